@@ -0,0 +1,19 @@
+package godirwalk
+
+// The access modes that may be combined and provided to Dirent.Accessible. They
+// have the same values as the R_OK, W_OK, and X_OK constants from
+// golang.org/x/sys/unix.
+const (
+	ExecuteOK = 0x1
+	WriteOK   = 0x2
+	ReadOK    = 0x4
+)
+
+// Accessible returns true if and only if the current process may access the
+// file system entry in the specified mode, which is a bitwise combination of
+// ReadOK, WriteOK, and ExecuteOK. On Unix this is determined by access(2),
+// which is more accurate than inspecting the mode bits because it accounts for
+// ownership, group membership, and access control lists. Note that access(2)
+// checks using the real rather than the effective user and group IDs. Symbolic
+// links are followed.
+func (de *Dirent) Accessible(mode int) bool { return accessible(de.osPathname(), mode) }
@@ -0,0 +1,29 @@
+package godirwalk
+
+import "os"
+
+// accessible approximates access(2) on Plan 9, which lacks it. Read access is
+// determined by attempting to open the file, while write and execute access are
+// determined by whether any of the corresponding permission bits are set,
+// because whether they apply to this process depends on the user and group
+// that own the file.
+func accessible(osPathname string, mode int) bool {
+	fi, err := os.Stat(osPathname)
+	if err != nil {
+		return false
+	}
+	if mode&WriteOK != 0 && fi.Mode().Perm()&0222 == 0 {
+		return false
+	}
+	if mode&ExecuteOK != 0 && fi.Mode().Perm()&0111 == 0 {
+		return false
+	}
+	if mode&ReadOK != 0 {
+		fh, err := os.Open(osPathname)
+		if err != nil {
+			return false
+		}
+		_ = fh.Close() // ignore potential error returned by Close
+	}
+	return true
+}
@@ -0,0 +1,9 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import "syscall"
+
+func accessible(osPathname string, mode int) bool {
+	return syscall.Access(osPathname, uint32(mode)) == nil
+}
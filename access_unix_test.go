@@ -0,0 +1,57 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirentAccessible(t *testing.T) {
+	root, cleanup := setupTree(t, "readable", "inaccessible")
+	defer cleanup()
+
+	t.Run("readable", func(t *testing.T) {
+		osPathname := filepath.Join(root, "readable")
+		ensureError(t, os.Chmod(osPathname, 0644))
+		de, err := NewDirent(osPathname)
+		ensureError(t, err)
+
+		if got, want := de.Accessible(ReadOK), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := de.Accessible(ReadOK|WriteOK), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if os.Geteuid() != 0 {
+			// root may execute any file having at least one execute bit
+			if got, want := de.Accessible(ExecuteOK), false; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+	})
+
+	t.Run("inaccessible", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can access files regardless of mode bits")
+		}
+		osPathname := filepath.Join(root, "inaccessible")
+		ensureError(t, os.Chmod(osPathname, 0))
+		de, err := NewDirent(osPathname)
+		ensureError(t, err)
+
+		for _, mode := range []int{ReadOK, WriteOK, ExecuteOK} {
+			if got, want := de.Accessible(mode), false; got != want {
+				t.Errorf("%d: GOT: %v; WANT: %v", mode, got, want)
+			}
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		de := &Dirent{path: filepath.Join(root, "missing"), name: "missing"}
+		if got, want := de.Accessible(ReadOK), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
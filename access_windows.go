@@ -0,0 +1,25 @@
+package godirwalk
+
+import "os"
+
+// accessible approximates access(2) on Windows, which lacks it. Read access is
+// determined by attempting to open the file, write access by the absence of the
+// read-only attribute, and because Windows has no execute permission, execute
+// access merely by the existence of the file.
+func accessible(osPathname string, mode int) bool {
+	fi, err := os.Stat(osPathname)
+	if err != nil {
+		return false
+	}
+	if mode&WriteOK != 0 && fi.Mode().Perm()&0200 == 0 {
+		return false
+	}
+	if mode&ReadOK != 0 {
+		fh, err := os.Open(osPathname)
+		if err != nil {
+			return false
+		}
+		_ = fh.Close() // ignore potential error returned by Close
+	}
+	return true
+}
@@ -0,0 +1,7 @@
+// +build !windows
+
+package godirwalk
+
+// alternateDataStreams always returns no streams, because alternate data
+// streams are only supported by NTFS on Windows.
+func alternateDataStreams(_ string) ([]string, error) { return nil, nil }
@@ -0,0 +1,64 @@
+package godirwalk
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32          = syscall.NewLazyDLL("kernel32.dll")
+	procFindFirstStreamW = modkernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = modkernel32.NewProc("FindNextStreamW")
+)
+
+// findStreamInfoStandard is the FindStreamInfoStandard information level of
+// FindFirstStreamW.
+const findStreamInfoStandard = 0
+
+// errorHandleEOF is returned by FindFirstStreamW and FindNextStreamW when there
+// are no more streams.
+const errorHandleEOF syscall.Errno = 38
+
+// win32FindStreamData mirrors the WIN32_FIND_STREAM_DATA structure.
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [syscall.MAX_PATH + 36]uint16
+}
+
+// alternateDataStreams returns the names of the alternate data streams of the
+// specified file, excluding its unnamed default data stream.
+func alternateDataStreams(osPathname string) ([]string, error) {
+	p, err := syscall.UTF16PtrFromString(osPathname)
+	if err != nil {
+		return nil, err
+	}
+
+	var data win32FindStreamData
+	h, _, e := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(p)), findStreamInfoStandard, uintptr(unsafe.Pointer(&data)), 0)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		if e == errorHandleEOF {
+			return nil, nil // the file has no data streams
+		}
+		return nil, &os.PathError{Op: "FindFirstStreamW", Path: osPathname, Err: e}
+	}
+	defer syscall.FindClose(syscall.Handle(h))
+
+	var names []string
+	for {
+		// Stream names have the form ":name:$DATA", where the name of the
+		// default data stream is empty.
+		name := strings.TrimSuffix(strings.TrimPrefix(syscall.UTF16ToString(data.StreamName[:]), ":"), ":$DATA")
+		if name != "" {
+			names = append(names, name)
+		}
+		r, _, e := procFindNextStreamW.Call(h, uintptr(unsafe.Pointer(&data)))
+		if r == 0 {
+			if e == errorHandleEOF {
+				return names, nil
+			}
+			return nil, &os.PathError{Op: "FindNextStreamW", Path: osPathname, Err: e}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package godirwalk
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkIncludeADS(t *testing.T) {
+	root, cleanup := setupTree(t, "d/f", "g")
+	defer cleanup()
+	if err := ioutil.WriteFile(filepath.Join(root, "g:hidden"), []byte("hidden\n"), 0644); err != nil {
+		t.Skip(err) // file system does not support alternate data streams
+	}
+
+	walkNames := func(t *testing.T, includeADS bool) []string {
+		t.Helper()
+		var names []string
+		err := Walk(root, &Options{
+			IncludeADS:    includeADS,
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, de *Dirent) error {
+				if got, want := de.Path(), osPathname; got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+				if got, want := de.IsAlternateDataStream(), strings.Contains(de.Name(), ":"); got != want {
+					t.Errorf("%s: GOT: %v; WANT: %v", de.Name(), got, want)
+				}
+				names = append(names, de.Name())
+				return nil
+			},
+		})
+		ensureError(t, err)
+		return names
+	}
+
+	t.Run("included", func(t *testing.T) {
+		if got, want := strings.Join(walkNames(t, true), " "), filepath.Base(root)+" d f g g:hidden"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("excluded", func(t *testing.T) {
+		if got, want := strings.Join(walkNames(t, false), " "), filepath.Base(root)+" d f g"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("stream contents", func(t *testing.T) {
+		err := Walk(root, &Options{
+			IncludeADS: true,
+			Callback: func(osPathname string, de *Dirent) error {
+				if !de.IsAlternateDataStream() {
+					return nil
+				}
+				fh, err := de.Open()
+				if err != nil {
+					return err
+				}
+				defer fh.Close()
+				buf, err := ioutil.ReadAll(fh)
+				if err != nil {
+					return err
+				}
+				if got, want := string(buf), "hidden\n"; got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+				return nil
+			},
+		})
+		ensureError(t, err)
+	})
+}
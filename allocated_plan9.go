@@ -0,0 +1,12 @@
+package godirwalk
+
+import (
+	"errors"
+	"os"
+)
+
+// allocatedSize returns an error, because Plan 9 does not report the space
+// allocated to files.
+func allocatedSize(osPathname string) (int64, int64, error) {
+	return 0, 0, &os.PathError{Op: "allocatedSize", Path: osPathname, Err: errors.New("not supported on plan9")}
+}
@@ -0,0 +1,19 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+// allocatedSize returns the apparent size of the specified file system node and
+// the number of bytes allocated to it, which POSIX reports in units of 512
+// bytes regardless of the block size of the file system.
+func allocatedSize(osPathname string) (int64, int64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(osPathname, &st); err != nil {
+		return 0, 0, &os.PathError{Op: "lstat", Path: osPathname, Err: err}
+	}
+	return int64(st.Size), int64(st.Blocks) * 512, nil
+}
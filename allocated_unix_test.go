@@ -0,0 +1,34 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirentSparseness(t *testing.T) {
+	root, cleanup := setupTree(t, "sparse")
+	defer cleanup()
+
+	const size = 16 << 20
+	ensureError(t, os.Truncate(filepath.Join(root, "sparse"), size)) // extends with a hole
+
+	de, err := NewDirent(filepath.Join(root, "sparse"))
+	ensureError(t, err)
+	apparent, allocated, err := de.Sparseness()
+	ensureError(t, err)
+	if got, want := apparent, int64(size); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if allocated >= apparent {
+		t.Skipf("file system allocated %d bytes to a sparse file of %d bytes", allocated, apparent)
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		de := NewDirentWithModeType(filepath.Join(root, "missing"), 0)
+		_, _, err := de.Sparseness()
+		ensureError(t, err, "missing")
+	})
+}
@@ -0,0 +1,32 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procGetCompressedFileSizeW = modkernel32.NewProc("GetCompressedFileSizeW")
+
+// invalidFileSize is returned by GetCompressedFileSizeW as the low-order
+// double word of the size upon failure, but also when that is its actual value.
+const invalidFileSize = 0xFFFFFFFF
+
+// allocatedSize returns the apparent size of the specified file system node and
+// the number of bytes allocated to it.
+func allocatedSize(osPathname string) (int64, int64, error) {
+	fi, err := os.Lstat(osPathname)
+	if err != nil {
+		return 0, 0, err
+	}
+	p, err := syscall.UTF16PtrFromString(osPathname)
+	if err != nil {
+		return 0, 0, err
+	}
+	var high uint32
+	low, _, e := procGetCompressedFileSizeW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&high)))
+	if low == invalidFileSize && e != syscall.Errno(0) {
+		return 0, 0, &os.PathError{Op: "GetCompressedFileSizeW", Path: osPathname, Err: e}
+	}
+	return fi.Size(), int64(high)<<32 | int64(uint32(low)), nil
+}
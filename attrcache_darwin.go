@@ -0,0 +1,15 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+// bypassAttrCache turns off data caching for the open directory handle.
+func bypassAttrCache(dh *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, dh.Fd(), syscall.F_NOCACHE, 1)
+	if errno != 0 {
+		return &os.PathError{Op: "fcntl", Path: dh.Name(), Err: errno}
+	}
+	return nil
+}
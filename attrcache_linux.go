@@ -0,0 +1,42 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The statx flags bypassAttrCache provides, which have the same values as the
+// AT_EMPTY_PATH and AT_STATX_FORCE_SYNC constants from golang.org/x/sys/unix.
+const (
+	atEmptyPath      = 0x1000
+	atStatxForceSync = 0x2000
+)
+
+// bypassAttrCache has the file system revalidate the attributes of the open
+// directory handle using statx(2) with AT_STATX_FORCE_SYNC. Network file
+// systems, such as NFS, then obtain the attributes of the directory from the
+// server, and discard their cached entries for the directory when it has
+// changed, so reading the directory reflects its most recent state. Linux does
+// not permit opening directories with O_DIRECT on most file systems, so there
+// is no means of bypassing the cache entirely. When statx is not available,
+// the attributes of the directory are obtained using fstat(2), which only
+// revalidates them once they expire.
+func bypassAttrCache(dh *os.File) error {
+	if sysStatx != 0 {
+		var stx statxT
+		_, _, errno := syscall.Syscall6(sysStatx, dh.Fd(), uintptr(unsafe.Pointer(&emptyPath)), atEmptyPath|atStatxForceSync, statxType|statxMtime, uintptr(unsafe.Pointer(&stx)), 0)
+		if errno == 0 {
+			return nil
+		}
+		if errno != syscall.ENOSYS {
+			return &os.PathError{Op: "statx", Path: dh.Name(), Err: errno}
+		}
+	}
+	_, err := dh.Stat()
+	return err
+}
+
+// emptyPath is the empty, NUL terminated pathname provided to statx along with
+// AT_EMPTY_PATH, so the file descriptor itself is described.
+var emptyPath byte
@@ -0,0 +1,9 @@
+// +build !darwin,!linux,!windows
+
+package godirwalk
+
+import "os"
+
+// bypassAttrCache does nothing, because this library does not know how to
+// bypass the file system attribute cache on this operating system.
+func bypassAttrCache(_ *os.File) error { return nil }
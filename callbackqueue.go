@@ -0,0 +1,90 @@
+package godirwalk
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// errCallbackQueueHalted is returned by invokeCallback when a queued callback
+// has halted the walk. Walk replaces it with the error that halted the walk,
+// which has already been provided to ErrorCallback.
+var errCallbackQueueHalted = errors.New("callback queue halted")
+
+// callbackQueue passes file system nodes from Walk to goroutines that invoke
+// Callback, used when CallbackQueueSize is positive.
+type callbackQueue struct {
+	items  chan queuedCallback
+	halted chan struct{} // closed when a callback halts the walk
+	once   sync.Once
+	err    error // error that halted the walk, set before halted is closed
+	wg     sync.WaitGroup
+}
+
+type queuedCallback struct {
+	osPathname string
+	dirent     *Dirent
+}
+
+// startCallbackQueue returns a new callbackQueue after starting the goroutines
+// that dequeue nodes from it.
+func startCallbackQueue(options *walker) *callbackQueue {
+	q := &callbackQueue{
+		items:  make(chan queuedCallback, options.CallbackQueueSize),
+		halted: make(chan struct{}),
+	}
+	for i := runtime.NumCPU(); i > 0; i-- {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for item := range q.items {
+				select {
+				case <-q.halted:
+					continue // drain remaining nodes without invoking Callback
+				default:
+				}
+				err := callCallback(item.osPathname, item.dirent, options)
+				if err == nil || err == filepath.SkipDir || err == FollowThis {
+					continue
+				}
+				if action := options.ErrorCallback(item.osPathname, err); action == SkipNode {
+					continue
+				}
+				q.once.Do(func() {
+					q.err = err
+					close(q.halted)
+				})
+			}
+		}()
+	}
+	return q
+}
+
+// enqueue waits until the node may be added to the queue, or returns
+// errCallbackQueueHalted when a callback has halted the walk. A copy of the
+// Dirent is enqueued, because Walk continues to update the Dirent of a
+// directory, such as to total the sizes of its children, while Callback may be
+// reading it.
+func (q *callbackQueue) enqueue(osPathname string, dirent *Dirent) error {
+	select {
+	case <-q.halted:
+		return errCallbackQueueHalted
+	default:
+	}
+	snapshot := *dirent
+	select {
+	case q.items <- queuedCallback{osPathname: osPathname, dirent: &snapshot}:
+		return nil
+	case <-q.halted:
+		return errCallbackQueueHalted
+	}
+}
+
+// close waits for the goroutines to invoke Callback for each enqueued node,
+// then returns the error that halted the walk, if any.
+func (q *callbackQueue) close() error {
+	close(q.items)
+	q.wg.Wait()
+	return q.err
+}
@@ -0,0 +1,144 @@
+package godirwalk
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkpointInterval is the minimum duration between writes of the checkpoint
+// file. It is a variable so tests may write the file for every node.
+var checkpointInterval = time.Second
+
+// checkpoint records the progress of a walk in the file named by the
+// CheckpointFile option, and resumes a walk from the progress recorded by a
+// previous walk.
+//
+// The file holds two lines: the pathname of the root of the walk, then the
+// pathname of the node whose callback most recently returned.
+type checkpoint struct {
+	pathname  string // of checkpoint file
+	root      string
+	last      string // pathname of most recently completed node
+	lastWrite time.Time
+
+	// resume holds the components of the pathname, relative to root, of the
+	// node completed last by the previous walk, while nodes preceding it are
+	// being skipped, and is nil otherwise.
+	resume []string
+}
+
+// Relative positions of a node with respect to the node completed last by a
+// previous walk.
+const (
+	checkpointAfter    = iota // node has not yet been visited
+	checkpointBefore          // node and its descendants have been visited
+	checkpointAncestor        // node has been visited, but some descendants may not have been
+)
+
+// loadCheckpoint returns a checkpoint for a walk of root, resuming from the
+// progress recorded in the specified file when it exists.
+func loadCheckpoint(pathname, root string) (*checkpoint, error) {
+	cp := &checkpoint{pathname: pathname, root: root, lastWrite: time.Now()}
+
+	buf, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		return nil, err
+	}
+
+	var lines []string
+	for scanner := bufio.NewScanner(strings.NewReader(string(buf))); scanner.Scan(); {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("cannot resume from checkpoint file %q: expected 2 lines; found %d", pathname, len(lines))
+	}
+	if lines[0] != root {
+		return nil, fmt.Errorf("cannot resume from checkpoint file %q: written while walking %q rather than %q", pathname, lines[0], root)
+	}
+	rel, err := filepath.Rel(root, lines[1])
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return nil, fmt.Errorf("cannot resume from checkpoint file %q: %q is not below %q", pathname, lines[1], root)
+	}
+
+	cp.last = lines[1]
+	cp.resume = pathComponents(rel)
+	return cp, nil
+}
+
+// pathComponents returns the components of the relative pathname, or an empty
+// slice for ".".
+func pathComponents(rel string) []string {
+	if rel == "." {
+		return []string{}
+	}
+	return strings.Split(rel, string(os.PathSeparator))
+}
+
+// position returns the position of the node with the specified pathname, which
+// is below root, relative to the node completed last by the previous walk, and
+// stops resuming upon reaching the first node after it. This relies upon the
+// walk visiting nodes in sorted order.
+func (cp *checkpoint) position(osPathname string) int {
+	rel, err := filepath.Rel(cp.root, osPathname)
+	if err != nil {
+		cp.resume = nil
+		return checkpointAfter
+	}
+	components := pathComponents(rel)
+	for i, component := range components {
+		if i == len(cp.resume) || component > cp.resume[i] {
+			cp.resume = nil // a descendant of, or sorted after, the node completed last
+			return checkpointAfter
+		}
+		if component < cp.resume[i] {
+			return checkpointBefore
+		}
+	}
+	if len(components) == len(cp.resume) {
+		cp.resume = nil // the node completed last; only nodes after it remain
+	}
+	return checkpointAncestor
+}
+
+// completed records that the callback for the specified node has returned,
+// writing the checkpoint file when it has not been written recently.
+func (cp *checkpoint) completed(osPathname string) error {
+	cp.last = osPathname
+	if now := time.Now(); now.Sub(cp.lastWrite) >= checkpointInterval {
+		cp.lastWrite = now
+		return cp.write()
+	}
+	return nil
+}
+
+// write atomically replaces the checkpoint file with the current progress.
+func (cp *checkpoint) write() error {
+	if cp.last == "" {
+		return nil // no progress to record
+	}
+	tmp := cp.pathname + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(cp.root+"\n"+cp.last+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.pathname)
+}
+
+// finish removes the checkpoint file after the walk completes, or writes it
+// when the walk halted due to an error.
+func (cp *checkpoint) finish(walkErr error) error {
+	if walkErr != nil {
+		return cp.write()
+	}
+	if err := os.Remove(cp.pathname); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
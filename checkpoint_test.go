@@ -0,0 +1,105 @@
+package godirwalk
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWalkCheckpointFile(t *testing.T) {
+	defer func(interval time.Duration) { checkpointInterval = interval }(checkpointInterval)
+	checkpointInterval = 0 // write the checkpoint file after every node
+
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e/f", "e/g", "h")
+	defer cleanup()
+	checkpointDir, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+	ensureError(t, err)
+	defer os.RemoveAll(checkpointDir)
+	checkpointFile := filepath.Join(checkpointDir, "checkpoint")
+
+	// walkUntil walks root, halting upon reaching the node with the specified
+	// relative pathname, and returns the relative pathnames visited.
+	walkUntil := func(t *testing.T, halt string) ([]string, error) {
+		t.Helper()
+		var visited []string
+		err := Walk(root, &Options{
+			ScratchBuffer:  testScratchBuffer,
+			CheckpointFile: checkpointFile,
+			Callback: func(osPathname string, _ *Dirent) error {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				rel = filepath.ToSlash(rel)
+				if rel == halt {
+					return errors.New("interrupted")
+				}
+				visited = append(visited, rel)
+				return nil
+			},
+			PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				visited = append(visited, "post "+filepath.ToSlash(rel))
+				return nil
+			},
+		})
+		return visited, err
+	}
+
+	visited, err := walkUntil(t, "e/g")
+	ensureError(t, err, "interrupted")
+	if got, want := strings.Join(visited, ","), ".,a,a/b,a/b/c,post a/b,a/d,post a,e,e/f"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	buf, err := ioutil.ReadFile(checkpointFile)
+	ensureError(t, err)
+	if got, want := string(buf), root+"\n"+filepath.Join(root, "e/f")+"\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	visited, err = walkUntil(t, "")
+	ensureError(t, err)
+	if got, want := strings.Join(visited, ","), "e/g,post e,h,post ."; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	if _, err = os.Stat(checkpointFile); !os.IsNotExist(err) {
+		t.Errorf("GOT: %v; WANT: %v", err, "not exist")
+	}
+
+	t.Run("different root", func(t *testing.T) {
+		ensureError(t, ioutil.WriteFile(checkpointFile, []byte("/some/other/root\n/some/other/root/a\n"), 0644))
+		defer os.Remove(checkpointFile)
+		_, err := walkUntil(t, "")
+		ensureError(t, err, "rather than")
+	})
+
+	t.Run("unsorted", func(t *testing.T) {
+		err := Walk(root, &Options{
+			CheckpointFile: checkpointFile,
+			Unsorted:       true,
+			Callback:       func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "cannot checkpoint")
+	})
+	t.Run("dir priority", func(t *testing.T) {
+		err := Walk(root, &Options{
+			CheckpointFile: checkpointFile,
+			DirPriority:    func(string, *Dirent) int { return 0 },
+			Callback:       func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "cannot checkpoint")
+	})
+	t.Run("creation time", func(t *testing.T) {
+		err := Walk(root, &Options{
+			CheckpointFile:     checkpointFile,
+			SortByCreationTime: true,
+			Callback:           func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "cannot checkpoint")
+	})
+}
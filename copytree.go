@@ -0,0 +1,233 @@
+package godirwalk
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// modeBits are the bits of a file mode CopyTree preserves.
+const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// CopyOptions specifies how CopyTree copies a file system hierarchy.
+type CopyOptions struct {
+	// Options specifies how CopyTree walks the hierarchy being copied.
+	Options
+
+	// DryRun specifies whether CopyTree merely walks the hierarchy, and
+	// returns the same errors for nodes that already exist that it otherwise
+	// would, without creating anything.
+	DryRun bool
+
+	// OverwriteExisting specifies whether CopyTree replaces nodes that
+	// already exist, rather than returning an error for them.
+	OverwriteExisting bool
+}
+
+// CopyTree walks the file system hierarchy rooted at src, and replicates it at
+// dst, which is created when it does not exist, in the manner of `cp -a`.
+// Directories are created, the contents of regular files are copied, and
+// symbolic links are copied as symbolic links, and never followed, while
+// devices, named pipes, and sockets are not copied. The permission bits,
+// modification times, and where the process is permitted to set them, the
+// owner, group, and extended attributes of each node are preserved. On Linux,
+// the extended attributes include any POSIX ACLs, and on macOS, any resource
+// forks.
+//
+// CopyTree does not call copyfile(3), so unlike copyfile(3) with
+// COPYFILE_METADATA, it does not preserve the access control lists of nodes on
+// macOS, which are not stored as extended attributes. On operating systems
+// other than Linux and macOS, such as the BSDs, only the permission bits and
+// modification times of nodes are preserved.
+//
+// When a node to be copied already exists below dst, CopyTree returns an
+// error for it unless the OverwriteExisting option is true, in which case the
+// existing node is replaced. A directory may be copied onto an existing
+// directory, whose contents are merged with those copied. Errors encountered
+// copying a node are handled by ErrorCallback as though returned by Callback.
+//
+// The provided CopyOptions may be nil. Only the fields of its Options that
+// HelperOptions copies are used, other than FollowSymbolicLinks and
+// ResolveRootSymlink, which are ignored, so symbolic links are copied as links.
+//
+//    options := &godirwalk.CopyOptions{OverwriteExisting: true}
+//    err := godirwalk.CopyTree(osSrcDirname, osDstDirname, options)
+func CopyTree(src, dst string, opts *CopyOptions) error {
+	var copyOptions CopyOptions
+	if opts != nil {
+		copyOptions = *opts
+	}
+	options := HelperOptions(&copyOptions.Options)
+	options.FollowSymbolicLinks = false
+	options.ResolveRootSymlink = false
+
+	src, dst = filepath.Clean(src), filepath.Clean(dst)
+	if err := ensureNotWithin(src, dst); err != nil {
+		return err
+	}
+
+	// target returns the pathname below dst corresponding to the node below
+	// src.
+	target := func(osPathname string) (string, error) {
+		rel, err := filepath.Rel(src, osPathname)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dst, rel), nil
+	}
+
+	options.Callback = func(osPathname string, _ *Dirent) error {
+		osTarget, err := target(osPathname)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		if copyOptions.DryRun {
+			_, err = checkTarget(osTarget, fi.IsDir(), copyOptions.OverwriteExisting)
+			return err
+		}
+		return copyNode(osPathname, osTarget, fi, copyOptions.OverwriteExisting)
+	}
+
+	// The mode and times of each directory are set once its children have
+	// been copied, so a read-only directory may be populated, and its
+	// modification time is not changed by doing so.
+	options.PostChildrenCallback = func(osPathname string, _ *Dirent) error {
+		if copyOptions.DryRun {
+			return nil
+		}
+		osTarget, err := target(osPathname)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		if err = copyMetadata(osPathname, osTarget, fi); err != nil {
+			return err
+		}
+		if err = os.Chmod(osTarget, fi.Mode()&modeBits); err != nil {
+			return err
+		}
+		return os.Chtimes(osTarget, fi.ModTime(), fi.ModTime())
+	}
+
+	return Walk(src, &options)
+}
+
+// ensureNotWithin returns an error when dst is src or one of its descendants,
+// which would cause CopyTree to copy its own copies.
+func ensureNotWithin(src, dst string) error {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absSrc, absDst)
+	if err != nil {
+		return nil // on different volumes
+	}
+	if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		return errors.New("cannot copy tree into itself: " + dst)
+	}
+	return nil
+}
+
+// checkTarget returns whether a node exists at the specified pathname that
+// must be replaced by the node being copied, or an error when such a node
+// exists but may not be replaced. An existing directory need not be replaced
+// by a directory.
+func checkTarget(osTarget string, isDir, overwrite bool) (bool, error) {
+	fi, err := os.Lstat(osTarget)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if isDir && fi.IsDir() {
+		return false, nil
+	}
+	if !overwrite {
+		return false, &os.PathError{Op: "copy", Path: osTarget, Err: os.ErrExist}
+	}
+	return true, nil
+}
+
+// copyNode copies the file system node at osSource, described by fi, to
+// osTarget.
+func copyNode(osSource, osTarget string, fi os.FileInfo, overwrite bool) error {
+	mode := fi.Mode()
+	if mode&(os.ModeDir|os.ModeSymlink) == 0 && !mode.IsRegular() {
+		return nil // devices, named pipes, and sockets are not copied
+	}
+
+	replace, err := checkTarget(osTarget, mode.IsDir(), overwrite)
+	if err != nil {
+		return err
+	}
+	if replace {
+		if err = os.Remove(osTarget); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case mode.IsDir():
+		if err = os.Mkdir(osTarget, 0700); os.IsExist(err) {
+			err = os.Chmod(osTarget, 0700) // ensure existing directory may be populated
+		}
+		return err // remaining metadata is copied once the children are copied
+	case mode&os.ModeSymlink != 0:
+		referent, err := os.Readlink(osSource)
+		if err != nil {
+			return err
+		}
+		if err = os.Symlink(referent, osTarget); err != nil {
+			return err
+		}
+		return copyMetadata(osSource, osTarget, fi)
+	}
+
+	if err = copyFile(osSource, osTarget); err != nil {
+		return err
+	}
+	// Ownership is copied before the mode, because changing the owner of a
+	// file clears its set-user-ID and set-group-ID bits.
+	if err = copyMetadata(osSource, osTarget, fi); err != nil {
+		return err
+	}
+	if err = os.Chmod(osTarget, mode&modeBits); err != nil {
+		return err
+	}
+	return os.Chtimes(osTarget, fi.ModTime(), fi.ModTime())
+}
+
+// copyFile copies the contents of the regular file at osSource to a newly
+// created file at osTarget.
+func copyFile(osSource, osTarget string) error {
+	src, err := os.Open(osSource)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(osTarget, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if er := dst.Close(); err == nil {
+		err = er
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package godirwalk
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyTree(t *testing.T) {
+	src, cleanup := setupTree(t, "a/b/c", "a/d", "e/", "f")
+	defer cleanup()
+	ensureError(t, os.Chmod(filepath.Join(src, "f"), 0640))
+	ensureError(t, os.Chmod(filepath.Join(src, "e"), 0750))
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	ensureError(t, os.Chtimes(filepath.Join(src, "a", "d"), mtime, mtime))
+	ensureError(t, os.Chtimes(filepath.Join(src, "a"), mtime, mtime))
+	haveSymlinks := os.Symlink("a/b", filepath.Join(src, "toB")) == nil
+
+	scratch, cleanupScratch := setupTree(t)
+	defer cleanupScratch()
+	dst := filepath.Join(scratch, "copy")
+
+	ensureError(t, CopyTree(src, dst, nil))
+
+	diff, err := DiffTrees(src, dst, &DiffOptions{CompareContents: true})
+	ensureError(t, err)
+	if got, want := len(diff.Added)+len(diff.Removed)+len(diff.Modified), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v (%+v)", got, want, diff)
+	}
+
+	for _, name := range []string{"a", "a/d", "e", "f"} {
+		fiSrc, err := os.Lstat(filepath.Join(src, name))
+		ensureError(t, err)
+		fiDst, err := os.Lstat(filepath.Join(dst, name))
+		ensureError(t, err)
+		if got, want := fiDst.Mode(), fiSrc.Mode(); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+		if got, want := fiDst.ModTime(), fiSrc.ModTime(); !got.Equal(want) {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+	}
+	if haveSymlinks {
+		referent, err := os.Readlink(filepath.Join(dst, "toB"))
+		ensureError(t, err)
+		if got, want := referent, "a/b"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	t.Run("existing", func(t *testing.T) {
+		ensureError(t, ioutil.WriteFile(filepath.Join(dst, "f"), []byte("stale\n"), 0644))
+
+		err := CopyTree(src, dst, nil)
+		if !errors.Is(err, os.ErrExist) {
+			t.Errorf("GOT: %v; WANT: %v", err, os.ErrExist)
+		}
+
+		ensureError(t, CopyTree(src, dst, &CopyOptions{OverwriteExisting: true}))
+		buf, err := ioutil.ReadFile(filepath.Join(dst, "f"))
+		ensureError(t, err)
+		if got, want := string(buf), "f\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dryRun := filepath.Join(scratch, "dry-run")
+		ensureError(t, CopyTree(src, dryRun, &CopyOptions{DryRun: true}))
+		if _, err := os.Lstat(dryRun); !os.IsNotExist(err) {
+			t.Errorf("GOT: %v; WANT: %v", err, os.ErrNotExist)
+		}
+
+		err := CopyTree(src, dst, &CopyOptions{DryRun: true})
+		if !errors.Is(err, os.ErrExist) {
+			t.Errorf("GOT: %v; WANT: %v", err, os.ErrExist)
+		}
+	})
+
+	t.Run("into itself", func(t *testing.T) {
+		ensureError(t, CopyTree(src, filepath.Join(src, "a", "copy"), nil), "into itself")
+		ensureError(t, CopyTree(src, src, nil), "into itself")
+	})
+}
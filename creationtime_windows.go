@@ -0,0 +1,51 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// sortByCreationTime sorts the children of the specified directory in
+// ascending order of the time NTFS recorded for the creation of each of them.
+// The sort is stable, so children created at the same time remain in their
+// prior order, and children whose creation time cannot be obtained are sorted
+// after all others.
+func sortByCreationTime(osDirname string, children Dirents) {
+	created := make([]int64, len(children))
+	known := make([]bool, len(children))
+	for i, de := range children {
+		fi, err := os.Lstat(filepath.Join(osDirname, de.name))
+		if err != nil {
+			continue
+		}
+		if fad, ok := fi.Sys().(*syscall.Win32FileAttributeData); ok {
+			created[i], known[i] = fad.CreationTime.Nanoseconds(), true
+		}
+	}
+	sort.Stable(&byCreationTime{children: children, created: created, known: known})
+}
+
+// byCreationTime sorts children in ascending order of their creation times,
+// which are swapped along with them.
+type byCreationTime struct {
+	children Dirents
+	created  []int64
+	known    []bool
+}
+
+func (b *byCreationTime) Len() int { return len(b.children) }
+
+func (b *byCreationTime) Less(i, j int) bool {
+	if b.known[i] != b.known[j] {
+		return b.known[i]
+	}
+	return b.created[i] < b.created[j]
+}
+
+func (b *byCreationTime) Swap(i, j int) {
+	b.children[i], b.children[j] = b.children[j], b.children[i]
+	b.created[i], b.created[j] = b.created[j], b.created[i]
+	b.known[i], b.known[j] = b.known[j], b.known[i]
+}
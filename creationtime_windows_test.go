@@ -0,0 +1,53 @@
+package godirwalk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWalkSortByCreationTime(t *testing.T) {
+	root, cleanup := setupTree(t)
+	defer cleanup()
+
+	// Create nodes out of lexical order, far enough apart that their creation
+	// times differ.
+	for _, name := range []string{"c", "a", "d", "b"} {
+		if name == "d" {
+			ensureError(t, os.Mkdir(filepath.Join(root, name), 0755))
+		} else {
+			ensureError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(name+"\n"), 0644))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var actual []string
+	var created []int64
+	err := Walk(root, &Options{
+		SortByCreationTime: true,
+		Callback: func(osPathname string, de *Dirent) error {
+			if osPathname == root {
+				return nil
+			}
+			fi, err := de.Info()
+			ensureError(t, err)
+			actual = append(actual, de.Name())
+			created = append(created, fi.Sys().(*syscall.Win32FileAttributeData).CreationTime.Nanoseconds())
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	if got, want := strings.Join(actual, " "), "c a d b"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	for i := 1; i < len(created); i++ {
+		if created[i] < created[i-1] {
+			t.Errorf("GOT: %s created before %s; WANT: ascending creation times", actual[i], actual[i-1])
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package godirwalk
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TreeDiff describes the differences between two file system hierarchies.
+// Each field holds pathnames relative to the roots of the hierarchies, using
+// solidus separators, sorted lexically.
+type TreeDiff struct {
+	Added    []string // nodes present only below the second root
+	Removed  []string // nodes present only below the first root
+	Modified []string // nodes present below both roots that differ
+}
+
+// DiffOptions specifies how DiffTrees compares file system hierarchies.
+type DiffOptions struct {
+	// Options specifies how DiffTrees walks each hierarchy.
+	Options
+
+	// CompareContents specifies whether regular files whose contents differ,
+	// and symbolic links whose referents differ, are modified, in addition to
+	// nodes whose modes differ.
+	CompareContents bool
+
+	// Comparator is an optional function invoked with the Dirents of each
+	// pair of nodes present below both roots, to decide whether they are
+	// equal, such as by comparing their modification times or checksums. When
+	// provided, a node is modified when Comparator returns false, and neither
+	// the modes of the nodes nor CompareContents are considered. The
+	// CachedFileInfo method of each Dirent returns its os.FileInfo.
+	Comparator func(a, b *Dirent) bool
+}
+
+// DiffTrees walks the file system hierarchies rooted at the specified
+// directories, and returns the nodes added to, removed from, and modified in
+// the second relative to the first. Nodes are matched by their pathnames
+// relative to their roots, and a node is modified when its mode differs, or
+// when the CompareContents option is true, when it is a regular file whose
+// contents differ, or a symbolic link whose referent differs. When the
+// Comparator option is provided, a node is instead modified when it returns
+// false. When a directory is added or removed, so is each of its descendants.
+//
+// The provided DiffOptions may be nil. Only the fields of its Options that
+// HelperOptions copies are used.
+//
+//    options := &godirwalk.DiffOptions{CompareContents: true}
+//    diff, err := godirwalk.DiffTrees(osDirnameA, osDirnameB, options)
+//    if err != nil {
+//        return err
+//    }
+//    for _, pathname := range diff.Modified {
+//        fmt.Printf("M %s\n", pathname)
+//    }
+func DiffTrees(a, b string, opts *DiffOptions) (*TreeDiff, error) {
+	var diffOptions DiffOptions
+	if opts != nil {
+		diffOptions = *opts
+	}
+	options := HelperOptions(&diffOptions.Options)
+
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	nodesA, err := diffNodes(a, &options)
+	if err != nil {
+		return nil, err
+	}
+	nodesB, err := diffNodes(b, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := new(TreeDiff)
+	for rel, deA := range nodesA {
+		deB, ok := nodesB[rel]
+		if !ok {
+			diff.Removed = append(diff.Removed, rel)
+			continue
+		}
+		if diffOptions.Comparator != nil {
+			if !diffOptions.Comparator(deA, deB) {
+				diff.Modified = append(diff.Modified, rel)
+			}
+			continue
+		}
+		modified, err := isModified(filepath.Join(a, rel), deA.fileInfo, filepath.Join(b, rel), deB.fileInfo, diffOptions.CompareContents)
+		if err != nil {
+			return nil, err
+		}
+		if modified {
+			diff.Modified = append(diff.Modified, rel)
+		}
+	}
+	for rel := range nodesB {
+		if _, ok := nodesA[rel]; !ok {
+			diff.Added = append(diff.Added, rel)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff, nil
+}
+
+// diffNodes returns a copy of the Dirent of each node below root, with its file
+// information populated, keyed by its slash-separated pathname relative to
+// root.
+func diffNodes(root string, options *Options) (map[string]*Dirent, error) {
+	nodes := make(map[string]*Dirent)
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if osPathname == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, osPathname)
+		if err != nil {
+			return err
+		}
+		fi := de.fileInfo
+		if fi == nil {
+			if fi, err = os.Lstat(osPathname); err != nil {
+				return err
+			}
+		}
+		node := *de
+		node.fileInfo = fi
+		nodes[filepath.ToSlash(rel)] = &node
+		return nil
+	}
+
+	if err := Walk(root, options); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// isModified returns true when the modes of the two nodes differ, or when
+// compareContents is true and their contents or referents differ.
+func isModified(osPathnameA string, fiA os.FileInfo, osPathnameB string, fiB os.FileInfo, compareContents bool) (bool, error) {
+	if fiA.Mode() != fiB.Mode() {
+		return true, nil
+	}
+	if !compareContents {
+		return false, nil
+	}
+	switch {
+	case fiA.Mode().IsRegular():
+		if fiA.Size() != fiB.Size() {
+			return true, nil
+		}
+		same, err := sameContents(osPathnameA, osPathnameB)
+		return !same, err
+	case fiA.Mode()&os.ModeSymlink != 0:
+		referentA, err := os.Readlink(osPathnameA)
+		if err != nil {
+			return false, err
+		}
+		referentB, err := os.Readlink(osPathnameB)
+		if err != nil {
+			return false, err
+		}
+		return referentA != referentB, nil
+	}
+	return false, nil
+}
+
+// sameContents returns true when the two files have the same contents.
+func sameContents(osPathnameA, osPathnameB string) (bool, error) {
+	fhA, err := os.Open(osPathnameA)
+	if err != nil {
+		return false, err
+	}
+	defer fhA.Close()
+	fhB, err := os.Open(osPathnameB)
+	if err != nil {
+		return false, err
+	}
+	defer fhB.Close()
+
+	bufA := make([]byte, 32*1024)
+	bufB := make([]byte, len(bufA))
+	for {
+		nA, errA := io.ReadFull(fhA, bufA)
+		nB, errB := io.ReadFull(fhB, bufB)
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			if errB == io.EOF || errB == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, errB
+		}
+	}
+}
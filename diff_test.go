@@ -0,0 +1,81 @@
+package godirwalk
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffTrees(t *testing.T) {
+	a, cleanupA := setupTree(t, "changed", "removed/x", "same", "type", "unchanged/y")
+	defer cleanupA()
+	b, cleanupB := setupTree(t, "added/z", "changed", "same", "type/", "unchanged/y")
+	defer cleanupB()
+
+	// Same size as, but different contents than, the corresponding file in a.
+	ensureError(t, ioutil.WriteFile(filepath.Join(b, "changed"), []byte("CHANGED\n"), 0644))
+
+	diffTrees := func(t *testing.T, options *DiffOptions) *TreeDiff {
+		t.Helper()
+		diff, err := DiffTrees(a, b, options)
+		ensureError(t, err)
+		return diff
+	}
+
+	check := func(t *testing.T, actual []string, expected string) {
+		t.Helper()
+		if got, want := strings.Join(actual, " "), expected; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	t.Run("modes", func(t *testing.T) {
+		diff := diffTrees(t, nil)
+		check(t, diff.Added, "added added/z")
+		check(t, diff.Removed, "removed removed/x")
+		check(t, diff.Modified, "type")
+	})
+
+	t.Run("contents", func(t *testing.T) {
+		diff := diffTrees(t, &DiffOptions{CompareContents: true})
+		check(t, diff.Added, "added added/z")
+		check(t, diff.Removed, "removed removed/x")
+		check(t, diff.Modified, "changed type")
+	})
+
+	t.Run("identical", func(t *testing.T) {
+		diff, err := DiffTrees(a, a, &DiffOptions{CompareContents: true})
+		ensureError(t, err)
+		if got, want := len(diff.Added)+len(diff.Removed)+len(diff.Modified), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("comparator", func(t *testing.T) {
+		sameMode := func(a, b *Dirent) bool { return a.CachedFileInfo().Mode() == b.CachedFileInfo().Mode() }
+		diff := diffTrees(t, &DiffOptions{Comparator: sameMode})
+		check(t, diff.Modified, "type")
+
+		sameContents := func(a, b *Dirent) bool {
+			if !a.IsRegular() || !b.IsRegular() {
+				return a.ModeType() == b.ModeType()
+			}
+			bufA, err := ioutil.ReadFile(a.Path())
+			ensureError(t, err)
+			bufB, err := ioutil.ReadFile(b.Path())
+			ensureError(t, err)
+			return bytes.Equal(bufA, bufB)
+		}
+		diff = diffTrees(t, &DiffOptions{Comparator: sameContents})
+		check(t, diff.Added, "added added/z")
+		check(t, diff.Removed, "removed removed/x")
+		check(t, diff.Modified, "changed type")
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := DiffTrees(a, filepath.Join(b, "missing"), nil)
+		ensureError(t, err, "missing")
+	})
+}
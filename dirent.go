@@ -1,8 +1,15 @@
 package godirwalk
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Dirent stores information about discovered file system
@@ -11,6 +18,53 @@ type Dirent struct {
 	path     string
 	name     string
 	modeType os.FileMode
+
+	// osPath is the actual pathname of the entry when it differs from path,
+	// because Walk reported a transformed name for the entry, and is empty
+	// otherwise.
+	osPath string
+
+	// realPath is the pathname a symbolic link resolves to, set by Walk when
+	// the FollowSymbolicLinks option is set, and is empty otherwise.
+	realPath string
+
+	// targetType is the mode type of the referent of a symbolic link, set by
+	// Walk, along with hasTargetType, when the AnnotateSymlinkTargets option
+	// is set and the referent exists.
+	targetType    os.FileMode
+	hasTargetType bool
+
+	// symlinkHops is the number of symbolic links in the chain beginning with
+	// this one, set by Walk when the CountSymlinkHops option is set.
+	symlinkHops int
+
+	// root is the pathname of the root of the walk that produced this Dirent,
+	// set by Walk, and is empty otherwise.
+	root string
+
+	// ads is set by Walk when the Dirent represents an alternate data stream
+	// of a file, reported because the IncludeADS option is set.
+	ads bool
+
+	fileInfo os.FileInfo // nil unless populated during construction
+
+	// subtreeSize is the total size of this node when it is not a directory,
+	// or of all non-directory descendants when it is, as accumulated by Walk.
+	subtreeSize int64
+
+	// noReuse is set by Walk when the NoReuseHint option is set, in which
+	// case files opened using Open are advised not to be cached.
+	noReuse bool
+
+	// noATime is set by Walk when the NoATime option is set, in which case
+	// files opened using Open do not have their access times updated.
+	noATime bool
+
+	// syncOpened is set by Walk when the SyncAfterCallback option is set and
+	// the callback is being invoked, in which case opened accumulates the
+	// files opened using OpenForWrite, so Walk can sync and close them.
+	syncOpened bool
+	opened     []*os.File
 }
 
 // NewDirent returns a newly initialized Dirent structure, or an error.  This
@@ -28,15 +82,100 @@ func NewDirent(osPathname string) (*Dirent, error) {
 		path:     osPathname,
 		name:     filepath.Base(osPathname),
 		modeType: fi.Mode() & os.ModeType,
+		fileInfo: fi,
 	}, nil
 }
 
+// NewDirentWithModeType returns a newly initialized Dirent structure for the
+// specified pathname having the specified mode type, without accessing the
+// file system, such as for an EntryDecoder to return for each entry it decodes.
+func NewDirentWithModeType(osPathname string, modeType os.FileMode) *Dirent {
+	return &Dirent{
+		path:     osPathname,
+		name:     filepath.Base(osPathname),
+		modeType: modeType & os.ModeType,
+	}
+}
+
 // Path returns the original filepath used to create the filesystem entity
 func (de Dirent) Path() string { return de.path }
 
+// AbsPath returns the absolute and cleaned representation of the filepath used
+// to create the file system entity. Because Path returns the pathname as it was
+// provided, which may be relative to the current working directory, this is
+// useful when the pathname must be given to another process.
+func (de Dirent) AbsPath() (string, error) {
+	absPath, err := filepath.Abs(de.path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(absPath), nil
+}
+
+// osPathname returns the pathname used to access the file system entry.
+func (de Dirent) osPathname() string {
+	if de.osPath != "" {
+		return de.osPath
+	}
+	return de.path
+}
+
+// PathComponents returns the elements of the cleaned pathname of the file
+// system entry. When the pathname is absolute, the first element is the
+// volume name, if any, followed by the path separator, such as "/" on Unix,
+// or `C:\` or `\\host\share\` on Windows. When a volume name is followed by a
+// relative pathname, such as "C:a", the volume name is its own element,
+// without a trailing separator. For instance, the components of
+// "/usr/local/bin" are "/", "usr", "local", and "bin".
+func (de Dirent) PathComponents() []string {
+	pathname := filepath.Clean(de.path)
+	volume := filepath.VolumeName(pathname)
+	rest := pathname[len(volume):]
+
+	var components []string
+	if len(rest) > 0 && os.IsPathSeparator(rest[0]) {
+		components = append(components, volume+string(os.PathSeparator))
+		rest = rest[1:]
+	} else if volume != "" {
+		components = append(components, volume)
+	}
+	if rest != "" {
+		components = append(components, strings.Split(rest, string(os.PathSeparator))...)
+	}
+	return components
+}
+
+// IsUnder returns true if and only if the pathname of the file system entry is
+// the specified ancestor directory, or lies within it. Both pathnames are
+// cleaned and made absolute before being compared, component by component, so
+// "/ab" is not under "/a", and on Windows they are compared without regard to
+// case, as the file system does. It returns false when either pathname cannot
+// be made absolute, or when they are on different volumes.
+func (de Dirent) IsUnder(ancestor string) bool {
+	absAncestor, err := filepath.Abs(ancestor)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(de.path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absAncestor, absPath)
+	if err != nil {
+		return false // on different volumes
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
 // Name returns the basename of the file system entry.
 func (de Dirent) Name() string { return de.name }
 
+// MatchName reports whether the basename of the file system entry matches the
+// shell file name pattern, using the same pattern syntax as filepath.Match. The
+// only possible returned error is filepath.ErrBadPattern, when pattern is
+// malformed.
+func (de Dirent) MatchName(pattern string) (bool, error) { return filepath.Match(pattern, de.name) }
+
 // ModeType returns the mode bits that specify the file system node type.  We
 // could make our own enum-like data type for encoding the file type, but Go's
 // runtime already gives us architecture independent file modes, as discussed in
@@ -46,6 +185,132 @@ func (de Dirent) Name() string { return de.name }
 //    information about files can be moved from one system to another portably.
 func (de Dirent) ModeType() os.FileMode { return de.modeType }
 
+// CachedFileInfo returns the os.FileInfo for the file system entry obtained
+// when the Dirent was created, or nil when none was obtained. Dirent structures
+// created by NewDirent always have this information, as do those provided by
+// Walk when the PreloadFileInfo option is set. This function does not follow
+// symbolic links, so the returned information describes the entry itself.
+func (de Dirent) CachedFileInfo() os.FileInfo { return de.fileInfo }
+
+// Info returns the os.FileInfo for the file system entry, invoking os.Lstat
+// upon the first call when the Dirent was created without that information,
+// and caching the result for subsequent calls. Like CachedFileInfo, this
+// function does not follow symbolic links.
+func (de *Dirent) Info() (os.FileInfo, error) {
+	if de.fileInfo == nil {
+		fi, err := os.Lstat(de.osPathname())
+		if err != nil {
+			return nil, err
+		}
+		de.fileInfo = fi
+	}
+	return de.fileInfo, nil
+}
+
+// IsSetuid returns true if and only if the set-user-ID bit of the file system
+// entry is set, obtaining the mode of the entry using Info.
+func (de *Dirent) IsSetuid() (bool, error) { return de.hasModeBits(os.ModeSetuid) }
+
+// IsSetgid returns true if and only if the set-group-ID bit of the file system
+// entry is set, obtaining the mode of the entry using Info.
+func (de *Dirent) IsSetgid() (bool, error) { return de.hasModeBits(os.ModeSetgid) }
+
+// IsSticky returns true if and only if the sticky bit of the file system entry
+// is set, obtaining the mode of the entry using Info.
+func (de *Dirent) IsSticky() (bool, error) { return de.hasModeBits(os.ModeSticky) }
+
+func (de *Dirent) hasModeBits(bits os.FileMode) (bool, error) {
+	fi, err := de.Info()
+	if err != nil {
+		return false, err
+	}
+	return fi.Mode()&bits != 0, nil
+}
+
+// SubtreeSize returns the size in bytes of the file system entry when it is not
+// a directory, or the total size of all of its non-directory descendants when
+// it is a directory. It is only populated by Walk when the
+// AccumulateSubtreeSizes option is set, and for directories is only complete
+// once the directory's children have been processed, i.e., when the Dirent is
+// provided to PostChildrenCallback.
+func (de Dirent) SubtreeSize() int64 { return de.subtreeSize }
+
+// Sparseness returns both the apparent size in bytes of the file system entry,
+// which is the size reported by its file information, and the number of bytes
+// the file system has allocated to store it, obtaining both anew without
+// following symbolic links. The allocated size of a sparse file is less than
+// its apparent size, so callers may compute the ratio of the two in one call.
+// On Windows, the allocated size is reported by GetCompressedFileSizeW, which
+// is the apparent size for files that are neither sparse nor compressed.
+func (de *Dirent) Sparseness() (apparent, allocated int64, err error) {
+	return allocatedSize(de.osPathname())
+}
+
+// OpenForWrite opens the file system entry for writing, without creating or
+// truncating it. Ordinarily the caller is responsible for closing the returned
+// file. However, when invoked on the Dirent provided to a Callback function
+// while Walk is running with the SyncAfterCallback option set, Walk syncs and
+// closes the returned file after the Callback function returns, and the
+// Callback function must not close it.
+func (de *Dirent) OpenForWrite() (*os.File, error) {
+	fh, err := os.OpenFile(de.osPathname(), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if de.syncOpened {
+		de.opened = append(de.opened, fh)
+	}
+	return fh, nil
+}
+
+// Open opens the file system entry for reading, and the caller is responsible
+// for closing the returned file. When invoked on a Dirent provided by Walk
+// with the NoReuseHint option set, the operating system is advised that the
+// contents of the file will not be read again, and with the NoATime option
+// set, the file is opened without updating its access time when permitted.
+func (de Dirent) Open() (*os.File, error) {
+	osPathname := de.osPathname()
+	fh, err := os.OpenFile(osPathname, os.O_RDONLY|de.openFlags(), 0)
+	if err != nil && de.noATime && os.IsPermission(err) {
+		// Only the owner of a file, or a process with CAP_FOWNER, may open
+		// it with O_NOATIME, so open it normally otherwise.
+		fh, err = os.Open(osPathname)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if de.noReuse {
+		adviseNoReuse(fh)
+	}
+	return fh, nil
+}
+
+// openFlags returns the flags Open adds to O_RDONLY.
+func (de Dirent) openFlags() int {
+	if de.noATime {
+		return oNoatime
+	}
+	return 0
+}
+
+// NewReader opens the file system entry for reading using Open, and returns a
+// buffered reader of its contents, along with a function that closes the file,
+// which the caller is responsible for invoking once it is done reading.
+//
+//    r, closeFile, err := de.NewReader()
+//    if err != nil {
+//        return err
+//    }
+//    defer closeFile()
+//    line, err := r.ReadString('\n')
+func (de Dirent) NewReader() (*bufio.Reader, func() error, error) {
+	fh, err := de.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return bufio.NewReader(fh), fh.Close, nil
+}
+
 // IsDir returns true if and only if the Dirent represents a file system
 // directory.  Note that on some operating systems, more than one file mode bit
 // may be set for a node.  For instance, on Windows, a symbolic link that points
@@ -63,17 +328,32 @@ func (de Dirent) IsRegular() bool { return de.modeType&os.ModeType == 0 }
 // set.
 func (de Dirent) IsSymlink() bool { return de.modeType&os.ModeSymlink != 0 }
 
+// maxSymlinks is the number of symbolic links FollowSymlink follows in a chain
+// before reporting a loop, matching MAXSYMLINKS on Linux.
+const maxSymlinks = 40
+
 // If the Dirent is a symlink, then this function
 // will resolve that symlink and return a new Dirent wrapping
 // the resolved filepath on the system.
-// Returns the original Dirent in the case of an error or if the original Dirent.IsSymlink() == false.
+// Returns the original Dirent in the case of an error or if the original
+// Dirent.IsSymlink() == false. When the chain of symlinks is longer than 40
+// links, including when it is a cycle, the error is a *SymlinkCycleError
+// wrapping ErrSymlinkLoop, whose Chain field lists the links followed.
 func (de Dirent) FollowSymlink() (*Dirent, error) {
 	if !de.IsSymlink() {
 		// return the de unchanged
 		return &de, nil
 	}
 
-	resolvedPath, err := filepath.EvalSymlinks(de.path)
+	chain, osPathname, err := symlinkChain(de.osPathname())
+	if err == ErrSymlinkLoop {
+		return &de, &SymlinkCycleError{Dirent: &de, Err: err, Chain: chain}
+	}
+	if err != nil {
+		return &de, err
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(osPathname)
 	if err != nil {
 		return &de, err
 	}
@@ -91,9 +371,167 @@ func (de Dirent) FollowSymlink() (*Dirent, error) {
 	return resolvedDe, nil
 }
 
+// symlinkChain follows the chain of symbolic links beginning with the specified
+// symbolic link one link at a time, rather than leaving it to the operating
+// system, so a chain too long to follow may be reported along with the links
+// in it, rather than as ELOOP. It returns the pathname of each link in the
+// chain, and the pathname of the node that is not a symbolic link the chain
+// ends with, or ErrSymlinkLoop when the chain is longer than maxSymlinks.
+func symlinkChain(osPathname string) ([]string, string, error) {
+	chain := []string{osPathname}
+	for {
+		referent, err := os.Readlink(osPathname)
+		if err != nil {
+			return chain, "", err
+		}
+		if !filepath.IsAbs(referent) {
+			// Resolve the directory holding the link, so any ".." in the
+			// referent is relative to where the link actually resides.
+			osDirname, err := filepath.EvalSymlinks(filepath.Dir(osPathname))
+			if err != nil {
+				return chain, "", err
+			}
+			referent = filepath.Join(osDirname, referent)
+		}
+		fi, err := os.Lstat(referent)
+		if err != nil {
+			return chain, "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return chain, referent, nil
+		}
+		chain = append(chain, referent)
+		if len(chain) > maxSymlinks {
+			return chain, "", ErrSymlinkLoop
+		}
+		osPathname = referent
+	}
+}
+
+// RealPath returns the pathname the symbolic link represented by the Dirent
+// resolves to, as returned by filepath.EvalSymlinks, when Walk resolved it
+// because the FollowSymbolicLinks option is set, and an empty string
+// otherwise, including when the link is dangling. Path continues to return the
+// pathname of the link itself.
+func (de Dirent) RealPath() string { return de.realPath }
+
+// ResolveRelativeTarget returns the referent of the symbolic link represented
+// by the Dirent, resolved lexically against the directory containing the link,
+// and made relative to the root of the walk that produced the Dirent when the
+// referent lies within it, which is useful when archiving a hierarchy that
+// stores links relative to its root. Unlike filepath.EvalSymlinks, it reads
+// only this link, does not resolve any symbolic links in the pathname of the
+// referent, and does not require the referent to exist. When the referent
+// lies outside the root, or the Dirent was not produced by Walk, it returns
+// the cleaned pathname of the referent, which is relative only when both the
+// pathname of the Dirent and the link are relative. It returns an error when
+// the Dirent does not represent a symbolic link.
+//
+// For instance, for the link "root/a/b/link" whose referent is "../c", walked
+// from "root", it returns "a/c".
+func (de Dirent) ResolveRelativeTarget() (string, error) {
+	if !de.IsSymlink() {
+		return "", fmt.Errorf("cannot resolve target of non-symlink: %s", de.path)
+	}
+	referent, err := os.Readlink(de.osPathname())
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(referent) {
+		referent = filepath.Join(filepath.Dir(de.path), referent)
+	}
+	referent = filepath.Clean(referent)
+	if de.root == "" {
+		return referent, nil
+	}
+	rel, err := filepath.Rel(de.root, referent)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return referent, nil // not within the root
+	}
+	return rel, nil
+}
+
+// TargetType returns the mode type of the referent of the symbolic link
+// represented by the Dirent, and true, when Walk resolved it because the
+// AnnotateSymlinkTargets option is set. It returns false when the Dirent does
+// not represent a symbolic link, the option is not set, or the referent could
+// not be resolved, such as when the link is dangling.
+func (de Dirent) TargetType() (os.FileMode, bool) { return de.targetType, de.hasTargetType }
+
+// SymlinkHops returns the number of symbolic links that must be followed to
+// reach the referent of the symbolic link represented by the Dirent, including
+// itself, as counted by Walk when the CountSymlinkHops option is set, and
+// zero otherwise. When the chain cannot be followed to its end, such as when
+// it is dangling or too long, it returns the number of links followed until
+// then.
+func (de Dirent) SymlinkHops() int { return de.symlinkHops }
+
+// IsAlternateDataStream returns true if and only if the Dirent represents an
+// NTFS alternate data stream of a regular file, which Walk reports when the
+// IncludeADS option is set.
+func (de Dirent) IsAlternateDataStream() bool { return de.ads }
+
 // IsDevice returns true if and only if the Dirent represents a device file.
 func (de Dirent) IsDevice() bool { return de.modeType&os.ModeDevice != 0 }
 
+// String returns a stable, human readable representation of the Dirent, such as
+// "Dirent{path: /a/b, type: d}", suitable for logging. The type is a single
+// character in the style of `ls -l`: '-' for regular files, 'd' for
+// directories, 'l' for symbolic links, 'c' for character devices, 'b' for block
+// devices, 'p' for named pipes, 's' for sockets, and '?' for anything else.
+func (de Dirent) String() string {
+	return "Dirent{path: " + de.path + ", type: " + string(typeChar(de.modeType)) + "}"
+}
+
+// direntJSON is the JSON representation of a Dirent.
+type direntJSON struct {
+	Path     string      `json:"path"`
+	Name     string      `json:"name"`
+	ModeType os.FileMode `json:"modeType"`
+}
+
+// MarshalJSON returns the JSON encoding of the Dirent, which is an object
+// holding its pathname, name, and mode type, such as
+// {"path":"/a/b","name":"b","modeType":2147483648}.
+func (de Dirent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(direntJSON{Path: de.path, Name: de.name, ModeType: de.modeType})
+}
+
+// UnmarshalJSON sets the pathname, name, and mode type of the Dirent from the
+// JSON encoding returned by MarshalJSON.
+func (de *Dirent) UnmarshalJSON(data []byte) error {
+	var v direntJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*de = Dirent{path: v.Path, name: v.Name, modeType: v.ModeType & os.ModeType}
+	return nil
+}
+
+// typeChar returns the `ls -l` style character for the specified mode type.
+// Symbolic links are checked first, because on some operating systems symbolic
+// links to directories have both mode type bits set.
+func typeChar(modeType os.FileMode) byte {
+	switch {
+	case modeType&os.ModeSymlink != 0:
+		return 'l'
+	case modeType&os.ModeDir != 0:
+		return 'd'
+	case modeType&os.ModeCharDevice != 0:
+		return 'c'
+	case modeType&os.ModeDevice != 0:
+		return 'b'
+	case modeType&os.ModeNamedPipe != 0:
+		return 'p'
+	case modeType&os.ModeSocket != 0:
+		return 's'
+	case modeType&os.ModeType == 0:
+		return '-'
+	default:
+		return '?'
+	}
+}
+
 // Dirents represents a slice of Dirent pointers, which are sortable by
 // name. This type satisfies the `sort.Interface` interface.
 type Dirents []*Dirent
@@ -107,3 +545,160 @@ func (l Dirents) Less(i, j int) bool { return l[i].name < l[j].name }
 
 // Swap exchanges the two Dirent entries specified by the two provided indexes.
 func (l Dirents) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// MarshalJSON returns the JSON encoding of the slice, which is an array holding
+// the encoding of each Dirent, as returned by its MarshalJSON method, in
+// order. A nil slice is encoded as an empty array, and a nil Dirent as null.
+//
+//    children, err := godirwalk.ReadDirents(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    sort.Sort(children)
+//    buf, err := children.MarshalJSON()
+func (l Dirents) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]*Dirent(l))
+}
+
+// SortByDepth returns a new slice holding the Dirent entries sorted by the
+// depth of their pathnames, which is the number of path separators they
+// contain, in ascending order, then by name. This is useful for processing
+// entries gathered from multiple directories parents first.
+func (l Dirents) SortByDepth() Dirents { return l.sortByDepth(false) }
+
+// SortByDepthDesc returns a new slice holding the Dirent entries sorted by the
+// depth of their pathnames in descending order, then by name. This is useful
+// for processing entries gathered from multiple directories children first,
+// such as when removing them.
+func (l Dirents) SortByDepthDesc() Dirents { return l.sortByDepth(true) }
+
+func (l Dirents) sortByDepth(descending bool) Dirents {
+	sorted := make(Dirents, len(l))
+	copy(sorted, l)
+	depths := make(map[*Dirent]int, len(sorted))
+	for _, de := range sorted {
+		depths[de] = strings.Count(filepath.ToSlash(de.path), "/")
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		di, dj := depths[sorted[i]], depths[sorted[j]]
+		if di != dj {
+			return di < dj != descending
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
+
+// Each invokes fn for each Dirent in the slice, in order, stopping at and
+// returning the first error fn returns.
+func (l Dirents) Each(fn func(*Dirent) error) error {
+	for _, de := range l {
+		if err := fn(de); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reduce aggregates the slice into a single value by invoking fn for each
+// Dirent, in order, with the value accumulated so far, starting with initial,
+// and returns the value fn returns for the final Dirent, or initial when the
+// slice is empty.
+//
+//    countDir := func(acc interface{}, de *godirwalk.Dirent) interface{} {
+//        if de.IsDir() {
+//            return acc.(int) + 1
+//        }
+//        return acc
+//    }
+//    count := children.Reduce(0, countDir).(int)
+func (l Dirents) Reduce(initial interface{}, fn func(interface{}, *Dirent) interface{}) interface{} {
+	acc := initial
+	for _, de := range l {
+		acc = fn(acc, de)
+	}
+	return acc
+}
+
+// ReduceInt64 is like Reduce, but accumulates an int64, such as a count or a
+// total size, without requiring type assertions.
+func (l Dirents) ReduceInt64(initial int64, fn func(int64, *Dirent) int64) int64 {
+	acc := initial
+	for _, de := range l {
+		acc = fn(acc, de)
+	}
+	return acc
+}
+
+// ReduceString is like Reduce, but accumulates a string, such as a list of
+// names, without requiring type assertions.
+func (l Dirents) ReduceString(initial string, fn func(string, *Dirent) string) string {
+	acc := initial
+	for _, de := range l {
+		acc = fn(acc, de)
+	}
+	return acc
+}
+
+// TotalSize returns the sum of the sizes of the Dirents in the slice, as
+// reported by their Info methods, which use the file information cached in
+// each Dirent when available, and otherwise invoke os.Lstat. The size of a
+// directory is its own size as reported by the operating system, not the size
+// of its descendants. TotalSize returns the first error returned by Info.
+func (l Dirents) TotalSize() (int64, error) {
+	var total int64
+	for _, de := range l {
+		fi, err := de.Info()
+		if err != nil {
+			return 0, err
+		}
+		total += fi.Size()
+	}
+	return total, nil
+}
+
+// EachParallel invokes fn for each Dirent in the slice, from no more than
+// concurrency goroutines at once, or runtime.NumCPU goroutines when
+// concurrency is not positive, and returns the first error fn returns. Once fn
+// returns an error, it is not invoked for the remaining Dirents, although
+// EachParallel waits for invocations already in progress to return.
+func (l Dirents) EachParallel(fn func(*Dirent) error, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, de := range l {
+		sem <- struct{}{} // wait for an available goroutine
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		wg.Add(1)
+		go func(de *Dirent) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(de); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(de)
+	}
+
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,383 @@
+package godirwalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// chdir changes the working directory of the process to the specified
+// directory, and returns a function that restores the original working
+// directory.
+func chdir(tb testing.TB, osDirname string) func() {
+	tb.Helper()
+	cwd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err = os.Chdir(osDirname); err != nil {
+		tb.Fatal(err)
+	}
+	return func() {
+		if err := os.Chdir(cwd); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestDirentAbsPath(t *testing.T) {
+	defer chdir(t, testRoot)()
+
+	actual := make(map[string]string)
+
+	err := Walk("d0/d1", &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			absPath, err := de.AbsPath()
+			if err != nil {
+				return err
+			}
+			actual[osPathname] = absPath
+			return nil
+		},
+	})
+
+	ensureError(t, err)
+
+	expected := map[string]string{
+		filepath.FromSlash("d0/d1"):    filepath.Join(testRoot, "d0/d1"),
+		filepath.FromSlash("d0/d1/f2"): filepath.Join(testRoot, "d0/d1/f2"),
+	}
+
+	if got, want := len(actual), len(expected); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	for osPathname, want := range expected {
+		if got := actual[osPathname]; got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", osPathname, got, want)
+		}
+	}
+}
+
+func TestDirentIsUnder(t *testing.T) {
+	de := &Dirent{path: filepath.FromSlash("/a/b/c"), name: "c"}
+
+	for ancestor, want := range map[string]bool{
+		"/":        true,
+		"/a":       true,
+		"/a/":      true,
+		"/a/b":     true,
+		"/a/b/c":   true, // exact
+		"/a/./b":   true,
+		"/a/x/..":  true,
+		"/a/b/c/d": false,
+		"/ab":      false, // sibling sharing a prefix
+		"/a/b/cc":  false,
+		"/x":       false,
+		"a":        false, // relative to the working directory
+	} {
+		if got := de.IsUnder(filepath.FromSlash(ancestor)); got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", ancestor, got, want)
+		}
+	}
+
+	t.Run("relative", func(t *testing.T) {
+		de := &Dirent{path: filepath.FromSlash("d0/d1/f2"), name: "f2"}
+		if got, want := de.IsUnder("d0"), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := de.IsUnder("d"), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestDirentMatchName(t *testing.T) {
+	de := &Dirent{path: filepath.FromSlash("some/dir/file.txt"), name: "file.txt"}
+
+	for pattern, want := range map[string]bool{
+		"*.txt":    true,
+		"file.*":   true,
+		"f?le.txt": true,
+		"*.go":     false,
+		"dir":      false,
+		"*/*.txt":  false,
+	} {
+		got, err := de.MatchName(pattern)
+		ensureError(t, err)
+		if got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", pattern, got, want)
+		}
+	}
+
+	_, err := de.MatchName("[")
+	if got, want := err, filepath.ErrBadPattern; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestDirentString(t *testing.T) {
+	for modeType, want := range map[os.FileMode]string{
+		0:                                 "Dirent{path: /a/b, type: -}",
+		os.ModeDir:                        "Dirent{path: /a/b, type: d}",
+		os.ModeSymlink:                    "Dirent{path: /a/b, type: l}",
+		os.ModeSymlink | os.ModeDir:       "Dirent{path: /a/b, type: l}",
+		os.ModeDevice | os.ModeCharDevice: "Dirent{path: /a/b, type: c}",
+		os.ModeDevice:                     "Dirent{path: /a/b, type: b}",
+		os.ModeNamedPipe:                  "Dirent{path: /a/b, type: p}",
+		os.ModeSocket:                     "Dirent{path: /a/b, type: s}",
+		os.ModeIrregular:                  "Dirent{path: /a/b, type: ?}",
+	} {
+		de := &Dirent{path: "/a/b", name: "b", modeType: modeType}
+		if got := de.String(); got != want {
+			t.Errorf("%v: GOT: %q; WANT: %q", modeType, got, want)
+		}
+		if got := fmt.Sprint(de); got != want {
+			t.Errorf("%v: GOT: %q; WANT: %q", modeType, got, want)
+		}
+	}
+}
+
+func TestDirentsMarshalJSON(t *testing.T) {
+	l := Dirents{
+		{path: "/a/z", name: "z", modeType: os.ModeDir},
+		{path: "/a/b", name: "b"},
+		{path: "/a/l", name: "l", modeType: os.ModeSymlink},
+		{path: "/a/c", name: "c", modeType: os.ModeDevice | os.ModeCharDevice},
+	}
+
+	buf, err := l.MarshalJSON()
+	ensureError(t, err)
+
+	const expected = `[{"path":"/a/z","name":"z","modeType":2147483648},` +
+		`{"path":"/a/b","name":"b","modeType":0},` +
+		`{"path":"/a/l","name":"l","modeType":134217728},` +
+		`{"path":"/a/c","name":"c","modeType":69206016}]`
+	if got, want := string(buf), expected; got != want {
+		t.Errorf("GOT: %s; WANT: %s", got, want)
+	}
+
+	var decoded Dirents
+	ensureError(t, json.Unmarshal(buf, &decoded))
+	if got, want := len(decoded), len(l); got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, de := range decoded {
+		if got, want := *de, *l[i]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: GOT: %v; WANT: %v", i, got, want)
+		}
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		buf, err := Dirents(nil).MarshalJSON()
+		ensureError(t, err)
+		if got, want := string(buf), "[]"; got != want {
+			t.Errorf("GOT: %s; WANT: %s", got, want)
+		}
+	})
+}
+
+func TestDirentsSortByDepth(t *testing.T) {
+	var l Dirents
+	for _, p := range []string{"/r/a/b/c", "/r/z", "/r/a/y", "/r/a", "/r/a/b"} {
+		l = append(l, &Dirent{path: p, name: filepath.Base(p)})
+	}
+
+	paths := func(l Dirents) string {
+		var s []string
+		for _, de := range l {
+			s = append(s, de.Path())
+		}
+		return strings.Join(s, " ")
+	}
+
+	original := paths(l)
+
+	if got, want := paths(l.SortByDepth()), "/r/a /r/z /r/a/b /r/a/y /r/a/b/c"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := paths(l.SortByDepthDesc()), "/r/a/b/c /r/a/b /r/a/y /r/a /r/z"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := paths(l), original; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestDirentsEach(t *testing.T) {
+	l := Dirents{
+		&Dirent{name: "a"},
+		&Dirent{name: "b"},
+		&Dirent{name: "c"},
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var names []string
+		err := l.Each(func(de *Dirent) error {
+			names = append(names, de.Name())
+			return nil
+		})
+		ensureError(t, err)
+		if got, want := strings.Join(names, " "), "a b c"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("stops at error", func(t *testing.T) {
+		var names []string
+		err := l.Each(func(de *Dirent) error {
+			names = append(names, de.Name())
+			if de.Name() == "b" {
+				return fmt.Errorf("stop at %s", de.Name())
+			}
+			return nil
+		})
+		ensureError(t, err, "stop at b")
+		if got, want := strings.Join(names, " "), "a b"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestDirentsEachParallel(t *testing.T) {
+	var l Dirents
+	for i := 0; i < 100; i++ {
+		l = append(l, &Dirent{name: fmt.Sprintf("%02d", i)})
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var mu sync.Mutex
+		var names []string
+		var running, maxRunning int32
+		err := l.EachParallel(func(de *Dirent) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			mu.Lock()
+			names = append(names, de.Name())
+			mu.Unlock()
+			return nil
+		}, 4)
+		ensureError(t, err)
+		if got, want := len(names), len(l); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := maxRunning, int32(4); got > want {
+			t.Errorf("GOT: %v; WANT: <= %v", got, want)
+		}
+	})
+
+	t.Run("stops at error", func(t *testing.T) {
+		var count int32
+		err := l.EachParallel(func(de *Dirent) error {
+			atomic.AddInt32(&count, 1)
+			if de.Name() == "00" {
+				return fmt.Errorf("stop at %s", de.Name())
+			}
+			return nil
+		}, 1)
+		ensureError(t, err, "stop at 00")
+		// With a single goroutine, the error is recorded before the second
+		// Dirent is considered.
+		if got, want := atomic.LoadInt32(&count), int32(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestDirentsReduce(t *testing.T) {
+	l := Dirents{
+		&Dirent{name: "a", modeType: os.ModeDir, subtreeSize: 3},
+		&Dirent{name: "b", subtreeSize: 5},
+		&Dirent{name: "c", modeType: os.ModeDir, subtreeSize: 7},
+	}
+
+	t.Run("interface", func(t *testing.T) {
+		count := l.Reduce(0, func(acc interface{}, de *Dirent) interface{} {
+			if de.IsDir() {
+				return acc.(int) + 1
+			}
+			return acc
+		})
+		if got, want := count, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("int64", func(t *testing.T) {
+		total := l.ReduceInt64(1, func(acc int64, de *Dirent) int64 { return acc + de.SubtreeSize() })
+		if got, want := total, int64(16); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		names := l.ReduceString(">", func(acc string, de *Dirent) string { return acc + de.Name() })
+		if got, want := names, ">abc"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if got, want := Dirents(nil).ReduceString("initial", nil), "initial"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestDirentNewReader(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b")
+	defer cleanup()
+
+	de, err := NewDirent(filepath.Join(root, "a/b"))
+	ensureError(t, err)
+
+	r, closeFile, err := de.NewReader()
+	ensureError(t, err)
+	line, err := r.ReadString('\n')
+	ensureError(t, err)
+	if got, want := line, "a/b\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	ensureError(t, closeFile())
+	// A second close fails only when the first released the file descriptor.
+	ensureError(t, closeFile(), "already closed")
+
+	_, _, err = Dirent{path: filepath.Join(root, "missing")}.NewReader()
+	ensureError(t, err, "missing")
+}
+
+func TestDirentsTotalSize(t *testing.T) {
+	root, cleanup := setupTree(t, "a", "bb", "d/c")
+	defer cleanup()
+
+	children, err := ReadDirents(root, nil)
+	ensureError(t, err)
+
+	fi, err := os.Lstat(filepath.Join(root, "d"))
+	ensureError(t, err)
+
+	total, err := children.TotalSize()
+	ensureError(t, err)
+	// Each file contains its entry name followed by a newline, and the
+	// contents of the directory are not counted.
+	if got, want := total, int64(len("a\n")+len("bb\n"))+fi.Size(); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	missing := append(children, &Dirent{path: filepath.Join(root, "missing"), name: "missing"})
+	_, err = missing.TotalSize()
+	ensureError(t, err, "missing")
+}
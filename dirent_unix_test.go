@@ -0,0 +1,206 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirentPathComponents(t *testing.T) {
+	for pathname, want := range map[string]string{
+		"/":                 "/",
+		"/usr/local/bin":    "/|usr|local|bin",
+		"//usr//local/bin/": "/|usr|local|bin",
+		"a/b/../c":          "a|c",
+		"a":                 "a",
+		".":                 ".",
+		"":                  ".",
+	} {
+		de := &Dirent{path: pathname}
+		if got := strings.Join(de.PathComponents(), "|"); got != want {
+			t.Errorf("%q: GOT: %q; WANT: %q", pathname, got, want)
+		}
+	}
+}
+
+func TestDirentSpecialModeBits(t *testing.T) {
+	root, cleanup := setupTree(t, "setuid", "setgid", "sticky/", "plain")
+	defer cleanup()
+
+	bits := map[string]os.FileMode{
+		"setuid": os.ModeSetuid,
+		"setgid": os.ModeSetgid,
+		"sticky": os.ModeSticky,
+	}
+
+	for name, bit := range bits {
+		osPathname := filepath.Join(root, name)
+		if err := os.Chmod(osPathname, bit|0755); err != nil {
+			t.Logf("cannot set %s bit: %s", name, err)
+			continue
+		}
+	}
+
+	children, err := ReadDirents(root, nil)
+	ensureError(t, err)
+
+	for _, de := range children {
+		de := de
+		t.Run(de.Name(), func(t *testing.T) {
+			fi, err := os.Lstat(de.Path())
+			ensureError(t, err)
+			if bit, ok := bits[de.Name()]; ok && fi.Mode()&bit == 0 {
+				t.Skipf("not permitted to set %s bit", de.Name())
+			}
+			if got, want := de.CachedFileInfo(), os.FileInfo(nil); got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+
+			for _, tc := range []struct {
+				name  string
+				check func() (bool, error)
+			}{
+				{"setuid", de.IsSetuid},
+				{"setgid", de.IsSetgid},
+				{"sticky", de.IsSticky},
+			} {
+				got, err := tc.check()
+				ensureError(t, err)
+				if want := tc.name == de.Name(); got != want {
+					t.Errorf("%s: GOT: %v; WANT: %v", tc.name, got, want)
+				}
+			}
+
+			if de.CachedFileInfo() == nil {
+				t.Errorf("GOT: %v; WANT: cached information", nil)
+			}
+		})
+	}
+
+	de := &Dirent{path: filepath.Join(root, "missing"), name: "missing"}
+	_, err = de.IsSetuid()
+	ensureError(t, err, "missing")
+}
+
+func TestDirentFollowSymlinkChain(t *testing.T) {
+	root, cleanup := setupTree(t, "target")
+	defer cleanup()
+
+	// makeChain creates n symbolic links, each referring to the next, the last
+	// of which refers to the specified referent, and returns the first link.
+	makeChain := func(t *testing.T, prefix string, n int, referent string) *Dirent {
+		t.Helper()
+		for i := n; i > 0; i-- {
+			link := fmt.Sprintf("%s%02d", prefix, i)
+			ensureError(t, os.Symlink(referent, filepath.Join(root, link)))
+			referent = link
+		}
+		de, err := NewDirent(filepath.Join(root, referent))
+		ensureError(t, err)
+		return de
+	}
+
+	t.Run("longest chain", func(t *testing.T) {
+		resolved, err := makeChain(t, "ok", maxSymlinks, "target").FollowSymlink()
+		ensureError(t, err)
+		if got, want := resolved.Name(), "target"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("chain too long", func(t *testing.T) {
+		de := makeChain(t, "long", maxSymlinks+1, "target")
+		resolved, err := de.FollowSymlink()
+		if !errors.Is(err, ErrSymlinkLoop) {
+			t.Fatalf("GOT: %v; WANT: %v", err, ErrSymlinkLoop)
+		}
+		var sce *SymlinkCycleError
+		if !errors.As(err, &sce) {
+			t.Fatalf("GOT: %T; WANT: %T", err, sce)
+		}
+		if got, want := len(sce.Chain), maxSymlinks+1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := sce.Chain[0], de.Path(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, err, "long01 -> ", "long41")
+		if got, want := resolved.Path(), de.Path(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		ensureError(t, os.Symlink("cycle2", filepath.Join(root, "cycle1")))
+		ensureError(t, os.Symlink("cycle1", filepath.Join(root, "cycle2")))
+		de, err := NewDirent(filepath.Join(root, "cycle1"))
+		ensureError(t, err)
+		_, err = de.FollowSymlink()
+		if !errors.Is(err, ErrSymlinkLoop) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrSymlinkLoop)
+		}
+	})
+}
+
+func TestDirentResolveRelativeTarget(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/", "a/c", "outside/f")
+	defer cleanup()
+	walkRoot := filepath.Join(root, "a")
+
+	for _, link := range []struct{ pathname, referent string }{
+		{"a/b/up", "../c"},
+		{"a/b/dangling", "missing"},
+		{"a/b/out", "../../outside/f"},
+		{"a/b/abs", filepath.Join(root, "a", "c")},
+	} {
+		ensureError(t, os.Symlink(link.referent, filepath.Join(root, link.pathname)))
+	}
+
+	actual := make(map[string]string)
+	err := Walk(walkRoot, &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			if de.IsSymlink() {
+				target, err := de.ResolveRelativeTarget()
+				ensureError(t, err)
+				actual[de.Name()] = target
+			}
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	expected := map[string]string{
+		"up":       "c",
+		"dangling": filepath.Join("b", "missing"),
+		"out":      filepath.Join(root, "outside", "f"),
+		"abs":      "c",
+	}
+	for name, want := range expected {
+		if got := actual[name]; got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", name, got, want)
+		}
+	}
+
+	t.Run("without walk", func(t *testing.T) {
+		de, err := NewDirent(filepath.Join(root, "a", "b", "up"))
+		ensureError(t, err)
+		target, err := de.ResolveRelativeTarget()
+		ensureError(t, err)
+		if got, want := target, filepath.Join(root, "a", "c"); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("not symlink", func(t *testing.T) {
+		de, err := NewDirent(filepath.Join(root, "a", "c"))
+		ensureError(t, err)
+		_, err = de.ResolveRelativeTarget()
+		ensureError(t, err, "non-symlink")
+	})
+}
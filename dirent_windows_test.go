@@ -0,0 +1,38 @@
+package godirwalk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDirentPathComponents(t *testing.T) {
+	for pathname, want := range map[string]string{
+		`C:\`:                   `C:\`,
+		`C:\Users\a\b`:          `C:\|Users|a|b`,
+		`C:/Users/a/`:           `C:\|Users|a`,
+		`C:a\b`:                 `C:|a|b`,
+		`\\host\share\dir\file`: `\\host\share\|dir|file`,
+		`\Windows\System32`:     `\|Windows|System32`,
+		`a\b\..\c`:              `a|c`,
+		`a`:                     `a`,
+	} {
+		de := &Dirent{path: pathname}
+		if got := strings.Join(de.PathComponents(), "|"); got != want {
+			t.Errorf("%q: GOT: %q; WANT: %q", pathname, got, want)
+		}
+	}
+}
+
+func TestDirentIsUnderCase(t *testing.T) {
+	de := &Dirent{path: `C:\Users\A\b`}
+	for ancestor, want := range map[string]bool{
+		`c:\users`:    true,
+		`C:/USERS/a`:  true,
+		`C:\Users\Ab`: false,
+		`D:\Users`:    false,
+	} {
+		if got := de.IsUnder(ancestor); got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", ancestor, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build go1.16
+// +build go1.16
+
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FromOSDirEntries returns a Dirent for each of the provided entries, such as
+// those returned by os.ReadDir, of the specified directory. Entries returned by
+// ToOSDirEntries are converted back to the Dirents they were created from.
+//
+//    entries, err := os.ReadDir(osDirname)
+//    if err != nil {
+//        return err
+//    }
+//    dirents := godirwalk.FromOSDirEntries(osDirname, entries)
+func FromOSDirEntries(dir string, entries []os.DirEntry) Dirents {
+	dirents := make(Dirents, len(entries))
+	for i, entry := range entries {
+		if de, ok := entry.(direntEntry); ok {
+			dirents[i] = de.Dirent
+			continue
+		}
+		dirents[i] = &Dirent{
+			path:     filepath.Join(dir, entry.Name()),
+			name:     entry.Name(),
+			modeType: entry.Type() & os.ModeType,
+		}
+	}
+	return dirents
+}
+
+// ToOSDirEntries returns an os.DirEntry for each Dirent in the slice, whose
+// Info method obtains the file information of the Dirent as its Info method
+// does.
+func (l Dirents) ToOSDirEntries() []os.DirEntry {
+	entries := make([]os.DirEntry, len(l))
+	for i, de := range l {
+		entries[i] = direntEntry{de}
+	}
+	return entries
+}
+
+// direntEntry adapts a Dirent to the os.DirEntry interface.
+type direntEntry struct {
+	*Dirent
+}
+
+func (de direntEntry) Type() os.FileMode { return de.modeType }
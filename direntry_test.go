@@ -0,0 +1,71 @@
+//go:build go1.16
+// +build go1.16
+
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSDirEntries(t *testing.T) {
+	root, cleanup := setupTree(t, "a/", "b", "c/d")
+	defer cleanup()
+	haveSymlinks := os.Symlink("b", filepath.Join(root, "e")) == nil
+
+	entries, err := os.ReadDir(root)
+	ensureError(t, err)
+
+	dirents := FromOSDirEntries(root, entries)
+	if got, want := len(dirents), len(entries); got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, de := range dirents {
+		if got, want := de.Name(), entries[i].Name(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := de.Path(), filepath.Join(root, entries[i].Name()); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := de.ModeType(), entries[i].Type(); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", de.Name(), got, want)
+		}
+	}
+	if haveSymlinks {
+		if got, want := dirents[len(dirents)-1].IsSymlink(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		expected, err := ReadDirents(root, nil)
+		ensureError(t, err)
+
+		converted := expected.ToOSDirEntries()
+		for i, entry := range converted {
+			if got, want := entry.Name(), expected[i].Name(); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if got, want := entry.Type(), expected[i].ModeType(); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", entry.Name(), got, want)
+			}
+			if got, want := entry.IsDir(), expected[i].IsDir(); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", entry.Name(), got, want)
+			}
+			fi, err := entry.Info()
+			ensureError(t, err)
+			if got, want := fi.Mode()&os.ModeType, expected[i].ModeType(); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", entry.Name(), got, want)
+			}
+		}
+
+		actual := FromOSDirEntries(root, converted)
+		ensureDirentsMatch(t, actual, expected)
+		for i := range actual {
+			if actual[i] != expected[i] {
+				t.Errorf("GOT: %v; WANT: original Dirent %v", actual[i], expected[i])
+			}
+		}
+	})
+}
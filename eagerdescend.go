@@ -0,0 +1,51 @@
+package godirwalk
+
+import (
+	"errors"
+	"sync"
+)
+
+// errEagerHalted is returned by walk when an error in another goroutine has
+// halted a walk with EagerDescend set. Walk returns that error instead.
+var errEagerHalted = errors.New("walk halted")
+
+// eagerDescent is the state shared by the goroutines of a walk with
+// EagerDescend set.
+type eagerDescent struct {
+	// workers is a semaphore that limits the number of child directories
+	// walked in separate goroutines at once.
+	workers chan struct{}
+
+	mu  sync.Mutex
+	err error // first error that halted the walk
+}
+
+// eagerChild is a child directory being walked in a separate goroutine.
+type eagerChild struct {
+	osPathname string
+	dirent     *Dirent
+	err        error
+}
+
+// halt records the error that halted the walk, unless one already has.
+func (e *eagerDescent) halt(err error) {
+	if de, ok := err.(deferredError); ok {
+		err = de.err
+	}
+	if err == errEagerHalted {
+		return
+	}
+	e.mu.Lock()
+	if e.err == nil {
+		e.err = err
+	}
+	e.mu.Unlock()
+}
+
+// halted returns the error that halted the walk, or nil when the walk has not
+// been halted.
+func (e *eagerDescent) halted() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
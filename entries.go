@@ -0,0 +1,63 @@
+//go:build go1.23
+// +build go1.23
+
+package godirwalk
+
+import (
+	"errors"
+	"iter"
+)
+
+// errStopIteration is returned by the Callback used by Entries to stop the
+// walk when the consumer of the iterator stops ranging over it.
+var errStopIteration = errors.New("stop iteration")
+
+// Entries returns an iterator over the file system nodes in the hierarchy
+// rooted at the specified directory, visited in the same order as Walk would
+// visit them. Breaking out of a range loop over the iterator stops the walk and
+// releases its resources.
+//
+// The provided Options may be nil. When not nil, its Callback,
+// CallbackQueueSize, and EagerDescend fields are ignored, because the loop body
+// is not invoked concurrently. When no ErrorCallback is provided, the first
+// error halts the walk and is yielded along with a nil Dirent as the final
+// element. When an ErrorCallback is provided, it is invoked for errors as it
+// would be by Walk, and only errors it does not direct Walk to skip are
+// yielded.
+//
+//    for de, err := range godirwalk.Entries(osDirname, nil) {
+//        if err != nil {
+//            return err
+//        }
+//        fmt.Printf("%s %s\n", de.ModeType(), de.Path())
+//    }
+func Entries(osDirname string, options *Options) iter.Seq2[*Dirent, error] {
+	return func(yield func(*Dirent, error) bool) {
+		var o Options
+		if options != nil {
+			o = copyOptions(options)
+		}
+		o.CallbackQueueSize = 0 // yield must be called from this goroutine
+		o.EagerDescend = false
+
+		o.Callback = func(_ string, de *Dirent) error {
+			if !yield(de, nil) {
+				return errStopIteration
+			}
+			return nil
+		}
+
+		if errorCallback := o.ErrorCallback; errorCallback != nil {
+			o.ErrorCallback = func(osPathname string, err error) ErrorAction {
+				if err == errStopIteration {
+					return Halt // consumer stopped ranging; ErrorCallback may not skip it
+				}
+				return errorCallback(osPathname, err)
+			}
+		}
+
+		if err := Walk(osDirname, &o); err != nil && err != errStopIteration {
+			yield(nil, err)
+		}
+	}
+}
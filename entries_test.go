@@ -0,0 +1,89 @@
+//go:build go1.23
+// +build go1.23
+
+package godirwalk
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEntries(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		var actual []string
+		for de, err := range Entries(filepath.Join(testRoot, "d0"), nil) {
+			ensureError(t, err)
+			actual = append(actual, de.Path())
+		}
+
+		var expected []string
+		err := Walk(filepath.Join(testRoot, "d0"), &Options{
+			Callback: func(osPathname string, _ *Dirent) error {
+				expected = append(expected, osPathname)
+				return nil
+			},
+		})
+		ensureError(t, err)
+
+		ensureStringSlicesMatch(t, actual, expected)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var reads int
+		defer func(original func(string, []byte) (Dirents, error)) { readDirents = original }(readDirents)
+		readDirents = func(osDirname string, scratchBuffer []byte) (Dirents, error) {
+			reads++
+			return ReadDirents(osDirname, scratchBuffer)
+		}
+
+		var count int
+		for _, err := range Entries(filepath.Join(testRoot, "d0"), &Options{
+			ErrorCallback: func(_ string, _ error) ErrorAction { return SkipNode },
+		}) {
+			ensureError(t, err)
+			count++
+			if count == 2 {
+				break
+			}
+		}
+
+		if got, want := count, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		// Only the root directory should have been read.
+		if got, want := reads, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("eager descend", func(t *testing.T) {
+		// The loop body is not safe for concurrent use.
+		var actual []string
+		for de, err := range Entries(filepath.Join(testRoot, "d0"), &Options{EagerDescend: true}) {
+			ensureError(t, err)
+			actual = append(actual, de.Path())
+		}
+
+		var expected []string
+		for de, err := range Entries(filepath.Join(testRoot, "d0"), nil) {
+			ensureError(t, err)
+			expected = append(expected, de.Path())
+		}
+
+		ensureStringSlicesMatch(t, actual, expected)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var errs int
+		for de, err := range Entries(filepath.Join(testRoot, "missing"), nil) {
+			if de != nil {
+				t.Errorf("GOT: %v; WANT: nil", de)
+			}
+			ensureError(t, err, "missing")
+			errs++
+		}
+		if got, want := errs, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
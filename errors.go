@@ -0,0 +1,110 @@
+package godirwalk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// When the TypedErrors option is set, errors returned by the operating system
+// while Walk visits a file system node are provided to ErrorCallback as one of
+// the following types, each of which holds the Dirent of the node that caused
+// the error, and whose Error method returns the same message as the wrapped
+// error, which identifies the pathname of the node.
+//
+// When an error that originates within Walk and does not identify the pathname
+// of the node that caused it, such as ErrDirTooLarge, halts the walk, Walk
+// returns it wrapped with that pathname, so use errors.Is to identify it.
+// Errors that already identify the pathname, such as an *os.PathError, are
+// returned unchanged, as are errors returned by the upstream callback
+// functions.
+
+// PathError describes an operating system error encountered while accessing a
+// file system node, other than those described by PermissionError and
+// SymlinkCycleError.
+type PathError struct {
+	Dirent *Dirent
+	Err    *os.PathError
+}
+
+func (e *PathError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the wrapped *os.PathError.
+func (e *PathError) Unwrap() error { return e.Err }
+
+// PermissionError describes a file system node that could not be accessed
+// because permission was denied.
+type PermissionError struct {
+	Dirent *Dirent
+	Err    error
+}
+
+func (e *PermissionError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the wrapped error.
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// SymlinkCycleError describes a symbolic link that could not be resolved
+// because its referent is, or refers through, a cycle of symbolic links, or a
+// chain of symbolic links too long to follow.
+type SymlinkCycleError struct {
+	Dirent *Dirent
+	Err    error
+
+	// Chain holds the pathname of each symbolic link followed, in order,
+	// when the error is returned by FollowSymlink, and is otherwise nil.
+	Chain []string
+}
+
+func (e *SymlinkCycleError) Error() string {
+	if len(e.Chain) == 0 {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + strings.Join(e.Chain, " -> ")
+}
+
+// Unwrap returns the wrapped error.
+func (e *SymlinkCycleError) Unwrap() error { return e.Err }
+
+// ErrSymlinkLoop is wrapped by the *SymlinkCycleError FollowSymlink returns
+// when a symbolic link refers through a chain of more than 40 symbolic links,
+// which is the most Linux follows before failing with ELOOP.
+var ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// nodeError returns err wrapped in the error type describing its failure mode,
+// or err unchanged when it is not an operating system error.
+func nodeError(de *Dirent, err error) error {
+	pe, ok := err.(*os.PathError)
+	if !ok {
+		return err
+	}
+	if os.IsPermission(pe) {
+		return &PermissionError{Dirent: de, Err: pe}
+	}
+	if isSymlinkLoop(pe.Err) {
+		return &SymlinkCycleError{Dirent: de, Err: pe}
+	}
+	return &PathError{Dirent: de, Err: pe}
+}
+
+// nodeError returns err wrapped in the error type describing its failure mode
+// when TypedErrors is set, or err unchanged otherwise.
+func (w *walker) nodeError(de *Dirent, err error) error {
+	if !w.TypedErrors {
+		return err
+	}
+	return nodeError(de, err)
+}
+
+// wrapPath returns err annotated with the pathname of the node that caused it,
+// for errors that originate within Walk rather than in an upstream callback,
+// or err unchanged when it already identifies the pathname.
+func wrapPath(err error, osPathname string) error {
+	var pe *os.PathError
+	var le *os.LinkError
+	if errors.As(err, &pe) || errors.As(err, &le) {
+		return err
+	}
+	return fmt.Errorf("godirwalk: %w: %s", err, osPathname)
+}
@@ -0,0 +1,165 @@
+package godirwalk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestNodeError(t *testing.T) {
+	de := &Dirent{name: "name", path: "dir/name"}
+
+	t.Run("permission", func(t *testing.T) {
+		pe := &os.PathError{Op: "open", Path: "dir/name", Err: syscall.EACCES}
+		err, ok := nodeError(de, pe).(*PermissionError)
+		if !ok {
+			t.Fatalf("GOT: %T; WANT: %T", err, &PermissionError{})
+		}
+		if got, want := err.Dirent, de; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := err.Error(), pe.Error(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := err.Unwrap(), error(pe); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("path", func(t *testing.T) {
+		pe := &os.PathError{Op: "open", Path: "dir/name", Err: syscall.ENOENT}
+		err, ok := nodeError(de, pe).(*PathError)
+		if !ok {
+			t.Fatalf("GOT: %T; WANT: %T", err, &PathError{})
+		}
+		if got, want := err.Err, pe; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("not an operating system error", func(t *testing.T) {
+		if got, want := nodeError(de, filepath.SkipDir), filepath.SkipDir; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkErrorTypes(t *testing.T) {
+	root, cleanup := setupTree(t, "d/")
+	defer cleanup()
+	if err := os.Symlink("missing", filepath.Join(root, "dangling")); err != nil {
+		t.Skip(err)
+	}
+	ensureError(t, os.Symlink("loop", filepath.Join(root, "loop")))
+
+	var pathErrors, cycleErrors []string
+	err := Walk(root, &Options{
+		TypedErrors:         true,
+		FollowSymbolicLinks: true,
+		OnDanglingSymlink:   DanglingSymlinkReport,
+		Callback:            func(string, *Dirent) error { return nil },
+		ErrorCallback: func(osPathname string, err error) ErrorAction {
+			switch e := err.(type) {
+			case *PathError:
+				pathErrors = append(pathErrors, e.Dirent.Name())
+			case *SymlinkCycleError:
+				cycleErrors = append(cycleErrors, e.Dirent.Name())
+			default:
+				t.Errorf("GOT: %T; WANT: typed error for %q", err, osPathname)
+			}
+			return SkipNode
+		},
+	})
+	ensureError(t, err)
+	ensureStringSlicesMatch(t, pathErrors, []string{"dangling"})
+	ensureStringSlicesMatch(t, cycleErrors, []string{"loop"})
+
+	t.Run("untyped", func(t *testing.T) {
+		err := Walk(root, &Options{
+			OnDanglingSymlink: DanglingSymlinkReport,
+			Callback:          func(string, *Dirent) error { return nil },
+			ErrorCallback: func(osPathname string, err error) ErrorAction {
+				if _, ok := err.(*os.PathError); !ok {
+					t.Errorf("GOT: %T; WANT: %T", err, &os.PathError{})
+				}
+				if !os.IsNotExist(err) {
+					t.Errorf("GOT: %v; WANT: not exist error", err)
+				}
+				return SkipNode
+			},
+		})
+		ensureError(t, err)
+	})
+}
+
+func TestWalkWrapsErrors(t *testing.T) {
+	root, cleanup := setupTree(t, "d/")
+	defer cleanup()
+	osDanglingname := filepath.Join(root, "dangling")
+	if err := os.Symlink("missing", osDanglingname); err != nil {
+		t.Skip(err)
+	}
+
+	t.Run("path error", func(t *testing.T) {
+		// The error already identifies the pathname, so it is not wrapped.
+		err := Walk(root, &Options{
+			OnDanglingSymlink: DanglingSymlinkReport,
+			Callback:          func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, osDanglingname)
+		if !os.IsNotExist(err) {
+			t.Errorf("GOT: %v; WANT: not exist error", err)
+		}
+		if got, want := strings.Count(err.Error(), osDanglingname), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		err := Walk(root, &Options{
+			TypedErrors:       true,
+			OnDanglingSymlink: DanglingSymlinkReport,
+			Callback:          func(string, *Dirent) error { return nil },
+		})
+		var pe *PathError
+		if !errors.As(err, &pe) {
+			t.Fatalf("GOT: %T; WANT: %T", err, pe)
+		}
+		if got, want := pe.Dirent.Name(), "dangling"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("GOT: %v; WANT: %v", err, os.ErrNotExist)
+		}
+	})
+
+	t.Run("internal", func(t *testing.T) {
+		// The error does not identify the pathname, so it is wrapped.
+		err := Walk(root, &Options{
+			MaxEntriesPerDir: 1,
+			Callback:         func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "godirwalk: ", ": "+root)
+		if !errors.Is(err, ErrDirTooLarge) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrDirTooLarge)
+		}
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		errCallback := errors.New("callback error")
+		err := Walk(root, &Options{
+			Callback: func(osPathname string, _ *Dirent) error {
+				if osPathname == osDanglingname {
+					return errCallback
+				}
+				return nil
+			},
+		})
+		if got, want := err, errCallback; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
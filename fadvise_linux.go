@@ -0,0 +1,19 @@
+package godirwalk
+
+import "os"
+
+// fadvSequential is the POSIX_FADV_SEQUENTIAL posix_fadvise(2) advice, which
+// has the same value on every architecture. The value of POSIX_FADV_NOREUSE
+// differs by architecture, so fadvNoreuse is declared along with fadvise.
+const fadvSequential = 2
+
+// adviseSequential advises the kernel, using posix_fadvise(2), that the open
+// directory will be read sequentially, which sets the read-ahead window of the
+// directory handle. Because the advice is merely a hint, any error is ignored.
+func adviseSequential(dh *os.File) { fadvise(dh, fadvSequential) }
+
+// adviseNoReuse advises the kernel, using posix_fadvise(2), that the contents
+// of the open file will be accessed only once, so they need not displace
+// other pages in the page cache. Because the advice is merely a hint, any
+// error is ignored.
+func adviseNoReuse(fh *os.File) { fadvise(fh, fadvNoreuse) }
@@ -0,0 +1,14 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+const fadvNoreuse = 5 // POSIX_FADV_NOREUSE
+
+// fadvise gives the advice for the entire open file using fadvise64_64(2),
+// whose 64-bit offset and length are each split across two registers.
+func fadvise(fh *os.File, advice uintptr) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_FADVISE64_64, fh.Fd(), 0, 0, 0, 0, advice)
+}
@@ -0,0 +1,16 @@
+// +build linux
+// +build amd64 arm64 loong64 mips64 mips64le ppc64 ppc64le riscv64
+
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+const fadvNoreuse = 5 // POSIX_FADV_NOREUSE
+
+// fadvise gives the advice for the entire open file using fadvise64(2).
+func fadvise(fh *os.File, advice uintptr) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_FADVISE64, fh.Fd(), 0, 0, advice, 0, 0)
+}
@@ -0,0 +1,15 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+const fadvNoreuse = 5 // POSIX_FADV_NOREUSE
+
+// fadvise gives the advice for the entire open file using
+// arm_fadvise64_64(2), which takes the advice before the 64-bit offset and
+// length, so each of them is aligned to a pair of registers.
+func fadvise(fh *os.File, advice uintptr) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_ARM_FADVISE64_64, fh.Fd(), advice, 0, 0, 0, 0)
+}
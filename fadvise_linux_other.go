@@ -0,0 +1,13 @@
+// +build linux
+// +build !386,!amd64,!arm,!arm64,!loong64,!mips64
+// +build !mips64le,!ppc64,!ppc64le,!riscv64,!s390x
+
+package godirwalk
+
+import "os"
+
+const fadvNoreuse = 5 // POSIX_FADV_NOREUSE
+
+// fadvise does nothing, because this library does not know how to invoke
+// posix_fadvise(2) on this architecture.
+func fadvise(_ *os.File, _ uintptr) {}
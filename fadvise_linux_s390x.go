@@ -0,0 +1,13 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+const fadvNoreuse = 7 // POSIX_FADV_NOREUSE
+
+// fadvise gives the advice for the entire open file using fadvise64(2).
+func fadvise(fh *os.File, advice uintptr) {
+	_, _, _ = syscall.Syscall6(syscall.SYS_FADVISE64, fh.Fd(), 0, 0, advice, 0, 0)
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package godirwalk
+
+import "os"
+
+// adviseSequential does nothing, because this library only knows how to advise
+// the kernel of access patterns on Linux.
+func adviseSequential(_ *os.File) {}
+
+// adviseNoReuse does nothing, because this library only knows how to advise
+// the kernel of access patterns on Linux.
+func adviseNoReuse(_ *os.File) {}
@@ -0,0 +1,74 @@
+package godirwalk
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// The fscrypt ioctl(2) requests and values this library refers to, from
+// linux/fscrypt.h. Both requests are _IOWR, whose encoding is the same on
+// every architecture for arguments of these sizes.
+const (
+	fsIocGetEncryptionPolicyEx   = 0xc0096616 // _IOWR('f', 22, __u8[9])
+	fsIocGetEncryptionKeyStatus  = 0xc0806626 // _IOWR('f', 26, struct fscrypt_get_key_status_arg)
+	fscryptPolicyV1              = 0
+	fscryptPolicyV2              = 2
+	fscryptKeySpecTypeDescriptor = 1
+	fscryptKeySpecTypeIdentifier = 2
+	fscryptKeyStatusAbsent       = 1
+)
+
+// fscryptGetPolicyExArg mirrors struct fscrypt_get_policy_ex_arg, whose policy
+// is large enough to hold either version of struct fscrypt_policy.
+type fscryptGetPolicyExArg struct {
+	Size   uint64
+	Policy [24]byte
+}
+
+// fscryptGetKeyStatusArg mirrors struct fscrypt_get_key_status_arg.
+type fscryptGetKeyStatusArg struct {
+	KeySpecType uint32
+	_           uint32
+	KeySpec     [32]byte
+	_           [6]uint32
+	Status      uint32
+	StatusFlags uint32
+	UserCount   uint32
+	_           [13]uint32
+}
+
+// isLockedEncrypted returns true when the specified directory is protected by
+// an fscrypt policy whose key has not been added to the file system, so the
+// names of its children are presented as encoded ciphertext. Keys provided
+// through a process keyring, which only v1 policies permit, are not visible to
+// the file system, so directories unlocked that way are also reported as
+// locked. Any error, including the kernel or file system not supporting
+// fscrypt, is treated as the directory not being locked.
+func isLockedEncrypted(osDirname string) bool {
+	fd, err := syscall.Open(osDirname, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return false // let reading the directory report any error
+	}
+	defer syscall.Close(fd)
+
+	policy := fscryptGetPolicyExArg{Size: uint64(len(fscryptGetPolicyExArg{}.Policy))}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), fsIocGetEncryptionPolicyEx, uintptr(unsafe.Pointer(&policy))); errno != 0 {
+		return false // ENODATA when the directory is not encrypted
+	}
+
+	var status fscryptGetKeyStatusArg
+	switch policy.Policy[0] {
+	case fscryptPolicyV1:
+		status.KeySpecType = fscryptKeySpecTypeDescriptor
+		copy(status.KeySpec[:8], policy.Policy[4:12]) // master_key_descriptor
+	case fscryptPolicyV2:
+		status.KeySpecType = fscryptKeySpecTypeIdentifier
+		copy(status.KeySpec[:16], policy.Policy[8:24]) // master_key_identifier
+	default:
+		return false
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), fsIocGetEncryptionKeyStatus, uintptr(unsafe.Pointer(&status))); errno != 0 {
+		return false // kernel predates the file system keyring
+	}
+	return status.Status == fscryptKeyStatusAbsent
+}
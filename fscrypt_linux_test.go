@@ -0,0 +1,40 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkSkipLockedEncryptedDirs(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "d")
+	defer cleanup()
+
+	var actual []string
+	err := Walk(root, &Options{
+		SkipLockedEncryptedDirs: true,
+		ScratchBuffer:           testScratchBuffer,
+		Callback: func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		},
+		ErrorCallback: func(osPathname string, err error) ErrorAction {
+			t.Errorf("GOT: %q: %v; WANT: no error", osPathname, err)
+			return Halt
+		},
+	})
+	ensureError(t, err)
+
+	// Unencrypted directories are not locked.
+	if got, want := strings.Join(actual, " "), ". a a/b a/b/c d"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		if got, want := isLockedEncrypted(filepath.Join(root, "missing")), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
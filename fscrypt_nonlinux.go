@@ -0,0 +1,7 @@
+// +build !linux
+
+package godirwalk
+
+// isLockedEncrypted always returns false, because fscrypt is only available on
+// Linux.
+func isLockedEncrypted(_ string) bool { return false }
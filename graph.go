@@ -0,0 +1,178 @@
+package godirwalk
+
+import (
+	"container/heap"
+	"fmt"
+	"path/filepath"
+)
+
+// DirGraph is the file system hierarchy visited by a walk, represented as a
+// graph whose nodes are the visited Dirents, keyed by their pathnames, and
+// whose edges connect each directory to its immediate descendants.
+type DirGraph struct {
+	root     string
+	nodes    map[string]*Dirent
+	parents  map[string]string
+	children map[string][]string // in the order they were visited
+}
+
+// WalkGraph walks the file system hierarchy rooted at the specified directory,
+// and returns a DirGraph of the nodes it visits, so that the hierarchy may be
+// queried repeatedly without walking it again. Nodes are keyed by the
+// pathnames passed to the callback by Walk.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    g, err := godirwalk.WalkGraph(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    from, to := filepath.Join(osDirname, "a/b"), filepath.Join(osDirname, "c")
+//    path, err := g.ShortestPath(from, to)
+func WalkGraph(root string, opts *Options) (*DirGraph, error) {
+	options := HelperOptions(opts)
+
+	g := &DirGraph{
+		nodes:    make(map[string]*Dirent),
+		parents:  make(map[string]string),
+		children: make(map[string][]string),
+	}
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		node := *de
+		if len(g.nodes) == 0 {
+			g.root = osPathname
+		} else if parent := filepath.Dir(osPathname); g.nodes[parent] != nil {
+			g.parents[osPathname] = parent
+			g.children[parent] = append(g.children[parent], osPathname)
+		}
+		g.nodes[osPathname] = &node
+		return nil
+	}
+
+	if err := Walk(root, &options); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Root returns the pathname of the node at which the walk started.
+func (g *DirGraph) Root() string { return g.root }
+
+// Node returns the Dirent with the specified pathname, or nil when the graph
+// does not contain it.
+func (g *DirGraph) Node(pathname string) *Dirent { return g.nodes[pathname] }
+
+// Len returns the number of nodes in the graph.
+func (g *DirGraph) Len() int { return len(g.nodes) }
+
+// BFS returns the Dirents of start and each of its descendants in
+// breadth-first order, or nil when the graph does not contain start.
+func (g *DirGraph) BFS(start string) []*Dirent {
+	if g.nodes[start] == nil {
+		return nil
+	}
+	var dirents []*Dirent
+	for queue := []string{start}; len(queue) > 0; queue = queue[1:] {
+		dirents = append(dirents, g.nodes[queue[0]])
+		queue = append(queue, g.children[queue[0]]...)
+	}
+	return dirents
+}
+
+// DFS returns the Dirents of start and each of its descendants in depth-first
+// order, which is the order Walk visits them, or nil when the graph does not
+// contain start.
+func (g *DirGraph) DFS(start string) []*Dirent {
+	if g.nodes[start] == nil {
+		return nil
+	}
+	var dirents []*Dirent
+	for stack := []string{start}; len(stack) > 0; {
+		pathname := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dirents = append(dirents, g.nodes[pathname])
+		children := g.children[pathname]
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i]) // pushed in reverse so first child is popped first
+		}
+	}
+	return dirents
+}
+
+// ShortestPath returns the pathnames of the nodes on the shortest path from src
+// to dst, inclusive, found using Dijkstra's algorithm with a weight of one for
+// each edge. Edges are traversed in either direction, so the path between two
+// nodes ascends to their nearest common ancestor, then descends to dst.
+func (g *DirGraph) ShortestPath(src, dst string) ([]string, error) {
+	if g.nodes[src] == nil {
+		return nil, fmt.Errorf("cannot find shortest path: %q not in graph", src)
+	}
+	if g.nodes[dst] == nil {
+		return nil, fmt.Errorf("cannot find shortest path: %q not in graph", dst)
+	}
+
+	distances := map[string]int{src: 0}
+	previous := make(map[string]string)
+	pq := &distanceHeap{{pathname: src}}
+
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(distanceItem)
+		if item.pathname == dst {
+			break
+		}
+		if item.distance > distances[item.pathname] {
+			continue // stale entry superseded by a shorter distance
+		}
+		neighbors := g.children[item.pathname]
+		if parent, ok := g.parents[item.pathname]; ok {
+			neighbors = append(neighbors[:len(neighbors):len(neighbors)], parent)
+		}
+		for _, neighbor := range neighbors {
+			distance := item.distance + 1
+			if d, ok := distances[neighbor]; ok && d <= distance {
+				continue
+			}
+			distances[neighbor] = distance
+			previous[neighbor] = item.pathname
+			heap.Push(pq, distanceItem{pathname: neighbor, distance: distance})
+		}
+	}
+
+	if _, ok := distances[dst]; !ok {
+		return nil, fmt.Errorf("cannot find shortest path: no path from %q to %q", src, dst)
+	}
+
+	path := make([]string, distances[dst]+1)
+	for i, pathname := len(path)-1, dst; i >= 0; i-- {
+		path[i] = pathname
+		pathname = previous[pathname]
+	}
+	return path, nil
+}
+
+// distanceItem is a node and its tentative distance from the source node of
+// ShortestPath.
+type distanceItem struct {
+	pathname string
+	distance int
+}
+
+// distanceHeap is a min-heap of distanceItems ordered by distance.
+type distanceHeap []distanceItem
+
+func (h distanceHeap) Len() int { return len(h) }
+
+func (h distanceHeap) Less(i, j int) bool { return h[i].distance < h[j].distance }
+
+func (h distanceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *distanceHeap) Push(x interface{}) { *h = append(*h, x.(distanceItem)) }
+
+func (h *distanceHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,79 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkGraph(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e/f/", "g")
+	defer cleanup()
+
+	g, err := WalkGraph(root, nil)
+	ensureError(t, err)
+
+	abs := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+
+	rels := func(dirents []*Dirent) string {
+		var names []string
+		for _, de := range dirents {
+			rel, err := filepath.Rel(root, de.Path())
+			ensureError(t, err)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return strings.Join(names, " ")
+	}
+
+	if got, want := g.Len(), 8; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := g.Root(), root; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := g.Node(abs("a/b")).IsDir(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("BFS", func(t *testing.T) {
+		if got, want := rels(g.BFS(root)), ". a e g a/b a/d e/f a/b/c"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := rels(g.BFS(abs("a"))), "a a/b a/d a/b/c"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got := g.BFS(abs("missing")); got != nil {
+			t.Errorf("GOT: %v; WANT: %v", got, nil)
+		}
+	})
+
+	t.Run("DFS", func(t *testing.T) {
+		if got, want := rels(g.DFS(root)), ". a a/b a/b/c a/d e e/f g"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got := g.DFS(abs("missing")); got != nil {
+			t.Errorf("GOT: %v; WANT: %v", got, nil)
+		}
+	})
+
+	t.Run("ShortestPath", func(t *testing.T) {
+		for _, tc := range []struct{ src, dst, want string }{
+			{"a/b/c", "e/f", "a/b/c a/b a . e e/f"},
+			{".", "a/d", ". a a/d"},
+			{"a/d", "a/d", "a/d"},
+		} {
+			path, err := g.ShortestPath(abs(tc.src), abs(tc.dst))
+			ensureError(t, err)
+			for i := range path {
+				path[i], _ = filepath.Rel(root, path[i])
+				path[i] = filepath.ToSlash(path[i])
+			}
+			if got := strings.Join(path, " "); got != tc.want {
+				t.Errorf("GOT: %q; WANT: %q", got, tc.want)
+			}
+		}
+
+		_, err := g.ShortestPath(abs("a"), abs("missing"))
+		ensureError(t, err, "not in graph")
+	})
+}
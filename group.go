@@ -0,0 +1,54 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// GroupByTopLevel walks the file system hierarchy rooted at the specified
+// directory, and returns its descendants grouped by the name of the immediate
+// descendant of the root they are found beneath, such as for sharding a
+// repository by top-level directory. Each group holds the immediate
+// descendant followed by its own descendants, in the order Walk visits them,
+// so a non-directory immediate descendant is the only member of its group. The
+// root itself is not a member of any group, and the Dirents of each group are
+// copies that may be retained.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    groups, err := godirwalk.GroupByTopLevel(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    for name, group := range groups {
+//        fmt.Printf("%s: %d nodes\n", name, len(group))
+//    }
+func GroupByTopLevel(osDirname string, opts *Options) (map[string]Dirents, error) {
+	options := HelperOptions(opts)
+
+	osDirname = filepath.Clean(osDirname)
+	groups := make(map[string]Dirents)
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		rel, err := filepath.Rel(osDirname, osPathname)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil // the root belongs to no group
+		}
+		name := rel
+		if i := strings.IndexRune(rel, filepath.Separator); i >= 0 {
+			name = rel[:i]
+		}
+		node := *de
+		groups[name] = append(groups[name], &node)
+		return nil
+	}
+
+	if err := Walk(osDirname, &options); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
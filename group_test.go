@@ -0,0 +1,51 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGroupByTopLevel(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e", "f/", "g/h/i/j", "g/k")
+	defer cleanup()
+
+	groups, err := GroupByTopLevel(root, nil)
+	ensureError(t, err)
+
+	expected := map[string]string{
+		"a": "a a/b a/b/c a/d",
+		"e": "e",
+		"f": "f",
+		"g": "g g/h g/h/i g/h/i/j g/k",
+	}
+	if got, want := len(groups), len(expected); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	for name, want := range expected {
+		var actual []string
+		for _, de := range groups[name] {
+			rel, err := filepath.Rel(root, de.Path())
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		if got := strings.Join(actual, " "); got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", name, got, want)
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		empty, cleanup := setupTree(t)
+		defer cleanup()
+		groups, err := GroupByTopLevel(empty, nil)
+		ensureError(t, err)
+		if got, want := len(groups), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := GroupByTopLevel(filepath.Join(root, "missing"), nil)
+		ensureError(t, err, "missing")
+	})
+}
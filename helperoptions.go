@@ -0,0 +1,65 @@
+package godirwalk
+
+// HelperOptions returns the Options that functions such as CopyTree, DiffTrees,
+// MerkleHash, and WriteManifest, which walk a hierarchy to build a result,
+// provide to Walk, given the Options provided to them, which may be nil.
+//
+// Those functions gather their results from callbacks that are not safe for
+// concurrent use, expect Callback to be invoked for every node including
+// directories, and identify nodes by their pathnames relative to the root. So
+// only the fields that control which nodes are visited, how directories are
+// read, and how errors are handled are copied. All other fields are left as
+// their zero values, including every callback other than ErrorCallback, the
+// options that change the order in which nodes are visited or the pathnames
+// reported for them, and the options that invoke callbacks concurrently, resume
+// from a checkpoint, or report nodes other than those in the hierarchy. Nodes
+// are therefore visited one at a time, in lexical order. RequireDir is always
+// set, because those results describe the hierarchy below a directory, so the
+// functions return an error for a root that is not one.
+//
+// The returned Options may be modified by the caller, and are not used by the
+// walk of any other function.
+func HelperOptions(opts *Options) Options {
+	if opts == nil {
+		return Options{RequireDir: true}
+	}
+	return Options{
+		ErrorCallback:              opts.ErrorCallback,
+		TypedErrors:                opts.TypedErrors,
+		SilentPermissionErrors:     opts.SilentPermissionErrors,
+		FollowSymbolicLinks:        opts.FollowSymbolicLinks,
+		OnDanglingSymlink:          opts.OnDanglingSymlink,
+		AnnotateSymlinkTargets:     opts.AnnotateSymlinkTargets,
+		CountSymlinkHops:           opts.CountSymlinkHops,
+		Stats:                      opts.Stats,
+		RequireDir:                 true,
+		ResolveRootSymlink:         opts.ResolveRootSymlink,
+		RetryOnStale:               opts.RetryOnStale,
+		ScratchBuffer:              opts.ScratchBuffer,
+		MaxOpenDirs:                opts.MaxOpenDirs,
+		MaxEntriesPerDir:           opts.MaxEntriesPerDir,
+		MaxAllocBytes:              opts.MaxAllocBytes,
+		ParallelDirs:               opts.ParallelDirs,
+		SkipNetworkFilesystems:     opts.SkipNetworkFilesystems,
+		BoundaryMarkers:            opts.BoundaryMarkers,
+		SkipSubvolumes:             opts.SkipSubvolumes,
+		SkipLockedEncryptedDirs:    opts.SkipLockedEncryptedDirs,
+		SkipZFSSnapshots:           opts.SkipZFSSnapshots,
+		IncludeZFSSnapshots:        opts.IncludeZFSSnapshots,
+		PreloadFileInfo:            opts.PreloadFileInfo,
+		StatMask:                   opts.StatMask,
+		UseIOURing:                 opts.UseIOURing,
+		BypassAttrCache:            opts.BypassAttrCache,
+		SequentialHint:             opts.SequentialHint,
+		EntryDecoder:               opts.EntryDecoder,
+		NoReuseHint:                opts.NoReuseHint,
+		NoATime:                    opts.NoATime,
+		AccumulateSubtreeSizes:     opts.AccumulateSubtreeSizes,
+		FailFast:                   opts.FailFast,
+		DeferSiblingErrors:         opts.DeferSiblingErrors,
+		ExcludeRegexp:              opts.ExcludeRegexp,
+		IncludeRegexp:              opts.IncludeRegexp,
+		ShouldVisit:                opts.ShouldVisit,
+		CheckTimeMachineExclusions: opts.CheckTimeMachineExclusions,
+	}
+}
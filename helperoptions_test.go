@@ -0,0 +1,172 @@
+package godirwalk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestHelperOptions(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		if got := HelperOptions(nil); got.Callback != nil || got.ErrorCallback != nil || !got.RequireDir {
+			t.Errorf("GOT: %v; WANT: zero Options with RequireDir", got)
+		}
+	})
+
+	t.Run("copies safe fields", func(t *testing.T) {
+		exclude := regexp.MustCompile("^skip")
+		got := HelperOptions(&Options{
+			ErrorCallback: func(_ string, _ error) ErrorAction { return SkipNode },
+			ExcludeRegexp: exclude,
+			MaxOpenDirs:   4,
+		})
+		if got.ErrorCallback == nil {
+			t.Errorf("GOT: nil ErrorCallback; WANT: copied")
+		}
+		if got.ExcludeRegexp != exclude {
+			t.Errorf("GOT: %v; WANT: %v", got.ExcludeRegexp, exclude)
+		}
+		if got, want := got.MaxOpenDirs, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("clears other fields", func(t *testing.T) {
+		got := HelperOptions(&Options{
+			Callback:             func(_ string, _ *Dirent) error { return nil },
+			PostChildrenCallback: func(_ string, _ *Dirent) error { return nil },
+			NameTransform:        func(name string) string { return name },
+			TransformPath:        func(osPathname string, _ *Dirent) string { return osPathname },
+			Unsorted:             true,
+			EagerDescend:         true,
+			CallbackQueueSize:    8,
+			CheckpointFile:       "checkpoint",
+			CwdRelative:          true,
+			GlobalSizeOrder:      true,
+		})
+		if got.Callback != nil || got.PostChildrenCallback != nil || got.NameTransform != nil || got.TransformPath != nil {
+			t.Errorf("GOT: callbacks copied; WANT: cleared")
+		}
+		if got.Unsorted || got.EagerDescend || got.CallbackQueueSize != 0 || got.CheckpointFile != "" || got.CwdRelative || got.GlobalSizeOrder {
+			t.Errorf("GOT: %+v; WANT: fields cleared", got)
+		}
+	})
+}
+
+func TestHelpersIgnoreEagerDescend(t *testing.T) {
+	// The helpers gather their results with callbacks that are not safe for
+	// concurrent use, so run with the race detector to see them fail.
+	root, cleanup := setupTree(t, "a/b/c/", "a/b/f", "d/e/g", "d/h", "i/j/", "k")
+	defer cleanup()
+
+	eager := &Options{EagerDescend: true}
+
+	t.Run("WalkGraph", func(t *testing.T) {
+		g, err := WalkGraph(root, eager)
+		ensureError(t, err)
+		if got, want := g.Len(), 12; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("MerkleHash", func(t *testing.T) {
+		got, err := MerkleHash(root, eager, sha256.New)
+		ensureError(t, err)
+		want, err := MerkleHash(root, nil, sha256.New)
+		ensureError(t, err)
+		if !bytes.Equal(got, want) {
+			t.Errorf("GOT: %x; WANT: %x", got, want)
+		}
+	})
+
+	t.Run("ReadDirentsRecursive", func(t *testing.T) {
+		descendants, err := ReadDirentsRecursive(root, eager)
+		ensureError(t, err)
+		if got, want := len(descendants), 11; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("GroupByTopLevel", func(t *testing.T) {
+		groups, err := GroupByTopLevel(root, eager)
+		ensureError(t, err)
+		if got, want := len(groups), 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("DiffTrees", func(t *testing.T) {
+		diff, err := DiffTrees(root, root, &DiffOptions{Options: *eager})
+		ensureError(t, err)
+		if got := len(diff.Added) + len(diff.Removed) + len(diff.Modified); got != 0 {
+			t.Errorf("GOT: %v; WANT: 0", got)
+		}
+	})
+
+	t.Run("WalkPaged", func(t *testing.T) {
+		var count int
+		err := WalkPaged(root, 3, eager, func(page Dirents) error {
+			count += len(page)
+			return nil
+		})
+		ensureError(t, err)
+		if got, want := count, 11; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestHelpersIgnoreGlobalSizeOrder(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/f", "d/g", "k")
+	defer cleanup()
+
+	// Without a callback for each directory, the hash of each directory
+	// cannot be computed.
+	got, err := MerkleHash(root, &Options{GlobalSizeOrder: true}, sha256.New)
+	ensureError(t, err)
+	want, err := MerkleHash(root, nil, sha256.New)
+	ensureError(t, err)
+	if !bytes.Equal(got, want) {
+		t.Errorf("GOT: %x; WANT: %x", got, want)
+	}
+
+	g, err := WalkGraph(root, &Options{GlobalSizeOrder: true})
+	ensureError(t, err)
+	if got, want := g.Len(), 7; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestHelpersRequireDir(t *testing.T) {
+	root, cleanup := setupTree(t, "f")
+	defer cleanup()
+	file := filepath.Join(root, "f")
+
+	t.Run("MerkleHash", func(t *testing.T) {
+		_, err := MerkleHash(file, nil, sha256.New)
+		ensureError(t, err, "cannot Walk non-directory")
+	})
+
+	t.Run("WriteMakefile", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := WriteMakefile(&buf, file, &Options{RequireDir: false})
+		ensureError(t, err, "cannot Walk non-directory")
+	})
+
+	t.Run("WalkGraph", func(t *testing.T) {
+		_, err := WalkGraph(file, nil)
+		ensureError(t, err, "cannot Walk non-directory")
+	})
+
+	t.Run("CopyTree", func(t *testing.T) {
+		err := CopyTree(file, filepath.Join(root, "g"), nil)
+		ensureError(t, err, "cannot Walk non-directory")
+	})
+
+	t.Run("DiffTrees", func(t *testing.T) {
+		_, err := DiffTrees(file, file, nil)
+		ensureError(t, err, "cannot Walk non-directory")
+	})
+}
@@ -0,0 +1,167 @@
+package godirwalk
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern read from an ignore file.
+type ignoreRule struct {
+	segments []string // pattern split at solidus, where "**" matches any number of segments
+	negate   bool     // pattern began with '!', re-including matching nodes
+	dirOnly  bool     // pattern ended with '/', matching only directories
+}
+
+// LoadIgnoreFile reads an ignore file using the syntax shared by .gitignore,
+// .dockerignore, and .npmignore files, and returns a predicate that returns
+// true for each Dirent the file excludes. Patterns are matched against the
+// pathname of each Dirent relative to the directory containing the ignore
+// file, so the predicate returns false for Dirents outside that directory.
+//
+// Blank lines and lines beginning with '#' are ignored. A pattern beginning
+// with '!' re-includes nodes excluded by an earlier pattern, and the last
+// matching pattern takes precedence. A pattern ending with '/' matches only
+// directories. A pattern containing '/' elsewhere is matched against the
+// entire relative pathname, and otherwise against the name of the node at any
+// depth. Within a pattern, '*', '?', and '[...]' match as they do for
+// path.Match, and a "**" segment matches any number of directories. As with
+// git, a node is excluded when any directory containing it is excluded, and it
+// cannot be re-included.
+//
+//    osIgnorename := filepath.Join(osDirname, ".dockerignore")
+//    ignored, err := godirwalk.LoadIgnoreFile(osIgnorename)
+//    if err != nil {
+//        return err
+//    }
+//    err = godirwalk.Walk(osDirname, &godirwalk.Options{
+//        Callback: func(osPathname string, de *godirwalk.Dirent) error {
+//            if ignored(de) {
+//                if de.IsDir() {
+//                    return filepath.SkipDir
+//                }
+//                return nil
+//            }
+//            fmt.Println(osPathname)
+//            return nil
+//        },
+//    })
+func LoadIgnoreFile(pathname string) (func(*Dirent) bool, error) {
+	fh, err := os.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	base, err := filepath.Abs(filepath.Dir(pathname))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(de *Dirent) bool {
+		abs, err := de.AbsPath()
+		if err != nil {
+			return false
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+			return false
+		}
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		// Test each containing directory before the node itself, because
+		// nodes within an excluded directory are excluded.
+		for i := 1; i <= len(segments); i++ {
+			isDir := i < len(segments) || de.IsDir()
+			if isIgnored(rules, segments[:i], isDir) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseIgnoreRule returns the rule described by a line of an ignore file, or
+// false when the line holds no pattern.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	// Trailing spaces are ignored unless escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:] // escaped leading character
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/") // anchored to the base directory
+	} else {
+		line = "**/" + line // matches at any depth
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// isIgnored returns true when the last of the rules matching the pathname
+// segments excludes the node.
+func isIgnored(rules []ignoreRule, segments []string, isDir bool) bool {
+	var ignored bool
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments returns true when the pathname segments match the pattern
+// segments, where a "**" pattern segment matches zero or more segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,123 @@
+package godirwalk
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadIgnoreFile(t *testing.T) {
+	root, cleanup := setupTree(t,
+		"a.log",
+		"anchored",
+		"build/out",
+		"docs/guide/intro.md",
+		"docs/readme.md",
+		"keep.log",
+		"main.go",
+		"src/anchored",
+		"src/build",
+		"src/debug.log",
+		"src/node_modules/m/index.js",
+	)
+	defer cleanup()
+
+	const ignoreFile = `# comment
+*.log
+!keep.log
+
+build/
+/anchored
+docs/**/*.md
+node_modules
+`
+	pathname := filepath.Join(root, ".dockerignore")
+	ensureError(t, ioutil.WriteFile(pathname, []byte(ignoreFile), 0644))
+
+	ignored, err := LoadIgnoreFile(pathname)
+	ensureError(t, err)
+
+	var kept []string
+	err = Walk(root, &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			if ignored(de) {
+				if de.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if osPathname != root {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				kept = append(kept, filepath.ToSlash(rel))
+			}
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	// The build file below src is kept because the pattern matches only
+	// directories, and src/anchored because the pattern is anchored.
+	expected := ".dockerignore docs docs/guide keep.log main.go src src/anchored src/build"
+	if got, want := strings.Join(kept, " "), expected; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("descendants of excluded directories", func(t *testing.T) {
+		de := &Dirent{path: filepath.Join(root, "src/node_modules/m/index.js"), name: "index.js"}
+		if got, want := ignored(de), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("outside base directory", func(t *testing.T) {
+		de := &Dirent{path: filepath.Join(filepath.Dir(root), "a.log"), name: "a.log"}
+		if got, want := ignored(de), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := LoadIgnoreFile(filepath.Join(root, "missing"))
+		ensureError(t, err, "missing")
+	})
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		ok       bool
+		segments string
+		negate   bool
+		dirOnly  bool
+	}{
+		{"", false, "", false, false},
+		{"# comment", false, "", false, false},
+		{"\\#hash", true, "**/#hash", false, false},
+		{"name  ", true, "**/name", false, false},
+		{"!name", true, "**/name", true, false},
+		{"dir/", true, "**/dir", false, true},
+		{"/anchored", true, "anchored", false, false},
+		{"a/b", true, "a/b", false, false},
+	} {
+		rule, ok := parseIgnoreRule(tc.line)
+		if got, want := ok, tc.ok; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", tc.line, got, want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got, want := strings.Join(rule.segments, "/"), tc.segments; got != want {
+			t.Errorf("%q: GOT: %q; WANT: %q", tc.line, got, want)
+		}
+		if got, want := rule.negate, tc.negate; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", tc.line, got, want)
+		}
+		if got, want := rule.dirOnly, tc.dirOnly; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", tc.line, got, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// The io_uring constants this library refers to, from linux/io_uring.h.
+const (
+	ioringOffSQRing      = 0
+	ioringOffCQRing      = 0x8000000
+	ioringOffSQEs        = 0x10000000
+	ioringEnterGetEvents = 0x1
+	ioringOpStatx        = 21
+
+	// uringEntries is the number of submission queue entries requested, and
+	// thus the largest number of statx operations submitted at once.
+	uringEntries = 256
+)
+
+// ioSQRingOffsets mirrors struct io_sqring_offsets from linux/io_uring.h.
+type ioSQRingOffsets struct {
+	Head, Tail, RingMask, RingEntries, Flags, Dropped, Array, _ uint32
+	_                                                           uint64
+}
+
+// ioCQRingOffsets mirrors struct io_cqring_offsets from linux/io_uring.h.
+type ioCQRingOffsets struct {
+	Head, Tail, RingMask, RingEntries, Overflow, CQEs, Flags, _ uint32
+	_                                                           uint64
+}
+
+// ioURingParams mirrors struct io_uring_params from linux/io_uring.h.
+type ioURingParams struct {
+	SQEntries, CQEntries, Flags, SQThreadCPU, SQThreadIdle, Features, WQFd uint32
+	_                                                                      [3]uint32
+	SQOff                                                                  ioSQRingOffsets
+	CQOff                                                                  ioCQRingOffsets
+}
+
+// ioURingSQE mirrors struct io_uring_sqe from linux/io_uring.h, naming only the
+// fields used to submit statx operations.
+type ioURingSQE struct {
+	Opcode     uint8
+	Flags      uint8
+	IOPrio     uint16
+	Fd         int32
+	Off        uint64 // the statx buffer
+	Addr       uint64 // the pathname
+	Len        uint32 // the statx mask
+	StatxFlags uint32
+	UserData   uint64
+	_          [3]uint64
+}
+
+// ioURingCQE mirrors struct io_uring_cqe from linux/io_uring.h.
+type ioURingCQE struct {
+	UserData uint64
+	Res      int32
+	Flags    uint32
+}
+
+// uring is an io_uring instance used to obtain the file information of the
+// children of a directory with a single system call. It is safe for concurrent
+// use.
+type uring struct {
+	mu     sync.Mutex
+	fd     int
+	params ioURingParams
+	sqRing []byte
+	cqRing []byte
+	sqes   []byte
+
+	// failed is set when an error prevented waiting for submitted operations
+	// to complete, after which the instance is no longer used, and retained
+	// holds the memory those operations may still write to.
+	failed   bool
+	retained []interface{}
+}
+
+// newURing returns a new io_uring instance, or an error when the kernel does
+// not provide io_uring, or does not permit this process to use it.
+func newURing() (*uring, error) {
+	if sysIOURingSetup == 0 {
+		return nil, syscall.ENOSYS
+	}
+	u := new(uring)
+	fd, _, errno := syscall.Syscall(sysIOURingSetup, uringEntries, uintptr(unsafe.Pointer(&u.params)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	u.fd = int(fd)
+
+	var err error
+	prot, flags := syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE
+	if u.sqRing, err = syscall.Mmap(u.fd, ioringOffSQRing, int(u.params.SQOff.Array+u.params.SQEntries*4), prot, flags); err == nil {
+		if u.cqRing, err = syscall.Mmap(u.fd, ioringOffCQRing, int(u.params.CQOff.CQEs+u.params.CQEntries*uint32(unsafe.Sizeof(ioURingCQE{}))), prot, flags); err == nil {
+			u.sqes, err = syscall.Mmap(u.fd, ioringOffSQEs, int(u.params.SQEntries*uint32(unsafe.Sizeof(ioURingSQE{}))), prot, flags)
+		}
+	}
+	if err != nil {
+		u.close()
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	return u, nil
+}
+
+// close releases the resources of the io_uring instance.
+func (u *uring) close() {
+	for _, b := range [][]byte{u.sqRing, u.cqRing, u.sqes} {
+		if b != nil {
+			_ = syscall.Munmap(b)
+		}
+	}
+	_ = syscall.Close(u.fd)
+}
+
+// ringUint32 returns a pointer to the 32-bit field at the specified offset of
+// a ring.
+func ringUint32(ring []byte, offset uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&ring[offset]))
+}
+
+// lstatBatch obtains the file information of each of the specified pathnames
+// without following symbolic links, requesting the fields specified by mask,
+// and returns the os.FileInfo and error for each pathname in the
+// corresponding elements of the returned slices. It returns an error rather
+// than a result for every pathname when the operations cannot be submitted,
+// and reports syscall.EINVAL for each pathname when the kernel predates the
+// io_uring statx operation.
+func (u *uring) lstatBatch(osPathnames []string, mask uint32) ([]os.FileInfo, []error, error) {
+	infos := make([]*statxFileInfo, len(osPathnames))
+	names := make([]*byte, len(osPathnames))
+	errs := make([]error, len(osPathnames))
+	for i, osPathname := range osPathnames {
+		p, err := syscall.BytePtrFromString(osPathname)
+		if err != nil {
+			errs[i] = &os.PathError{Op: "statx", Path: osPathname, Err: err}
+			continue
+		}
+		names[i] = p
+		infos[i] = &statxFileInfo{name: filepath.Base(osPathname)}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.failed {
+		return nil, nil, syscall.EIO
+	}
+
+	sqMask := *ringUint32(u.sqRing, u.params.SQOff.RingMask)
+	cqMask := *ringUint32(u.cqRing, u.params.CQOff.RingMask)
+	sqTail := ringUint32(u.sqRing, u.params.SQOff.Tail)
+	cqHead := ringUint32(u.cqRing, u.params.CQOff.Head)
+	cqTail := ringUint32(u.cqRing, u.params.CQOff.Tail)
+
+	for start := 0; start < len(osPathnames); {
+		// Queue as many operations as the submission queue holds.
+		tail := atomic.LoadUint32(sqTail)
+		var submitted uint32
+		end := start
+		for ; end < len(osPathnames) && submitted < u.params.SQEntries; end++ {
+			if names[end] == nil {
+				continue
+			}
+			index := (tail + submitted) & sqMask
+			sqe := (*ioURingSQE)(unsafe.Pointer(&u.sqes[uintptr(index)*unsafe.Sizeof(ioURingSQE{})]))
+			*sqe = ioURingSQE{
+				Opcode:     ioringOpStatx,
+				Fd:         int32(atFdcwd),
+				Off:        uint64(uintptr(unsafe.Pointer(&infos[end].stx))),
+				Addr:       uint64(uintptr(unsafe.Pointer(names[end]))),
+				Len:        mask | statxType | statxMode, // required to satisfy os.FileInfo
+				StatxFlags: atSymlinkNofollow,
+				UserData:   uint64(end),
+			}
+			*ringUint32(u.sqRing, u.params.SQOff.Array+index*4) = index
+			submitted++
+		}
+		atomic.StoreUint32(sqTail, tail+submitted)
+
+		// Submit them, and wait for all of them to complete.
+		var consumed, completed uint32
+		for completed < submitted {
+			if atomic.LoadUint32(cqHead) == atomic.LoadUint32(cqTail) {
+				n, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(u.fd), uintptr(submitted-consumed), 1, ioringEnterGetEvents, 0, 0)
+				if errno == syscall.EINTR {
+					continue
+				}
+				if errno != 0 {
+					u.failed, u.retained = true, []interface{}{names, infos}
+					return nil, nil, os.NewSyscallError("io_uring_enter", errno)
+				}
+				consumed += uint32(n)
+			}
+			for head := atomic.LoadUint32(cqHead); head != atomic.LoadUint32(cqTail); head++ {
+				cqe := (*ioURingCQE)(unsafe.Pointer(&u.cqRing[uintptr(u.params.CQOff.CQEs)+uintptr(head&cqMask)*unsafe.Sizeof(ioURingCQE{})]))
+				if i := int(cqe.UserData); cqe.Res < 0 {
+					errs[i] = &os.PathError{Op: "statx", Path: osPathnames[i], Err: syscall.Errno(-cqe.Res)}
+					infos[i] = nil
+				}
+				atomic.StoreUint32(cqHead, head+1)
+				completed++
+			}
+		}
+		start = end
+	}
+	runtime.KeepAlive(names)
+	runtime.KeepAlive(infos)
+
+	fileInfos := make([]os.FileInfo, len(osPathnames))
+	for i, fi := range infos {
+		if fi != nil {
+			fileInfos[i] = fi
+		}
+	}
+	return fileInfos, errs, nil
+}
+
+// preloadWithURing obtains the file information of the specified children of a
+// directory as a batch using the io_uring instance of the walk, when there is
+// one. Children whose information cannot be obtained this way are left
+// without it, so it is obtained, and any error reported, as usual.
+func preloadWithURing(osDirname string, deChildren Dirents, options *walker) {
+	if options.uring == nil || len(deChildren) < 2 {
+		return
+	}
+	osPathnames := make([]string, len(deChildren))
+	for i, deChild := range deChildren {
+		osPathnames[i] = joinPathname(osDirname, deChild.name)
+	}
+	fileInfos, errs, err := options.uring.lstatBatch(osPathnames, options.StatMask)
+	if err != nil {
+		return
+	}
+	for i, deChild := range deChildren {
+		if errs[i] == nil && deChild.fileInfo == nil {
+			deChild.fileInfo = fileInfos[i]
+		}
+	}
+}
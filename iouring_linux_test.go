@@ -0,0 +1,111 @@
+package godirwalk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestURingLstatBatch(t *testing.T) {
+	u, err := newURing()
+	if err != nil {
+		t.Skip(err) // io_uring is not available
+	}
+	defer u.close()
+
+	names := []string{"d0/f1", "d0/d1", "d0/symlinks/toF1", "d0/missing"}
+	osPathnames := make([]string, len(names))
+	for i, name := range names {
+		osPathnames[i] = filepath.Join(testRoot, name)
+	}
+
+	fileInfos, errs, err := u.lstatBatch(osPathnames, statxSize)
+	ensureError(t, err)
+	if errors.Is(errs[0], syscall.EINVAL) {
+		t.Skip("kernel does not provide the io_uring statx operation")
+	}
+
+	for i, name := range names[:3] {
+		expected, err := os.Lstat(osPathnames[i])
+		ensureError(t, err)
+		ensureError(t, errs[i])
+		actual := fileInfos[i]
+		if got, want := actual.Name(), expected.Name(); got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", name, got, want)
+		}
+		if got, want := actual.Mode(), expected.Mode(); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+		if got, want := actual.Size(), expected.Size(); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+	}
+
+	if !os.IsNotExist(errs[3]) {
+		t.Errorf("GOT: %v; WANT: %v", errs[3], os.ErrNotExist)
+	}
+	if got := fileInfos[3]; got != nil {
+		t.Errorf("GOT: %v; WANT: nil", got)
+	}
+}
+
+func TestWalkUseIOURing(t *testing.T) {
+	var count int
+	err := Walk(filepath.Join(testRoot, "d0"), &Options{
+		UseIOURing:      true,
+		PreloadFileInfo: true,
+		StatMask:        statxSize,
+		ScratchBuffer:   testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			expected, err := os.Lstat(osPathname)
+			ensureError(t, err)
+			actual := de.CachedFileInfo()
+			if actual == nil {
+				t.Fatalf("%s: GOT: nil; WANT: os.FileInfo", osPathname)
+			}
+			if got, want := actual.Mode(), expected.Mode(); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+			}
+			if got, want := actual.Size(), expected.Size(); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+			}
+			count++
+			return nil
+		},
+	})
+	ensureError(t, err)
+	if count < 2 {
+		t.Errorf("GOT: %v; WANT: >= 2", count)
+	}
+}
+
+// benchmarkPreloadFileInfo walks a single wide directory obtaining the file
+// information of each of its children.
+func benchmarkPreloadFileInfo(b *testing.B, useIOURing bool) {
+	entries := make([]string, 1000)
+	for i := range entries {
+		entries[i] = fmt.Sprintf("wide/%04d", i)
+	}
+	root, cleanup := setupTree(b, entries...)
+	defer cleanup()
+
+	options := &Options{
+		UseIOURing:      useIOURing,
+		PreloadFileInfo: true,
+		StatMask:        statxSize | statxMtime,
+		ScratchBuffer:   make([]byte, DefaultScratchBufferSize),
+		Callback:        func(string, *Dirent) error { return nil },
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Walk(root, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPreloadFileInfoStatx(b *testing.B)   { benchmarkPreloadFileInfo(b, false) }
+func BenchmarkPreloadFileInfoIOURing(b *testing.B) { benchmarkPreloadFileInfo(b, true) }
@@ -0,0 +1,18 @@
+// +build !linux
+
+package godirwalk
+
+import "errors"
+
+// uring is not used, because io_uring is only available on Linux.
+type uring struct{}
+
+// newURing always returns an error, because io_uring is only available on
+// Linux.
+func newURing() (*uring, error) { return nil, errors.New("io_uring is only available on Linux") }
+
+// close does nothing.
+func (*uring) close() {}
+
+// preloadWithURing does nothing, because io_uring is only available on Linux.
+func preloadWithURing(_ string, _ Dirents, _ *walker) {}
@@ -0,0 +1,11 @@
+// +build linux
+// +build 386 amd64 arm arm64 loong64 ppc64 ppc64le riscv64 s390x
+
+package godirwalk
+
+// The io_uring system call numbers, which are the same on every architecture
+// other than mips.
+const (
+	sysIOURingSetup = 425
+	sysIOURingEnter = 426
+)
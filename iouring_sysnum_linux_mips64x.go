@@ -0,0 +1,10 @@
+// +build linux
+// +build mips64 mips64le
+
+package godirwalk
+
+// The io_uring system call numbers for the n64 ABI.
+const (
+	sysIOURingSetup = 5425
+	sysIOURingEnter = 5426
+)
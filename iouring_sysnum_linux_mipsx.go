@@ -0,0 +1,10 @@
+// +build linux
+// +build mips mipsle
+
+package godirwalk
+
+// The io_uring system call numbers for the o32 ABI.
+const (
+	sysIOURingSetup = 4425
+	sysIOURingEnter = 4426
+)
@@ -0,0 +1,12 @@
+// +build linux
+// +build !386,!amd64,!arm,!arm64,!loong64,!mips,!mipsle
+// +build !mips64,!mips64le,!ppc64,!ppc64le,!riscv64,!s390x
+
+package godirwalk
+
+// The io_uring system call numbers are not known for this architecture, so
+// io_uring is not used.
+const (
+	sysIOURingSetup = 0
+	sysIOURingEnter = 0
+)
@@ -0,0 +1,124 @@
+package godirwalk
+
+import (
+	"container/heap"
+	"os"
+)
+
+// LargestFiles returns the n largest regular files in the file system hierarchy
+// rooted at the specified directory, sorted in descending order by size, with
+// ties broken by pathname. Only the n largest files seen so far are retained
+// while walking, so memory use is proportional to n rather than to the size of
+// the hierarchy.
+//
+// The CachedFileInfo method of each returned Dirent returns the file
+// information used to determine its size.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    largest, err := godirwalk.LargestFiles(osDirname, 10, nil)
+//    if err != nil {
+//        return err
+//    }
+//    for _, de := range largest {
+//        fmt.Printf("%d %s\n", de.CachedFileInfo().Size(), de.Path())
+//    }
+func LargestFiles(osDirname string, n int, opts *Options) (Dirents, error) {
+	return topFiles(osDirname, n, opts, func(fi os.FileInfo) int64 { return fi.Size() })
+}
+
+// NewestFiles returns the n most recently modified regular files in the file
+// system hierarchy rooted at the specified directory, sorted in descending
+// order by modification time, with ties broken by pathname. As with
+// LargestFiles, only the n newest files seen so far are retained while
+// walking.
+//
+// The CachedFileInfo method of each returned Dirent returns the file
+// information used to determine its modification time.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+func NewestFiles(osDirname string, n int, opts *Options) (Dirents, error) {
+	return topFiles(osDirname, n, opts, func(fi os.FileInfo) int64 { return fi.ModTime().UnixNano() })
+}
+
+// topFiles returns the n regular files with the highest rank, sorted in
+// descending order by rank, with ties broken by pathname.
+func topFiles(osDirname string, n int, opts *Options, rank func(os.FileInfo) int64) (Dirents, error) {
+	options := HelperOptions(opts)
+
+	if n <= 0 {
+		return nil, nil
+	}
+
+	h := make(rankHeap, 0, n)
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if !de.IsRegular() {
+			return nil
+		}
+		fi := de.fileInfo
+		if fi == nil {
+			var err error
+			if fi, err = os.Lstat(osPathname); err != nil {
+				return err
+			}
+		}
+		item := rankedDirent{rank: rank(fi), de: de}
+		if len(h) == n {
+			if !h[0].less(item) {
+				return nil // ranks no higher than the lowest retained file
+			}
+			heap.Pop(&h)
+		}
+		retained := *de
+		retained.fileInfo = fi
+		item.de = &retained
+		heap.Push(&h, item)
+		return nil
+	}
+
+	if err := Walk(osDirname, &options); err != nil {
+		return nil, err
+	}
+
+	top := make(Dirents, len(h))
+	for i := len(top) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(&h).(rankedDirent).de
+	}
+	return top, nil
+}
+
+// rankedDirent is a Dirent and the rank topFiles orders it by.
+type rankedDirent struct {
+	rank int64
+	de   *Dirent
+}
+
+// less returns true when r would be returned after other by topFiles.
+func (r rankedDirent) less(other rankedDirent) bool {
+	if r.rank != other.rank {
+		return r.rank < other.rank
+	}
+	return r.de.path > other.de.path
+}
+
+// rankHeap is a min-heap of rankedDirents, whose root is the Dirent that would
+// be the last returned by topFiles.
+type rankHeap []rankedDirent
+
+func (h rankHeap) Len() int { return len(h) }
+
+func (h rankHeap) Less(i, j int) bool { return h[i].less(h[j]) }
+
+func (h rankHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *rankHeap) Push(x interface{}) { *h = append(*h, x.(rankedDirent)) }
+
+func (h *rankHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,88 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLargestFiles(t *testing.T) {
+	// Each file contains its entry name followed by a newline, so its size is
+	// one more than the length of its entry name.
+	root, cleanup := setupTree(t, "a", "d1/bbbbbbbb", "d1/cc", "d1/d2/dddddd", "d1/d2/ee", "ff", "g/", "zz/yy")
+	defer cleanup()
+
+	// The order of the returned files matters, so compare them joined.
+	ensureLargest := func(t *testing.T, n int, expected ...string) {
+		t.Helper()
+		dirents, err := LargestFiles(root, n, nil)
+		ensureError(t, err)
+		var actual []string
+		for _, de := range dirents {
+			rel, err := filepath.Rel(root, de.Path())
+			ensureError(t, err)
+			if got, want := de.CachedFileInfo().Size(), int64(len(filepath.ToSlash(rel))+1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		if got, want := strings.Join(actual, " "), strings.Join(expected, " "); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	t.Run("fewer than all", func(t *testing.T) {
+		ensureLargest(t, 3, "d1/d2/dddddd", "d1/bbbbbbbb", "d1/d2/ee")
+	})
+
+	t.Run("ties broken by pathname", func(t *testing.T) {
+		ensureLargest(t, 4, "d1/d2/dddddd", "d1/bbbbbbbb", "d1/d2/ee", "d1/cc")
+		ensureLargest(t, 5, "d1/d2/dddddd", "d1/bbbbbbbb", "d1/d2/ee", "d1/cc", "zz/yy")
+	})
+
+	t.Run("more than all", func(t *testing.T) {
+		ensureLargest(t, 10, "d1/d2/dddddd", "d1/bbbbbbbb", "d1/d2/ee", "d1/cc", "zz/yy", "ff", "a")
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		ensureLargest(t, 0)
+	})
+}
+
+func TestNewestFiles(t *testing.T) {
+	root, cleanup := setupTree(t, "a", "d1/b", "d1/c", "d1/d2/d", "e", "f/")
+	defer cleanup()
+
+	base := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for rel, age := range map[string]time.Duration{
+		"a":       4 * time.Hour,
+		"d1/b":    time.Hour,
+		"d1/c":    3 * time.Hour,
+		"d1/d2/d": 2 * time.Hour,
+		"e":       3 * time.Hour, // same time as d1/c
+	} {
+		mtime := base.Add(-age)
+		ensureError(t, os.Chtimes(filepath.Join(root, filepath.FromSlash(rel)), mtime, mtime))
+	}
+
+	ensureNewest := func(t *testing.T, n int, expected ...string) {
+		t.Helper()
+		dirents, err := NewestFiles(root, n, nil)
+		ensureError(t, err)
+		var actual []string
+		for _, de := range dirents {
+			rel, err := filepath.Rel(root, de.Path())
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		if got, want := strings.Join(actual, " "), strings.Join(expected, " "); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	ensureNewest(t, 2, "d1/b", "d1/d2/d")
+	ensureNewest(t, 3, "d1/b", "d1/d2/d", "d1/c")
+	ensureNewest(t, 10, "d1/b", "d1/d2/d", "d1/c", "e", "a")
+}
@@ -0,0 +1,71 @@
+package godirwalk
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// WriteMakefile writes to w a Makefile with one target for each file system
+// node in the hierarchy rooted at the specified directory, where the
+// prerequisite of each target is the target for its parent directory, and the
+// target for the root directory has no prerequisites. The first target, `all`,
+// has every non-directory node as a prerequisite. No recipes are written; the
+// Makefile is intended to be included by another Makefile that supplies them,
+// for instance using pattern rules, so that running `make -j` processes files
+// in parallel while still processing each directory before its contents.
+//
+// Targets are named by their pathnames, using solidus separators, prefixed by
+// the root as provided.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+func WriteMakefile(w io.Writer, root string, opts *Options) error {
+	options := HelperOptions(opts)
+
+	root = filepath.Clean(root)
+	var rules, all []string
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		target := makeEscape(filepath.ToSlash(osPathname))
+		if osPathname == root {
+			rules = append(rules, target+":")
+			return nil
+		}
+		rules = append(rules, target+": "+makeEscape(filepath.ToSlash(filepath.Dir(osPathname))))
+		if !de.IsDir() {
+			all = append(all, target)
+		}
+		return nil
+	}
+
+	if err := Walk(root, &options); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString(".PHONY: all\nall:")
+	for _, target := range all {
+		_, _ = bw.WriteString(" \\\n\t" + target)
+	}
+	_, _ = bw.WriteString("\n\n")
+	for _, rule := range rules {
+		_, _ = bw.WriteString(rule + "\n")
+	}
+	return bw.Flush()
+}
+
+// makeReplacer escapes the characters that are special to make when they appear
+// in target and prerequisite names.
+var makeReplacer = strings.NewReplacer(
+	"$", "$$",
+	"#", `\#`,
+	" ", `\ `,
+	":", `\:`,
+	"%", `\%`,
+)
+
+// makeEscape returns the pathname escaped for use as a make target or
+// prerequisite.
+func makeEscape(pathname string) string { return makeReplacer.Replace(pathname) }
@@ -0,0 +1,34 @@
+package godirwalk
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteMakefile(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "a/c d", "e/", "f$")
+	defer cleanup()
+
+	var makefile bytes.Buffer
+	ensureError(t, WriteMakefile(&makefile, root, nil))
+
+	r := makeEscape(filepath.ToSlash(root))
+	expected := `.PHONY: all
+all: \
+	` + r + `/a/b \
+	` + r + `/a/c\ d \
+	` + r + `/f$$
+
+` + r + `:
+` + r + `/a: ` + r + `
+` + r + `/a/b: ` + r + `/a
+` + r + `/a/c\ d: ` + r + `/a
+` + r + `/e: ` + r + `
+` + r + `/f$$: ` + r + `
+`
+	if got, want := makefile.String(), expected; got != want {
+		t.Errorf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+
+}
@@ -0,0 +1,341 @@
+package godirwalk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errManifestFull is returned by the Callback used by WriteManifest to stop
+// the walk once the manifest reaches MaxBytes.
+var errManifestFull = errors.New("manifest full")
+
+// ManifestOptions specifies how WriteManifest writes a manifest.
+type ManifestOptions struct {
+	// Options specifies how WriteManifest walks the hierarchy.
+	Options
+
+	// MaxBytes optionally limits the number of bytes written. When positive,
+	// WriteManifest stops walking once writing the line for the next node
+	// would exceed the limit, and reports the manifest as truncated.
+	MaxBytes int
+}
+
+// WriteManifest writes to w a manifest of the file system hierarchy rooted at
+// the specified directory, with one line for each node below the root, in the
+// order Walk visits them. Each line holds the type of the node, as the first
+// character of the mode displayed by ls, its size in bytes, which is zero for
+// nodes other than regular files, and its pathname relative to the root, using
+// solidus separators, quoted using Go syntax. For instance:
+//
+//    d 0 "src"
+//    - 1024 "src/main.go"
+//
+// When the MaxBytes option is positive, no more than that many bytes
+// are written, and the walk stops once writing the line for the next node would
+// exceed that limit, in which case the returned boolean is true, so the
+// manifest may, for instance, be guaranteed to fit within a single request.
+// The manifest may be read by ReadManifest to verify the hierarchy later.
+//
+// The provided ManifestOptions may be nil. Only the fields of its Options that
+// HelperOptions copies are used.
+func WriteManifest(w io.Writer, root string, opts *ManifestOptions) (bool, error) {
+	var manifestOptions ManifestOptions
+	if opts != nil {
+		manifestOptions = *opts
+	}
+	options := HelperOptions(&manifestOptions.Options)
+
+	if errorCallback := options.ErrorCallback; errorCallback != nil {
+		options.ErrorCallback = func(osPathname string, err error) ErrorAction {
+			if err == errManifestFull {
+				return Halt // ErrorCallback may not skip it
+			}
+			return errorCallback(osPathname, err)
+		}
+	}
+
+	root = filepath.Clean(root)
+	bw := bufio.NewWriter(w)
+	var written int
+	var line []byte
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if osPathname == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, osPathname)
+		if err != nil {
+			return err
+		}
+		var size int64
+		if de.IsRegular() {
+			fi := de.fileInfo
+			if fi == nil {
+				if fi, err = os.Lstat(osPathname); err != nil {
+					return err
+				}
+			}
+			size = fi.Size()
+		}
+
+		line = append(line[:0], typeChar(de.modeType), ' ')
+		line = strconv.AppendInt(line, size, 10)
+		line = append(line, ' ')
+		line = strconv.AppendQuote(line, filepath.ToSlash(rel))
+		line = append(line, '\n')
+
+		if manifestOptions.MaxBytes > 0 && written+len(line) > manifestOptions.MaxBytes {
+			return errManifestFull
+		}
+		written += len(line)
+		_, err = bw.Write(line)
+		return err
+	}
+
+	err := Walk(root, &options)
+	if err != nil && err != errManifestFull {
+		return false, err
+	}
+	if er := bw.Flush(); er != nil {
+		return false, er
+	}
+	return err == errManifestFull, nil
+}
+
+// ManifestEntry describes a node that VerifyTree expects to find below the root
+// of a file system hierarchy.
+type ManifestEntry struct {
+	// Path is the pathname of the node relative to the root, using solidus
+	// separators.
+	Path string
+
+	// Mode is the mode of the node, including its type and permission bits.
+	Mode os.FileMode
+
+	// Hash is an optional SHA-256 hash of the contents of a regular file, or
+	// of the referent of a symbolic link. It is ignored when nil.
+	Hash []byte
+
+	// typeOnly is true for entries returned by ReadManifest, whose Mode holds
+	// only the type of the node, and which also record the size of regular
+	// files.
+	typeOnly bool
+	size     int64
+}
+
+// ReadManifest reads a manifest written by WriteManifest from r, and returns
+// its entries, so a hierarchy may be verified against it by VerifyTree. As the
+// manifest records only the type of each node, and the size of regular files,
+// the Mode of each returned entry holds only the type bits, and VerifyTree
+// compares only the type of a node, and the size of a regular file, to those of
+// such an entry.
+//
+//    manifest, err := godirwalk.ReadManifest(r)
+//    if err != nil {
+//        return err
+//    }
+//    discrepancies, err := godirwalk.VerifyTree(osDirname, manifest, nil)
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var manifest []ManifestEntry
+	br := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			if line == "" {
+				return manifest, nil
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		entry, ok := parseManifestLine(strings.TrimSuffix(line, "\n"))
+		if !ok {
+			return nil, fmt.Errorf("cannot parse manifest line %d: %q", lineNumber, line)
+		}
+		manifest = append(manifest, entry)
+		if err == io.EOF {
+			return manifest, nil
+		}
+	}
+}
+
+// parseManifestLine returns the ManifestEntry for a line written by
+// WriteManifest, without its trailing newline.
+func parseManifestLine(line string) (ManifestEntry, bool) {
+	if len(line) < 2 || line[1] != ' ' {
+		return ManifestEntry{}, false
+	}
+	mode, ok := modeTypeFromChar(line[0])
+	if !ok {
+		return ManifestEntry{}, false
+	}
+	fields := strings.SplitN(line[2:], " ", 2)
+	if len(fields) != 2 {
+		return ManifestEntry{}, false
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || size < 0 {
+		return ManifestEntry{}, false
+	}
+	rel, err := strconv.Unquote(fields[1])
+	if err != nil || rel == "" {
+		return ManifestEntry{}, false
+	}
+	return ManifestEntry{Path: rel, Mode: mode, typeOnly: true, size: size}, true
+}
+
+// modeTypeFromChar returns the type bits of a mode whose type is displayed as
+// the specified character by typeChar.
+func modeTypeFromChar(c byte) (os.FileMode, bool) {
+	switch c {
+	case 'l':
+		return os.ModeSymlink, true
+	case 'd':
+		return os.ModeDir, true
+	case 'c':
+		return os.ModeDevice | os.ModeCharDevice, true
+	case 'b':
+		return os.ModeDevice, true
+	case 'p':
+		return os.ModeNamedPipe, true
+	case 's':
+		return os.ModeSocket, true
+	case '-':
+		return 0, true
+	case '?':
+		return os.ModeIrregular, true
+	default:
+		return 0, false
+	}
+}
+
+// DiscrepancyKind identifies how a node differs from a manifest.
+type DiscrepancyKind int
+
+const (
+	// Missing is the DiscrepancyKind of a node in the manifest that does not
+	// exist.
+	Missing DiscrepancyKind = iota
+
+	// Extra is the DiscrepancyKind of a node that is not in the manifest.
+	Extra
+
+	// Mismatched is the DiscrepancyKind of a node whose mode or hash differs
+	// from its manifest entry.
+	Mismatched
+)
+
+// String returns the name of the DiscrepancyKind.
+func (dk DiscrepancyKind) String() string {
+	switch dk {
+	case Missing:
+		return "missing"
+	case Extra:
+		return "extra"
+	case Mismatched:
+		return "mismatched"
+	default:
+		return "DiscrepancyKind(" + strconv.Itoa(int(dk)) + ")"
+	}
+}
+
+// Discrepancy describes a node that differs from a manifest.
+type Discrepancy struct {
+	Path string // relative to the root, using solidus separators
+	Kind DiscrepancyKind
+}
+
+// VerifyTree walks the file system hierarchy rooted at the specified directory,
+// and returns the discrepancies between its nodes and the manifest, sorted by
+// pathname. Nodes are matched to manifest entries by their pathnames relative
+// to the root, which is not itself verified. A node is mismatched when its mode
+// differs from that of its entry, or when the entry has a hash that differs
+// from the hash of the node. For an entry returned by ReadManifest, a node is
+// instead mismatched when its type differs from that of the entry, or when it
+// is a regular file whose size differs from that recorded in the manifest.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    discrepancies, err := godirwalk.VerifyTree(osDirname, manifest, nil)
+//    if err != nil {
+//        return err
+//    }
+//    for _, d := range discrepancies {
+//        fmt.Printf("%s %s\n", d.Kind, d.Path)
+//    }
+func VerifyTree(osDirname string, manifest []ManifestEntry, opts *Options) ([]Discrepancy, error) {
+	options := HelperOptions(opts)
+
+	expected := make(map[string]ManifestEntry, len(manifest))
+	for _, entry := range manifest {
+		expected[entry.Path] = entry
+	}
+
+	root := filepath.Clean(osDirname)
+	var discrepancies []Discrepancy
+	seen := make(map[string]struct{}, len(manifest))
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if osPathname == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, osPathname)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		entry, ok := expected[rel]
+		if !ok {
+			discrepancies = append(discrepancies, Discrepancy{Path: rel, Kind: Extra})
+			return nil
+		}
+		seen[rel] = struct{}{}
+
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		if entry.typeOnly {
+			mode := fi.Mode()
+			if typeChar(mode&os.ModeType) != typeChar(entry.Mode&os.ModeType) || mode.IsRegular() && fi.Size() != entry.size {
+				discrepancies = append(discrepancies, Discrepancy{Path: rel, Kind: Mismatched})
+				return nil
+			}
+		} else if fi.Mode() != entry.Mode {
+			discrepancies = append(discrepancies, Discrepancy{Path: rel, Kind: Mismatched})
+			return nil
+		}
+		if entry.Hash != nil {
+			digest, err := merkleLeaf(osPathname, fi, sha256.New())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(digest, entry.Hash) {
+				discrepancies = append(discrepancies, Discrepancy{Path: rel, Kind: Mismatched})
+			}
+		}
+		return nil
+	}
+
+	if err := Walk(root, &options); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range manifest {
+		if _, ok := seen[entry.Path]; !ok {
+			discrepancies = append(discrepancies, Discrepancy{Path: entry.Path, Kind: Missing})
+			seen[entry.Path] = struct{}{} // report duplicate entries once
+		}
+	}
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].Path < discrepancies[j].Path })
+	return discrepancies, nil
+}
@@ -0,0 +1,168 @@
+package godirwalk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteManifest(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "c", "d/")
+	defer cleanup()
+
+	// Each file contains its entry name followed by a newline.
+	const expected = "d 0 \"a\"\n- 4 \"a/b\"\n- 2 \"c\"\nd 0 \"d\"\n"
+
+	t.Run("unlimited", func(t *testing.T) {
+		var buf bytes.Buffer
+		truncated, err := WriteManifest(&buf, root, nil)
+		ensureError(t, err)
+		if got, want := buf.String(), expected; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := truncated, false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	for _, tc := range []struct {
+		max       int
+		manifest  string
+		truncated bool
+	}{
+		{len(expected), expected, false},
+		{len(expected) - 1, expected[:len(expected)-len("d 0 \"d\"\n")], true},
+		{len("d 0 \"a\"\n- 4 \"a/b\"\n") + 1, "d 0 \"a\"\n- 4 \"a/b\"\n", true},
+		{1, "", true},
+	} {
+		var buf bytes.Buffer
+		truncated, err := WriteManifest(&buf, root, &ManifestOptions{MaxBytes: tc.max})
+		ensureError(t, err)
+		if got, want := buf.String(), tc.manifest; got != want {
+			t.Errorf("max %d: GOT: %q; WANT: %q", tc.max, got, want)
+		}
+		if got, want := buf.Len() <= tc.max, true; got != want {
+			t.Errorf("max %d: GOT: %v; WANT: %v", tc.max, got, want)
+		}
+		if got, want := truncated, tc.truncated; got != want {
+			t.Errorf("max %d: GOT: %v; WANT: %v", tc.max, got, want)
+		}
+	}
+
+	t.Run("ErrorCallback cannot skip truncation", func(t *testing.T) {
+		var buf bytes.Buffer
+		truncated, err := WriteManifest(&buf, root, &ManifestOptions{
+			Options:  Options{ErrorCallback: func(string, error) ErrorAction { return SkipNode }},
+			MaxBytes: len("d 0 \"a\"\n"),
+		})
+		ensureError(t, err)
+		if got, want := buf.String(), "d 0 \"a\"\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := truncated, true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestVerifyTree(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "c", "d/", "f")
+	defer cleanup()
+
+	entry := func(rel string) ManifestEntry {
+		fi, err := os.Lstat(filepath.Join(root, rel))
+		ensureError(t, err)
+		return ManifestEntry{Path: rel, Mode: fi.Mode()}
+	}
+	digest := func(contents string) []byte {
+		sum := sha256.Sum256([]byte(contents))
+		return sum[:]
+	}
+
+	b := entry("a/b")
+	b.Hash = digest("a/b\n") // each file contains its entry name and a newline
+	manifest := []ManifestEntry{entry("a"), b, entry("c"), entry("d"), {Path: "e", Mode: 0644}}
+
+	t.Run("missing and extra", func(t *testing.T) {
+		discrepancies, err := VerifyTree(root, manifest, nil)
+		ensureError(t, err)
+		if got, want := fmt.Sprint(discrepancies), "[{e missing} {f extra}]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("mismatched", func(t *testing.T) {
+		mismatched := append([]ManifestEntry(nil), manifest...)
+		mismatched[1].Hash = digest("other\n")
+		mismatched[2].Mode ^= 0100
+		mismatched[3].Mode &^= os.ModeDir
+		mismatched = append(mismatched, entry("f"))
+
+		discrepancies, err := VerifyTree(root, mismatched, nil)
+		ensureError(t, err)
+		if got, want := fmt.Sprint(discrepancies), "[{a/b mismatched} {c mismatched} {d mismatched} {e missing}]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		_, err := VerifyTree(filepath.Join(root, "missing"), manifest, nil)
+		ensureError(t, err, "missing")
+	})
+}
+
+func TestReadManifest(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		root, cleanup := setupTree(t, "a/b", "c", "d/", "-rf")
+		defer cleanup()
+		if err := os.Symlink("c", filepath.Join(root, "link")); err != nil {
+			t.Skip(err)
+		}
+
+		var buf bytes.Buffer
+		_, err := WriteManifest(&buf, root, nil)
+		ensureError(t, err)
+		manifest, err := ReadManifest(&buf)
+		ensureError(t, err)
+		if got, want := len(manifest), 6; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+
+		discrepancies, err := VerifyTree(root, manifest, nil)
+		ensureError(t, err)
+		if got, want := len(discrepancies), 0; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", discrepancies, want)
+		}
+
+		ensureError(t, os.WriteFile(filepath.Join(root, "c"), []byte("changed\n"), 0644))
+		ensureError(t, os.Remove(filepath.Join(root, "link")))
+		ensureError(t, os.Mkdir(filepath.Join(root, "link"), 0755))
+		ensureError(t, os.Remove(filepath.Join(root, "a", "b")))
+		ensureError(t, os.WriteFile(filepath.Join(root, "e"), nil, 0644))
+
+		discrepancies, err = VerifyTree(root, manifest, nil)
+		ensureError(t, err)
+		if got, want := fmt.Sprint(discrepancies), "[{a/b missing} {c mismatched} {e extra} {link mismatched}]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, line := range []string{"x 0 \"a\"\n", "d \"a\"\n", "- -1 \"a\"\n", "- 0 a\n", "- 0 \"\"\n"} {
+			_, err := ReadManifest(strings.NewReader("d 0 \"ok\"\n" + line))
+			ensureError(t, err, "line 2")
+		}
+	})
+
+	t.Run("no trailing newline", func(t *testing.T) {
+		manifest, err := ReadManifest(strings.NewReader("d 0 \"a\"\n- 4 \"a/b c\""))
+		ensureError(t, err)
+		if got, want := fmt.Sprintln(len(manifest), manifest[1].Path, manifest[1].size), "2 a/b c 4\n"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
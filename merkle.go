@@ -0,0 +1,110 @@
+package godirwalk
+
+import (
+	"encoding/binary"
+	"hash"
+	"io"
+	"os"
+)
+
+// MerkleHash returns a hash of the file system hierarchy rooted at the
+// specified directory, computed as a Merkle tree using hashes created by the
+// provided constructor, such as sha256.New. The hash of a regular file is the
+// hash of its contents, the hash of a symbolic link is the hash of its referent
+// pathname, and the hash of a directory is the hash of the name, mode, and hash
+// of each of its immediate descendants, in lexical order. The returned hash
+// therefore changes when the name, contents, or mode of any node below the root
+// changes, but not when the root itself is renamed or moved.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used. The ErrorCallback and FollowSymbolicLinks fields are also
+// ignored, because a hash of a partially walked hierarchy would be meaningless,
+// and symbolic links are hashed rather than followed.
+//
+//    digest, err := godirwalk.MerkleHash(osDirname, nil, sha256.New)
+//    if err != nil {
+//        return err
+//    }
+//    fmt.Printf("%x\n", digest)
+func MerkleHash(root string, opts *Options, h func() hash.Hash) ([]byte, error) {
+	options := HelperOptions(opts)
+	options.ErrorCallback = nil
+	options.FollowSymbolicLinks = false
+
+	var stack []hash.Hash // one hash for each directory being walked
+	var digest []byte
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if de.IsDir() {
+			stack = append(stack, h())
+			return nil
+		}
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		leaf, err := merkleLeaf(osPathname, fi, h())
+		if err != nil {
+			return err
+		}
+		writeMerkleRecord(stack[len(stack)-1], de.name, fi.Mode(), leaf)
+		return nil
+	}
+
+	options.PostChildrenCallback = func(osPathname string, de *Dirent) error {
+		sum := stack[len(stack)-1].Sum(nil)
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			digest = sum // finished the root directory
+			return nil
+		}
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		writeMerkleRecord(stack[len(stack)-1], de.name, fi.Mode(), sum)
+		return nil
+	}
+
+	if err := Walk(root, &options); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// merkleLeaf returns the hash of a non-directory file system node.
+func merkleLeaf(osPathname string, fi os.FileInfo, h hash.Hash) ([]byte, error) {
+	switch {
+	case fi.Mode().IsRegular():
+		fh, err := os.Open(osPathname)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(h, fh)
+		if er := fh.Close(); err == nil {
+			err = er
+		}
+		if err != nil {
+			return nil, err
+		}
+	case fi.Mode()&os.ModeSymlink != 0:
+		referent, err := os.Readlink(osPathname)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = io.WriteString(h, referent) // hash.Hash Write never returns an error
+	}
+	return h.Sum(nil), nil
+}
+
+// writeMerkleRecord writes the name, mode, and hash of a child node to the hash
+// of its parent directory. The name is prefixed with its length so that no two
+// different sequences of records produce the same sequence of bytes.
+func writeMerkleRecord(parent hash.Hash, name string, mode os.FileMode, sum []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(mode))
+	binary.BigEndian.PutUint32(header[4:], uint32(len(name)))
+	_, _ = parent.Write(header[:]) // hash.Hash Write never returns an error
+	_, _ = io.WriteString(parent, name)
+	_, _ = parent.Write(sum)
+}
@@ -0,0 +1,80 @@
+package godirwalk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMerkleHash(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "a/c", "d", "e/")
+	defer cleanup()
+
+	merkleHash := func() []byte {
+		t.Helper()
+		digest, err := MerkleHash(root, nil, sha256.New)
+		ensureError(t, err)
+		if got, want := len(digest), sha256.Size; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		return digest
+	}
+
+	original := merkleHash()
+
+	t.Run("deterministic", func(t *testing.T) {
+		if got, want := merkleHash(), original; !bytes.Equal(got, want) {
+			t.Errorf("GOT: %x; WANT: %x", got, want)
+		}
+	})
+
+	// Each of the following modifies the tree, ensures the hash changes, then
+	// reverts the modification and ensures the hash is restored.
+	modifications := []struct {
+		name           string
+		modify, revert func() error
+	}{
+		{
+			name:   "content",
+			modify: func() error { return ioutil.WriteFile(filepath.Join(root, "a/b"), []byte("changed\n"), 0644) },
+			revert: func() error { return ioutil.WriteFile(filepath.Join(root, "a/b"), []byte("a/b\n"), 0644) },
+		},
+		{
+			name:   "name",
+			modify: func() error { return os.Rename(filepath.Join(root, "a/c"), filepath.Join(root, "a/z")) },
+			revert: func() error { return os.Rename(filepath.Join(root, "a/z"), filepath.Join(root, "a/c")) },
+		},
+		{
+			name:   "mode",
+			modify: func() error { return os.Chmod(filepath.Join(root, "d"), 0600) },
+			revert: func() error { return os.Chmod(filepath.Join(root, "d"), 0644) },
+		},
+		{
+			name:   "empty directory",
+			modify: func() error { return os.Mkdir(filepath.Join(root, "e/f"), os.ModePerm) },
+			revert: func() error { return os.Remove(filepath.Join(root, "e/f")) },
+		},
+	}
+
+	// Normalize file modes, which setupTree creates subject to umask.
+	for _, name := range []string{"a/b", "a/c", "d"} {
+		ensureError(t, os.Chmod(filepath.Join(root, name), 0644))
+	}
+	original = merkleHash()
+
+	for _, m := range modifications {
+		t.Run(m.name, func(t *testing.T) {
+			ensureError(t, m.modify())
+			if got := merkleHash(); bytes.Equal(got, original) {
+				t.Errorf("GOT: %x; WANT: different hash", got)
+			}
+			ensureError(t, m.revert())
+			if got, want := merkleHash(), original; !bytes.Equal(got, want) {
+				t.Errorf("GOT: %x; WANT: %x", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// +build darwin linux
+
+package godirwalk
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+// copyMetadata copies the owner and group of the file system node at osSource,
+// described by fi, to the node at osTarget, followed by its extended
+// attributes unless it is a symbolic link. Metadata the process is not
+// permitted to set, or the target file system does not support, is ignored.
+func copyMetadata(osSource, osTarget string, fi os.FileInfo) error {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(osTarget, int(st.Uid), int(st.Gid)); err != nil && !os.IsPermission(err) {
+			return err
+		}
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	names, err := listxattr(osSource)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil
+		}
+		return &os.PathError{Op: "listxattr", Path: osSource, Err: err}
+	}
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := getxattr(osSource, string(name))
+		if err != nil {
+			return &os.PathError{Op: "getxattr", Path: osSource, Err: err}
+		}
+		if err = setxattr(osTarget, string(name), value); err != nil {
+			if err == syscall.EPERM || err == syscall.EACCES || err == syscall.ENOTSUP {
+				continue // such as attributes only privileged processes may set
+			}
+			return &os.PathError{Op: "setxattr", Path: osTarget, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+// +build darwin linux
+
+package godirwalk
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyTreeExtendedAttributes(t *testing.T) {
+	src, cleanup := setupTree(t, "d/f")
+	defer cleanup()
+	const name, value = "user.godirwalk", "attribute value"
+	if err := setxattr(filepath.Join(src, "d", "f"), name, []byte(value)); err != nil {
+		if err == syscall.ENOTSUP {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+
+	scratch, cleanupScratch := setupTree(t)
+	defer cleanupScratch()
+	dst := filepath.Join(scratch, "copy")
+	ensureError(t, CopyTree(src, dst, nil))
+
+	buf, err := getxattr(filepath.Join(dst, "d", "f"), name)
+	ensureError(t, err)
+	if got, want := string(buf), value; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package godirwalk
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// mountEntry describes a single mounted file system from the mount table.
+type mountEntry struct {
+	device     string
+	mountPoint string
+	fsType     string
+}
+
+// networkFilesystemTypes enumerates the file system types that are generally
+// serviced over a network, or otherwise by a process other than the kernel,
+// and are therefore expected to be much slower to enumerate than local file
+// systems.
+var networkFilesystemTypes = map[string]struct{}{
+	"9p":        {},
+	"afs":       {},
+	"ceph":      {},
+	"cifs":      {},
+	"coda":      {},
+	"fuse":      {},
+	"fuseblk":   {},
+	"glusterfs": {},
+	"lustre":    {},
+	"ncpfs":     {},
+	"nfs":       {},
+	"nfs4":      {},
+	"smb":       {},
+	"smb3":      {},
+	"smbfs":     {},
+	"sshfs":     {},
+	"webdav":    {},
+}
+
+// isNetworkFilesystemType returns true if and only if the specified file system
+// type is a network or FUSE file system. FUSE file system types are often
+// reported with a subtype, such as "fuse.sshfs", and are all considered slow.
+func isNetworkFilesystemType(fsType string) bool {
+	if strings.HasPrefix(fsType, "fuse.") {
+		return true
+	}
+	_, ok := networkFilesystemTypes[fsType]
+	return ok
+}
+
+// parseMountTable parses a mount table in the format of /proc/self/mounts, in
+// which each line has the mounted device, the mount point, and the file system
+// type, followed by additional fields this library ignores. Spaces and other
+// special characters in the mount point are encoded as octal escape sequences.
+func parseMountTable(r io.Reader) ([]mountEntry, error) {
+	var entries []mountEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue // ignore malformed lines
+		}
+		entries = append(entries, mountEntry{device: unescapeMountField(fields[0]), mountPoint: unescapeMountField(fields[1]), fsType: fields[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// unescapeMountField converts octal escape sequences, such as `\040` for a
+// space, back to the characters they represent.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// mountTable maps each cleaned mount point to whether the file system mounted
+// there is a network or FUSE file system.
+type mountTable map[string]bool
+
+// newMountTable returns a mountTable for the provided entries. When a mount
+// point appears more than once, the last entry wins, because later mounts hide
+// earlier ones.
+func newMountTable(entries []mountEntry) mountTable {
+	mt := make(mountTable, len(entries))
+	for _, entry := range entries {
+		mt[filepath.Clean(entry.mountPoint)] = isNetworkFilesystemType(entry.fsType)
+	}
+	return mt
+}
+
+// isNetwork returns true if and only if the specified absolute pathname resides
+// on a network or FUSE file system, as determined by the nearest mount point at
+// or above the pathname.
+func (mt mountTable) isNetwork(osPathname string) bool {
+	for {
+		if isNetwork, ok := mt[osPathname]; ok {
+			return isNetwork
+		}
+		parent := filepath.Dir(osPathname)
+		if parent == osPathname {
+			return false
+		}
+		osPathname = parent
+	}
+}
+
+// mountDevices maps each cleaned mount point to the device mounted there.
+type mountDevices map[string]string
+
+// newMountDevices returns a mountDevices for the provided entries. As with
+// newMountTable, when a mount point appears more than once, the last entry
+// wins.
+func newMountDevices(entries []mountEntry) mountDevices {
+	md := make(mountDevices, len(entries))
+	for _, entry := range entries {
+		md[filepath.Clean(entry.mountPoint)] = entry.device
+	}
+	return md
+}
+
+// device returns the device mounted at the nearest mount point at or above the
+// specified absolute pathname, or false when there is no such mount point.
+func (md mountDevices) device(osPathname string) (string, bool) {
+	for {
+		if device, ok := md[osPathname]; ok {
+			return device, true
+		}
+		parent := filepath.Dir(osPathname)
+		if parent == osPathname {
+			return "", false
+		}
+		osPathname = parent
+	}
+}
@@ -0,0 +1,27 @@
+package godirwalk
+
+import "os"
+
+// loadMountTable returns the table of file systems mounted in the mount
+// namespace of this process.
+func loadMountTable() (mountTable, error) {
+	entries, err := loadMountEntries()
+	if err != nil {
+		return nil, err
+	}
+	return newMountTable(entries), nil
+}
+
+// loadMountEntries returns the entries of the mount table of the mount
+// namespace of this process.
+func loadMountEntries() ([]mountEntry, error) {
+	fh, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseMountTable(fh)
+	if er := fh.Close(); err == nil {
+		err = er
+	}
+	return entries, err
+}
@@ -0,0 +1,7 @@
+// +build !linux
+
+package godirwalk
+
+// loadMountTable returns an empty mount table, because detection of network
+// file systems is only supported on Linux.
+func loadMountTable() (mountTable, error) { return nil, nil }
@@ -0,0 +1,89 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsNetworkFilesystemType(t *testing.T) {
+	for fsType, want := range map[string]bool{
+		"btrfs":      false,
+		"cifs":       true,
+		"ext4":       false,
+		"fuse.sshfs": true,
+		"fuseblk":    true,
+		"nfs":        true,
+		"nfs4":       true,
+		"proc":       false,
+		"tmpfs":      false,
+		"xfs":        false,
+	} {
+		if got := isNetworkFilesystemType(fsType); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", fsType, got, want)
+		}
+	}
+}
+
+func TestMountTableIsNetwork(t *testing.T) {
+	const mounts = `/dev/sda1 / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+server:/export /mnt/nfs nfs4 rw,relatime 0 0
+/dev/sdb1 /mnt/nfs/local ext4 rw,relatime 0 0
+//server/share /mnt/my\040share cifs rw,relatime 0 0
+sshfs#user@host: /home/user/remote fuse.sshfs rw,nosuid,nodev 0 0
+malformed
+`
+	entries, err := parseMountTable(strings.NewReader(mounts))
+	ensureError(t, err)
+	if got, want := len(entries), 6; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	mt := newMountTable(entries)
+
+	for pathname, want := range map[string]bool{
+		"/":                     false,
+		"/home/user":            false,
+		"/home/user/remote":     true,
+		"/home/user/remote/a/b": true,
+		"/mnt":                  false,
+		"/mnt/my share/d":       true,
+		"/mnt/nfs":              true,
+		"/mnt/nfs/d":            true,
+		"/mnt/nfs/local/d":      false,
+		"/mnt/nfsx":             false,
+		"/proc/self":            false,
+	} {
+		if got := mt.isNetwork(filepath.FromSlash(pathname)); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", pathname, got, want)
+		}
+	}
+}
+
+func TestMountDevices(t *testing.T) {
+	const mounts = `/dev/sda1 / ext4 rw,relatime 0 0
+/dev/sdb1 /srv xfs rw,relatime,prjquota 0 0
+/dev/sdc1 /srv xfs rw,relatime,prjquota 0 0
+/dev/mapper/my\040vg /mnt/my\040share ext4 rw,relatime 0 0
+`
+	entries, err := parseMountTable(strings.NewReader(mounts))
+	ensureError(t, err)
+	md := newMountDevices(entries)
+
+	for pathname, want := range map[string]string{
+		"/":               "/dev/sda1",
+		"/home":           "/dev/sda1",
+		"/srv/projects/a": "/dev/sdc1", // later mount hides earlier
+		"/mnt/my share/d": "/dev/mapper/my vg",
+	} {
+		got, ok := md.device(filepath.FromSlash(pathname))
+		if !ok || got != want {
+			t.Errorf("%s: GOT: %q, %v; WANT: %q, true", pathname, got, ok, want)
+		}
+	}
+
+	if _, ok := newMountDevices(nil).device(filepath.FromSlash("/srv")); ok {
+		t.Errorf("GOT: %v; WANT: %v", ok, false)
+	}
+}
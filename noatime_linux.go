@@ -0,0 +1,7 @@
+package godirwalk
+
+import "syscall"
+
+// oNoatime is the flag that directs open(2) not to update the access time of
+// the file.
+const oNoatime = syscall.O_NOATIME
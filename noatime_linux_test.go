@@ -0,0 +1,56 @@
+package godirwalk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWalkNoATime(t *testing.T) {
+	root, cleanup := setupTree(t, "f")
+	defer cleanup()
+	osFilename := filepath.Join(root, "f")
+
+	// Access times are set well in the past before each walk, so that even
+	// file systems mounted with relatime update them.
+	past := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+
+	// readAccessTime walks root, reading f using Open, and returns the access
+	// time of f afterwards.
+	readAccessTime := func(t *testing.T, noATime bool) time.Time {
+		t.Helper()
+		ensureError(t, os.Chtimes(osFilename, past, past))
+		err := Walk(root, &Options{
+			NoATime: noATime,
+			Callback: func(_ string, de *Dirent) error {
+				if !de.IsRegular() {
+					return nil
+				}
+				fh, err := de.Open()
+				if err != nil {
+					return err
+				}
+				_, err = ioutil.ReadAll(fh)
+				if er := fh.Close(); err == nil {
+					err = er
+				}
+				return err
+			},
+		})
+		ensureError(t, err)
+		fi, err := os.Stat(osFilename)
+		ensureError(t, err)
+		st := fi.Sys().(*syscall.Stat_t)
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	}
+
+	if !readAccessTime(t, false).After(past) {
+		t.Skip("file system does not update access times")
+	}
+	if got, want := readAccessTime(t, true), past; !got.Equal(want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,7 @@
+// +build !linux
+
+package godirwalk
+
+// oNoatime is zero, because this library only knows how to open files without
+// updating their access times on Linux.
+const oNoatime = 0
@@ -0,0 +1,510 @@
+/*
+Package parketsink writes metadata about the file system nodes in a directory
+hierarchy as an Apache Parquet file, and reads such files.
+
+Each node becomes one row with the following columns:
+
+	path   BYTE_ARRAY (UTF8)              pathname of the node, including the root
+	name   BYTE_ARRAY (UTF8)              basename of the node
+	is_dir BOOLEAN                        whether the node is a directory
+	size   INT64                          size of the node in bytes, as reported by lstat
+	mtime  INT64 (TIMESTAMP_MICROS)       modification time of the node
+
+Files are written with PLAIN encoded, uncompressed data pages, which every
+Parquet implementation is able to read.
+
+This package encodes and decodes the file format itself, rather than using a
+Parquet library. The godirwalk module has no dependencies, and supports Go
+versions far older than those the Go Parquet libraries require, each of which
+would also bring a number of compression and encoding modules along with it.
+Because the files written use only the simplest features of the format, which
+amount to a handful of Thrift structures and fixed width or length prefixed
+values, they are written with much less code than a dependency would add. As
+a consequence, the reader only supports files using the features the writer
+uses, and files written by other implementations should be read using a
+Parquet library.
+*/
+package parketsink
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/karrick/godirwalk"
+)
+
+// Row is the Go representation of a single row of a file written by
+// WriteParquet.
+type Row struct {
+	Path  string
+	Name  string
+	IsDir bool
+	Size  int64
+	Mtime int64 // microseconds since the Unix epoch
+}
+
+const magic = "PAR1"
+
+// createdBy is recorded in the metadata of files written by this package.
+const createdBy = "github.com/karrick/godirwalk/parketsink"
+
+// Values from the Parquet Thrift definitions.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repetitionRequired = 0
+
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageTypeData = 0
+)
+
+// columns describes the schema of the files written by this package, in the
+// order the columns are stored.
+var columns = []struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+}{
+	{"path", typeByteArray, convertedUTF8},
+	{"name", typeByteArray, convertedUTF8},
+	{"is_dir", typeBoolean, convertedNone},
+	{"size", typeInt64, convertedNone},
+	{"mtime", typeInt64, convertedTimestampMicros},
+}
+
+const (
+	colPath = iota
+	colName
+	colIsDir
+	colSize
+	colMtime
+	numColumns
+)
+
+// rowGroupSize is the number of rows buffered in memory before they are
+// written to the file as a row group.
+var rowGroupSize = 64 * 1024
+
+// maxFooterSize limits the size of file metadata the reader will allocate
+// memory for, protecting against corrupt files.
+const maxFooterSize = 64 << 20
+
+// WriteParquet walks the file system hierarchy rooted at the specified
+// directory, and writes a Parquet file to w with one row for each node it
+// encounters.
+//
+// Only the fields of the provided Options, which may be nil, that
+// godirwalk.HelperOptions copies are used.
+func WriteParquet(w io.Writer, root string, opts *godirwalk.Options) error {
+	options := godirwalk.HelperOptions(opts)
+
+	pw := &writer{w: bufio.NewWriter(w)}
+	if err := pw.write([]byte(magic)); err != nil {
+		return err
+	}
+
+	options.Callback = func(osPathname string, de *godirwalk.Dirent) error {
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		return pw.append(Row{
+			Path:  osPathname,
+			Name:  de.Name(),
+			IsDir: de.IsDir(),
+			Size:  fi.Size(),
+			Mtime: fi.ModTime().UnixNano() / 1000,
+		})
+	}
+
+	if err := godirwalk.Walk(root, &options); err != nil {
+		return err
+	}
+	return pw.close()
+}
+
+// writer buffers rows in columnar form, and writes them as row groups.
+type writer struct {
+	w         *bufio.Writer
+	offset    int64
+	numRows   int64
+	rowGroups [][]byte // encoded RowGroup structs
+
+	// values holds the PLAIN encoding of the buffered values of each column,
+	// except for is_dir, whose values are bit-packed when the row group is
+	// written.
+	values [numColumns][]byte
+	isDir  []bool
+}
+
+func (pw *writer) write(buf []byte) error {
+	n, err := pw.w.Write(buf)
+	pw.offset += int64(n)
+	return err
+}
+
+func (pw *writer) append(row Row) error {
+	pw.values[colPath] = appendByteArray(pw.values[colPath], row.Path)
+	pw.values[colName] = appendByteArray(pw.values[colName], row.Name)
+	pw.isDir = append(pw.isDir, row.IsDir)
+	pw.values[colSize] = appendInt64(pw.values[colSize], row.Size)
+	pw.values[colMtime] = appendInt64(pw.values[colMtime], row.Mtime)
+	if len(pw.isDir) == rowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+func appendByteArray(buf []byte, s string) []byte {
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], uint32(len(s)))
+	return append(append(buf, scratch[:]...), s...)
+}
+
+func appendInt64(buf []byte, v int64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], uint64(v))
+	return append(buf, scratch[:]...)
+}
+
+// flush writes the buffered rows as a row group, where each column chunk
+// consists of a single data page.
+func (pw *writer) flush() error {
+	numRows := len(pw.isDir)
+	if numRows == 0 {
+		return nil
+	}
+
+	bits := make([]byte, (numRows+7)/8)
+	for i, v := range pw.isDir {
+		if v {
+			bits[i/8] |= 1 << uint(i%8)
+		}
+	}
+	pw.values[colIsDir] = bits
+
+	var chunks [][]byte
+	var totalSize int64
+
+	for i, column := range columns {
+		data := pw.values[i]
+
+		var dph thriftStruct
+		dph.i32(1, int32(numRows))
+		dph.i32(2, encodingPlain)
+		dph.i32(3, encodingRLE)
+		dph.i32(4, encodingRLE)
+
+		var ph thriftStruct
+		ph.i32(1, pageTypeData)
+		ph.i32(2, int32(len(data)))
+		ph.i32(3, int32(len(data)))
+		ph.structure(5, dph.bytes())
+		header := ph.bytes()
+
+		pageOffset := pw.offset
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+		chunkSize := int64(len(header) + len(data))
+		totalSize += chunkSize
+
+		var encodings [][]byte
+		for _, e := range []int64{encodingPlain, encodingRLE} {
+			encodings = append(encodings, appendZigzag(nil, e))
+		}
+
+		var md thriftStruct
+		md.i32(1, column.physicalType)
+		md.list(2, ctI32, encodings)
+		md.list(3, ctBinary, [][]byte{thriftString(column.name)})
+		md.i32(4, codecUncompressed)
+		md.i64(5, int64(numRows))
+		md.i64(6, chunkSize)
+		md.i64(7, chunkSize)
+		md.i64(9, pageOffset)
+
+		var cc thriftStruct
+		cc.i64(2, pageOffset)
+		cc.structure(3, md.bytes())
+		chunks = append(chunks, cc.bytes())
+	}
+
+	var rg thriftStruct
+	rg.list(1, ctStruct, chunks)
+	rg.i64(2, totalSize)
+	rg.i64(3, int64(numRows))
+	pw.rowGroups = append(pw.rowGroups, rg.bytes())
+	pw.numRows += int64(numRows)
+
+	for i := range pw.values {
+		pw.values[i] = pw.values[i][:0]
+	}
+	pw.isDir = pw.isDir[:0]
+	return nil
+}
+
+// thriftString returns the compact protocol encoding of s as a list element.
+func thriftString(s string) []byte {
+	return append(appendUvarint(nil, uint64(len(s))), s...)
+}
+
+// close writes any buffered rows followed by the file metadata.
+func (pw *writer) close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var root thriftStruct
+	root.str(4, "schema")
+	root.i32(5, int32(len(columns)))
+	schema := [][]byte{root.bytes()}
+
+	for _, column := range columns {
+		var se thriftStruct
+		se.i32(1, column.physicalType)
+		se.i32(3, repetitionRequired)
+		se.str(4, column.name)
+		if column.convertedType != convertedNone {
+			se.i32(6, column.convertedType)
+		}
+		schema = append(schema, se.bytes())
+	}
+
+	var fmd thriftStruct
+	fmd.i32(1, 1)
+	fmd.list(2, ctStruct, schema)
+	fmd.i64(3, pw.numRows)
+	fmd.list(4, ctStruct, pw.rowGroups)
+	fmd.str(6, createdBy)
+	footer := fmd.bytes()
+
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:], uint32(len(footer)))
+	copy(trailer[4:], magic)
+
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+	if err := pw.write(trailer[:]); err != nil {
+		return err
+	}
+	return pw.w.Flush()
+}
+
+// ReadParquet returns the rows of a Parquet file written by WriteParquet,
+// which is read from r and is size bytes long.
+func ReadParquet(r io.ReaderAt, size int64) ([]Row, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, errors.New("cannot read Parquet file: file too short")
+	}
+	var head [4]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, fmt.Errorf("cannot read Parquet file: %s", err)
+	}
+	var trailer [8]byte
+	if _, err := r.ReadAt(trailer[:], size-8); err != nil {
+		return nil, fmt.Errorf("cannot read Parquet file: %s", err)
+	}
+	if string(head[:]) != magic || string(trailer[4:]) != magic {
+		return nil, errors.New("cannot read Parquet file: missing magic number")
+	}
+
+	footerSize := int64(binary.LittleEndian.Uint32(trailer[:]))
+	if footerSize > maxFooterSize || footerSize > size-int64(len(magic)+len(trailer)) {
+		return nil, fmt.Errorf("cannot read Parquet file: invalid metadata size %d", footerSize)
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-8-footerSize); err != nil {
+		return nil, fmt.Errorf("cannot read Parquet file: %s", err)
+	}
+
+	fmd, err := (&thriftDecoder{buf: footer}).readStruct(0)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSchema(fmd); err != nil {
+		return nil, err
+	}
+
+	rowGroups, _ := fieldList(fmd, 4)
+	var rows []Row
+
+	for _, v := range rowGroups {
+		rg, ok := v.(map[int16]interface{})
+		if !ok {
+			return nil, errors.New("cannot read Parquet file: invalid row group")
+		}
+		numRows, ok := fieldInt(rg, 3)
+		if !ok || numRows < 0 || numRows > size {
+			// Every row occupies at least one byte.
+			return nil, errors.New("cannot read Parquet file: invalid row group")
+		}
+		chunks, _ := fieldList(rg, 1)
+		if len(chunks) != len(columns) {
+			return nil, fmt.Errorf("cannot read Parquet file: row group has %d columns; expected %d", len(chunks), len(columns))
+		}
+
+		group := make([]Row, numRows)
+		for _, v := range chunks {
+			cc, _ := v.(map[int16]interface{})
+			if err = readColumnChunk(r, size, cc, group); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, group...)
+	}
+
+	return rows, nil
+}
+
+// checkSchema returns an error unless the schema in the file metadata is the
+// one written by this package.
+func checkSchema(fmd map[int16]interface{}) error {
+	schema, _ := fieldList(fmd, 2)
+	if len(schema) != len(columns)+1 {
+		return fmt.Errorf("cannot read Parquet file: schema has %d elements; expected %d", len(schema), len(columns)+1)
+	}
+	for i, column := range columns {
+		se, _ := schema[i+1].(map[int16]interface{})
+		name, _ := fieldBytes(se, 4)
+		physicalType, _ := fieldInt(se, 1)
+		repetition, ok := fieldInt(se, 3)
+		if string(name) != column.name || physicalType != int64(column.physicalType) || !ok || repetition != repetitionRequired {
+			return fmt.Errorf("cannot read Parquet file: unsupported schema for column %d", i)
+		}
+	}
+	return nil
+}
+
+// readColumnChunk decodes the values of a column chunk into the corresponding
+// field of each row.
+func readColumnChunk(r io.ReaderAt, size int64, cc map[int16]interface{}, rows []Row) error {
+	md, ok := fieldStruct(cc, 3)
+	if !ok {
+		return errors.New("cannot read Parquet file: column chunk missing metadata")
+	}
+
+	path, _ := fieldList(md, 3)
+	column := -1
+	if len(path) == 1 {
+		name, _ := path[0].([]byte)
+		for i, c := range columns {
+			if c.name == string(name) {
+				column = i
+			}
+		}
+	}
+	if column < 0 {
+		return errors.New("cannot read Parquet file: column chunk for unknown column")
+	}
+	if codec, _ := fieldInt(md, 4); codec != codecUncompressed {
+		return fmt.Errorf("cannot read Parquet file: unsupported compression codec %d", codec)
+	}
+
+	offset, _ := fieldInt(md, 9)
+	chunkSize, _ := fieldInt(md, 7)
+	if offset < int64(len(magic)) || chunkSize < 0 || chunkSize > size-offset {
+		return errors.New("cannot read Parquet file: invalid column chunk location")
+	}
+	chunk := make([]byte, chunkSize)
+	if _, err := r.ReadAt(chunk, offset); err != nil {
+		return fmt.Errorf("cannot read Parquet file: %s", err)
+	}
+
+	var row int
+	d := &thriftDecoder{buf: chunk}
+
+	for row < len(rows) {
+		ph, err := d.readStruct(0)
+		if err != nil {
+			return err
+		}
+		if pageType, _ := fieldInt(ph, 1); pageType != pageTypeData {
+			return fmt.Errorf("cannot read Parquet file: unsupported page type %d", pageType)
+		}
+		dph, _ := fieldStruct(ph, 5)
+		numValues, _ := fieldInt(dph, 1)
+		if encoding, _ := fieldInt(dph, 2); encoding != encodingPlain {
+			return fmt.Errorf("cannot read Parquet file: unsupported encoding %d", encoding)
+		}
+		pageSize, _ := fieldInt(ph, 3)
+		if pageSize < 0 || pageSize > int64(len(d.buf)) || numValues < 0 || numValues > int64(len(rows)-row) {
+			return errors.New("cannot read Parquet file: invalid page")
+		}
+		data := d.buf[:pageSize]
+		d.buf = d.buf[pageSize:]
+
+		if _, err = decodePlain(columns[column].physicalType, data, column, rows[row:row+int(numValues)]); err != nil {
+			return err
+		}
+		row += int(numValues)
+	}
+
+	return nil
+}
+
+var errPageTruncated = errors.New("cannot read Parquet file: truncated page")
+
+// decodePlain decodes PLAIN encoded values from data into the specified column
+// of rows, and returns the remaining data.
+func decodePlain(physicalType int32, data []byte, column int, rows []Row) ([]byte, error) {
+	switch physicalType {
+	case typeBoolean:
+		if len(data) < (len(rows)+7)/8 {
+			return nil, errPageTruncated
+		}
+		for i := range rows {
+			rows[i].IsDir = data[i/8]&(1<<uint(i%8)) != 0
+		}
+		return data[(len(rows)+7)/8:], nil
+	case typeInt64:
+		if len(data) < 8*len(rows) {
+			return nil, errPageTruncated
+		}
+		for i := range rows {
+			v := int64(binary.LittleEndian.Uint64(data[8*i:]))
+			if column == colSize {
+				rows[i].Size = v
+			} else {
+				rows[i].Mtime = v
+			}
+		}
+		return data[8*len(rows):], nil
+	default:
+		for i := range rows {
+			if len(data) < 4 {
+				return nil, errPageTruncated
+			}
+			l := binary.LittleEndian.Uint32(data)
+			if uint64(l) > uint64(len(data)-4) {
+				return nil, errPageTruncated
+			}
+			v := string(data[4 : 4+l])
+			if column == colPath {
+				rows[i].Path = v
+			} else {
+				rows[i].Name = v
+			}
+			data = data[4+l:]
+		}
+		return data, nil
+	}
+}
@@ -0,0 +1,168 @@
+package parketsink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThriftRoundTrip(t *testing.T) {
+	var nested thriftStruct
+	nested.i64(1, -1)
+
+	var s thriftStruct
+	s.i32(1, 42)
+	s.i64(20, 1<<40) // delta too large to encode in field header
+	s.str(21, "hello")
+	s.structure(22, nested.bytes())
+	var elems [][]byte
+	for i := 0; i < 20; i++ {
+		elems = append(elems, appendZigzag(nil, int64(i)))
+	}
+	s.list(23, ctI32, elems) // long enough to require separate size
+
+	fields, err := (&thriftDecoder{buf: s.bytes()}).readStruct(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := fieldInt(fields, 1); got != 42 {
+		t.Errorf("GOT: %v; WANT: %v", got, 42)
+	}
+	if got, _ := fieldInt(fields, 20); got != 1<<40 {
+		t.Errorf("GOT: %v; WANT: %v", got, int64(1<<40))
+	}
+	if got, _ := fieldBytes(fields, 21); string(got) != "hello" {
+		t.Errorf("GOT: %q; WANT: %q", got, "hello")
+	}
+	n, _ := fieldStruct(fields, 22)
+	if got, _ := fieldInt(n, 1); got != -1 {
+		t.Errorf("GOT: %v; WANT: %v", got, -1)
+	}
+	list, _ := fieldList(fields, 23)
+	if got, want := len(list), 20; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := list[19], int64(19); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWriteParquetReadParquet(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err = os.Mkdir(filepath.Join(root, "d1"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(root, "d1/f1"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = WriteParquet(&buf, root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadParquet(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(root, "d1/f1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Walk is sorted, so the order of the rows is deterministic.
+	expected := []Row{
+		{Path: root, Name: filepath.Base(root), IsDir: true},
+		{Path: filepath.Join(root, "d1"), Name: "d1", IsDir: true},
+		{Path: filepath.Join(root, "d1/f1"), Name: "f1", Size: 6, Mtime: fi.ModTime().UnixNano() / 1000},
+	}
+	if got, want := len(rows), len(expected); got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, want := range expected {
+		got := rows[i]
+		if got.IsDir {
+			// Sizes and times of directories vary by platform.
+			got.Size, got.Mtime = 0, 0
+		}
+		if got != want {
+			t.Errorf("GOT: %#v; WANT: %#v", got, want)
+		}
+	}
+}
+
+func TestWriteParquetMultipleRowGroups(t *testing.T) {
+	defer func(size int) { rowGroupSize = size }(rowGroupSize)
+	rowGroupSize = 4
+
+	root, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for i := 0; i < 10; i++ {
+		if err = ioutil.WriteFile(filepath.Join(root, fmt.Sprintf("f%d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = WriteParquet(&buf, root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadParquet(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rows), 11; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, row := range rows[1:] {
+		if got, want := row.Name, fmt.Sprintf("f%d", i); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if row.IsDir {
+			t.Errorf("GOT: %v; WANT: %v", row.IsDir, false)
+		}
+	}
+}
+
+func TestReadParquetErrors(t *testing.T) {
+	t.Run("not parquet", func(t *testing.T) {
+		buf := []byte("this is not a parquet file")
+		if _, err := ReadParquet(bytes.NewReader(buf), int64(len(buf))); err == nil {
+			t.Errorf("GOT: %v; WANT: error", err)
+		}
+	})
+
+	t.Run("missing schema", func(t *testing.T) {
+		root, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+
+		var buf bytes.Buffer
+		if err = WriteParquet(&buf, root, nil); err != nil {
+			t.Fatal(err)
+		}
+		// Replace the metadata with an empty struct, which lacks the schema.
+		b := buf.Bytes()
+		corrupt := append(append([]byte(nil), b[:len(b)-8-footerSize(b)]...), 0, 1, 0, 0, 0, 'P', 'A', 'R', '1')
+		if _, err = ReadParquet(bytes.NewReader(corrupt), int64(len(corrupt))); err == nil {
+			t.Errorf("GOT: %v; WANT: error", err)
+		}
+	})
+}
+
+func footerSize(b []byte) int { return int(binary.LittleEndian.Uint32(b[len(b)-8:])) }
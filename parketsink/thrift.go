@@ -0,0 +1,266 @@
+package parketsink
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Parquet file metadata is serialized using the Thrift compact protocol. Only
+// the subset of the protocol required to encode and decode the structures
+// used by this package is implemented here.
+
+// Thrift compact protocol type identifiers.
+const (
+	ctStop      = 0
+	ctBoolTrue  = 1
+	ctBoolFalse = 2
+	ctByte      = 3
+	ctI16       = 4
+	ctI32       = 5
+	ctI64       = 6
+	ctDouble    = 7
+	ctBinary    = 8
+	ctList      = 9
+	ctSet       = 10
+	ctMap       = 11
+	ctStruct    = 12
+)
+
+// maxThriftDepth limits how deeply nested structures may be when decoding,
+// protecting against corrupt metadata.
+const maxThriftDepth = 32
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutUvarint(scratch[:], v)]...)
+}
+
+func appendZigzag(buf []byte, v int64) []byte {
+	return appendUvarint(buf, uint64(v<<1)^uint64(v>>63))
+}
+
+// thriftStruct accumulates the compact protocol encoding of a single struct.
+// Fields must be added in increasing order of their identifiers.
+type thriftStruct struct {
+	buf  []byte
+	last int16
+}
+
+func (s *thriftStruct) header(id int16, typ byte) {
+	if delta := id - s.last; delta > 0 && delta <= 15 {
+		s.buf = append(s.buf, byte(delta)<<4|typ)
+	} else {
+		s.buf = append(s.buf, typ)
+		s.buf = appendZigzag(s.buf, int64(id))
+	}
+	s.last = id
+}
+
+func (s *thriftStruct) i32(id int16, v int32) {
+	s.header(id, ctI32)
+	s.buf = appendZigzag(s.buf, int64(v))
+}
+
+func (s *thriftStruct) i64(id int16, v int64) {
+	s.header(id, ctI64)
+	s.buf = appendZigzag(s.buf, v)
+}
+
+func (s *thriftStruct) str(id int16, v string) {
+	s.header(id, ctBinary)
+	s.buf = appendUvarint(s.buf, uint64(len(v)))
+	s.buf = append(s.buf, v...)
+}
+
+func (s *thriftStruct) structure(id int16, v []byte) {
+	s.header(id, ctStruct)
+	s.buf = append(s.buf, v...)
+}
+
+// list appends a list field whose elements, of type elemType, have already been
+// encoded.
+func (s *thriftStruct) list(id int16, elemType byte, elems [][]byte) {
+	s.header(id, ctList)
+	if len(elems) < 15 {
+		s.buf = append(s.buf, byte(len(elems))<<4|elemType)
+	} else {
+		s.buf = append(s.buf, 0xf0|elemType)
+		s.buf = appendUvarint(s.buf, uint64(len(elems)))
+	}
+	for _, elem := range elems {
+		s.buf = append(s.buf, elem...)
+	}
+}
+
+// bytes terminates the struct and returns its encoding.
+func (s *thriftStruct) bytes() []byte { return append(s.buf, ctStop) }
+
+// thriftDecoder decodes compact protocol encoded values into generic Go
+// values: structs become map[int16]interface{} keyed by field identifier,
+// lists and sets become []interface{}, integers become int64, and binary
+// values become []byte. Maps and doubles are decoded and discarded.
+type thriftDecoder struct {
+	buf []byte
+}
+
+var errThriftTruncated = errors.New("cannot decode metadata: truncated")
+
+func (d *thriftDecoder) byte() (byte, error) {
+	if len(d.buf) == 0 {
+		return 0, errThriftTruncated
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b, nil
+}
+
+func (d *thriftDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		return 0, errThriftTruncated
+	}
+	d.buf = d.buf[n:]
+	return v, nil
+}
+
+func (d *thriftDecoder) zigzag() (int64, error) {
+	v, err := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (d *thriftDecoder) readStruct(depth int) (map[int16]interface{}, error) {
+	if depth > maxThriftDepth {
+		return nil, errors.New("cannot decode metadata: structures nested too deeply")
+	}
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		typ := b & 0x0f
+		if typ == ctStop {
+			return fields, nil
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := d.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		switch typ {
+		case ctBoolTrue:
+			fields[id] = true
+		case ctBoolFalse:
+			fields[id] = false
+		default:
+			if fields[id], err = d.readValue(typ, depth); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *thriftDecoder) readValue(typ byte, depth int) (interface{}, error) {
+	switch typ {
+	case ctBoolTrue, ctBoolFalse:
+		// Booleans outside of struct field headers occupy a single byte.
+		b, err := d.byte()
+		return b == ctBoolTrue, err
+	case ctByte:
+		b, err := d.byte()
+		return int64(int8(b)), err
+	case ctI16, ctI32, ctI64:
+		return d.zigzag()
+	case ctDouble:
+		if len(d.buf) < 8 {
+			return nil, errThriftTruncated
+		}
+		d.buf = d.buf[8:]
+		return nil, nil
+	case ctBinary:
+		l, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if l > uint64(len(d.buf)) {
+			return nil, errThriftTruncated
+		}
+		v := d.buf[:l]
+		d.buf = d.buf[l:]
+		return v, nil
+	case ctList, ctSet:
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(b >> 4)
+		if size == 15 {
+			if size, err = d.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > uint64(len(d.buf)) {
+			// Every element occupies at least one byte.
+			return nil, errThriftTruncated
+		}
+		elems := make([]interface{}, size)
+		for i := range elems {
+			if elems[i], err = d.readValue(b&0x0f, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	case ctMap:
+		size, err := d.uvarint()
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		types, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, err = d.readValue(types>>4, depth+1); err != nil {
+				return nil, err
+			}
+			if _, err = d.readValue(types&0x0f, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case ctStruct:
+		return d.readStruct(depth + 1)
+	default:
+		return nil, fmt.Errorf("cannot decode metadata: unsupported type %d", typ)
+	}
+}
+
+// Accessors for fields of decoded structs. Each returns false when the field
+// is absent or holds a value of a different type.
+
+func fieldInt(fields map[int16]interface{}, id int16) (int64, bool) {
+	v, ok := fields[id].(int64)
+	return v, ok
+}
+
+func fieldBytes(fields map[int16]interface{}, id int16) ([]byte, bool) {
+	v, ok := fields[id].([]byte)
+	return v, ok
+}
+
+func fieldStruct(fields map[int16]interface{}, id int16) (map[int16]interface{}, bool) {
+	v, ok := fields[id].(map[int16]interface{})
+	return v, ok
+}
+
+func fieldList(fields map[int16]interface{}, id int16) ([]interface{}, bool) {
+	v, ok := fields[id].([]interface{})
+	return v, ok
+}
@@ -0,0 +1,24 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// joinPathname returns the pathname of the specified child of the specified
+// directory, equivalent to filepath.Join(osDirname, name), without the work
+// filepath.Join does to clean the result. The osDirname argument must already
+// be clean, and name must be a single pathname component, as is always the
+// case while walking a directory hierarchy.
+func joinPathname(osDirname, name string) string {
+	if osDirname == "." {
+		return name
+	}
+	if filepath.VolumeName(osDirname) != "" {
+		return filepath.Join(osDirname, name) // let standard library handle volume name edge cases
+	}
+	if l := len(osDirname); l > 0 && os.IsPathSeparator(osDirname[l-1]) {
+		return osDirname + name
+	}
+	return osDirname + string(os.PathSeparator) + name
+}
@@ -0,0 +1,31 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinPathname(t *testing.T) {
+	for _, osDirname := range []string{".", "/", "a", "/a", "a/b", "../a", ".."} {
+		osDirname = filepath.FromSlash(osDirname)
+		if got, want := joinPathname(osDirname, "c"), filepath.Join(osDirname, "c"); got != want {
+			t.Errorf("%q: GOT: %q; WANT: %q", osDirname, got, want)
+		}
+	}
+}
+
+var benchmarkPathname string
+
+func BenchmarkJoinPathnameStandardLibrary(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkPathname = filepath.Join(goPrefix, maxName)
+	}
+}
+
+func BenchmarkJoinPathnameThisLibrary(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkPathname = joinPathname(goPrefix, maxName)
+	}
+}
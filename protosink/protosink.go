@@ -0,0 +1,244 @@
+/*
+Package protosink writes metadata about the file system nodes in a directory
+hierarchy as a stream of Protocol Buffer messages, and reads such streams.
+
+Each node is encoded as a DirentProto message, defined in dirent.proto, and
+prefixed by its length encoded as a varint, which is the same framing used by
+the parseDelimitedFrom and writeDelimitedTo functions of other Protocol Buffer
+implementations, so streams may be consumed by programs written in other
+languages. This package encodes and decodes the wire format itself, so that it
+does not require a Protocol Buffer runtime dependency.
+*/
+package protosink
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/karrick/godirwalk"
+)
+
+// DirentProto is the Go representation of the DirentProto message defined in
+// dirent.proto.
+type DirentProto struct {
+	Path     string
+	Name     string
+	ModeType uint32
+	Size     int64
+	Mtime    int64 // nanoseconds since the Unix epoch
+}
+
+// Field numbers and wire types from dirent.proto.
+const (
+	fieldPath     = 1
+	fieldName     = 2
+	fieldModeType = 3
+	fieldSize     = 4
+	fieldMtime    = 5
+
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+// maxMessageSize limits the size of a message Decoder will allocate memory for,
+// protecting against corrupt streams.
+const maxMessageSize = 1 << 20
+
+// appendUvarint appends the varint encoding of v to buf.
+func appendUvarint(buf []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutUvarint(scratch[:], v)]...)
+}
+
+// Marshal returns the Protocol Buffer wire encoding of the message. As in
+// proto3, fields with zero-values are omitted.
+func (m *DirentProto) Marshal() []byte {
+	var buf []byte
+	appendString := func(field int, s string) {
+		if s != "" {
+			buf = appendUvarint(buf, uint64(field<<3|wireBytes))
+			buf = appendUvarint(buf, uint64(len(s)))
+			buf = append(buf, s...)
+		}
+	}
+	appendVarint := func(field int, v uint64) {
+		if v != 0 {
+			buf = appendUvarint(buf, uint64(field<<3|wireVarint))
+			buf = appendUvarint(buf, v)
+		}
+	}
+	appendString(fieldPath, m.Path)
+	appendString(fieldName, m.Name)
+	appendVarint(fieldModeType, uint64(m.ModeType))
+	appendVarint(fieldSize, uint64(m.Size)) // int64 fields encode negative values as ten byte varints
+	appendVarint(fieldMtime, uint64(m.Mtime))
+	return buf
+}
+
+// Unmarshal decodes the Protocol Buffer wire encoding of the message into m.
+// Unknown fields are skipped, so messages written by newer versions of this
+// package may be read by older versions.
+func (m *DirentProto) Unmarshal(buf []byte) error {
+	*m = DirentProto{}
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return errors.New("cannot decode field tag")
+		}
+		buf = buf[n:]
+		field, wireType := int(tag>>3), int(tag&7)
+
+		switch wireType {
+		case wireVarint:
+			v, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return fmt.Errorf("cannot decode varint for field %d", field)
+			}
+			buf = buf[n:]
+			switch field {
+			case fieldModeType:
+				m.ModeType = uint32(v)
+			case fieldSize:
+				m.Size = int64(v)
+			case fieldMtime:
+				m.Mtime = int64(v)
+			}
+		case wireBytes:
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || l > uint64(len(buf)-n) {
+				return fmt.Errorf("cannot decode length of field %d", field)
+			}
+			s := string(buf[n : n+int(l)])
+			buf = buf[n+int(l):]
+			switch field {
+			case fieldPath:
+				m.Path = s
+			case fieldName:
+				m.Name = s
+			}
+		case wireI64:
+			if len(buf) < 8 {
+				return fmt.Errorf("cannot decode field %d", field)
+			}
+			buf = buf[8:]
+		case wireI32:
+			if len(buf) < 4 {
+				return fmt.Errorf("cannot decode field %d", field)
+			}
+			buf = buf[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d for field %d", wireType, field)
+		}
+	}
+	return nil
+}
+
+// WriteProto walks the file system hierarchy rooted at the specified directory,
+// and writes one length-delimited DirentProto message to w for each node it
+// encounters.
+//
+// Only the fields of the provided Options, which may be nil, that
+// godirwalk.HelperOptions copies are used.
+func WriteProto(w io.Writer, root string, opts *godirwalk.Options) error {
+	options := godirwalk.HelperOptions(opts)
+
+	bw := bufio.NewWriter(w)
+	var prefix []byte
+
+	options.Callback = func(osPathname string, de *godirwalk.Dirent) error {
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		m := &DirentProto{
+			Path:     osPathname,
+			Name:     de.Name(),
+			ModeType: uint32(de.ModeType()),
+			Size:     fi.Size(),
+			Mtime:    fi.ModTime().UnixNano(),
+		}
+		buf := m.Marshal()
+		prefix = appendUvarint(prefix[:0], uint64(len(buf)))
+		if _, err = bw.Write(prefix); err != nil {
+			return err
+		}
+		_, err = bw.Write(buf)
+		return err
+	}
+
+	if err := godirwalk.Walk(root, &options); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Decoder reads length-delimited DirentProto messages from a stream.
+type Decoder struct {
+	br  *bufio.Reader
+	buf []byte
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder { return &Decoder{br: bufio.NewReader(r)} }
+
+// Decode returns the next message from the stream, or io.EOF when the stream
+// ends cleanly between messages.
+func (d *Decoder) Decode() (*DirentProto, error) {
+	l, err := binary.ReadUvarint(d.br)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot read message length: %s", err)
+	}
+	if l > maxMessageSize {
+		return nil, fmt.Errorf("cannot read message: length %d exceeds maximum of %d", l, maxMessageSize)
+	}
+	if uint64(cap(d.buf)) < l {
+		d.buf = make([]byte, l)
+	}
+	d.buf = d.buf[:l]
+	if _, err = io.ReadFull(d.br, d.buf); err != nil {
+		return nil, fmt.Errorf("cannot read message: %s", err)
+	}
+	m := new(DirentProto)
+	if err = m.Unmarshal(d.buf); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadProto decodes the first length-delimited DirentProto message from r,
+// returning an error if it cannot be decoded, then returns a channel on which
+// that and each subsequent message is sent, which is closed when the stream
+// ends. Because the channel cannot convey errors, decoding stops silently at
+// the first malformed message after the first; programs that must detect such
+// errors should use a Decoder directly. The caller must receive from the
+// channel until it is closed, otherwise the goroutine sending messages on it
+// will not terminate.
+func ReadProto(r io.Reader) (<-chan *DirentProto, error) {
+	d := NewDecoder(r)
+	first, err := d.Decode()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	messages := make(chan *DirentProto)
+	go func() {
+		defer close(messages)
+		for m := first; m != nil; {
+			messages <- m
+			var err error
+			if m, err = d.Decode(); err != nil {
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
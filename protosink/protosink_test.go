@@ -0,0 +1,117 @@
+package protosink
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirentProtoMarshalRoundTrip(t *testing.T) {
+	for _, expected := range []DirentProto{
+		{},
+		{Path: "a/b", Name: "b"},
+		{Path: "a/b", Name: "b", ModeType: uint32(os.ModeDir), Size: 4096, Mtime: 1234567890123456789},
+		{Path: "negative", Size: -1, Mtime: -1},
+	} {
+		var actual DirentProto
+		if err := actual.Unmarshal(expected.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := actual, expected; got != want {
+			t.Errorf("GOT: %#v; WANT: %#v", got, want)
+		}
+	}
+}
+
+func TestDirentProtoUnmarshalSkipsUnknownFields(t *testing.T) {
+	buf := (&DirentProto{Path: "p"}).Marshal()
+	buf = append(buf, 6<<3|wireVarint, 42)    // unknown varint field
+	buf = append(buf, 7<<3|wireBytes, 1, 'x') // unknown length-delimited field
+	var m DirentProto
+	if err := m.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Path, "p"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestWriteProtoReadProto(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err = os.Mkdir(filepath.Join(root, "d1"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(root, "d1/f1"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stream bytes.Buffer
+	if err = WriteProto(&stream, root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := ReadProto(&stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []*DirentProto
+	for m := range messages {
+		actual = append(actual, m)
+	}
+
+	if got, want := len(actual), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Walk is sorted, so the order of the messages is deterministic.
+	for i, want := range []string{root, filepath.Join(root, "d1"), filepath.Join(root, "d1/f1")} {
+		if got := actual[i].Path; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	f1 := actual[2]
+	fi, err := os.Lstat(filepath.Join(root, "d1/f1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f1.Name, "f1"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := f1.Size, int64(6); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := f1.Mtime, fi.ModTime().UnixNano(); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := os.FileMode(actual[1].ModeType), os.ModeDir; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		if _, err := NewDecoder(bytes.NewReader(nil)).Decode(); err != io.EOF {
+			t.Errorf("GOT: %v; WANT: %v", err, io.EOF)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		buf := (&DirentProto{Path: "some/path"}).Marshal()
+		stream := append([]byte{byte(len(buf))}, buf[:len(buf)-2]...)
+		if _, err := NewDecoder(bytes.NewReader(stream)).Decode(); err == nil {
+			t.Errorf("GOT: %v; WANT: error", err)
+		}
+		if _, err := ReadProto(bytes.NewReader(stream)); err == nil {
+			t.Errorf("GOT: %v; WANT: error", err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package godirwalk
+
+// QuotaInfo describes the quota of the project a directory is assigned to, and
+// the usage counted against it. Limits of zero mean no limit is enforced.
+type QuotaInfo struct {
+	ProjectID  uint32 // project the directory is assigned to
+	UsedBytes  uint64 // bytes used by all nodes assigned to the project
+	SoftLimit  uint64 // bytes that may be used before a grace period begins
+	HardLimit  uint64 // bytes that may never be exceeded
+	UsedInodes uint64 // nodes assigned to the project
+}
+
+// QuotaWalk walks the file system hierarchy rooted at the specified directory,
+// and returns the quota information for each directory encountered that is
+// subject to a quota, keyed by the pathname Walk provides for it. On Linux,
+// directory quotas are project quotas, as supported by XFS and ext4: the
+// project a directory is assigned to is obtained using the FS_IOC_FSGETXATTR
+// ioctl(2), and the quota of that project using quotactl(2). Directories not
+// assigned to a project, or on file systems without project quotas enabled,
+// are omitted. Obtaining the quotas of projects generally requires the
+// CAP_SYS_ADMIN capability, and failing to do so is handled by ErrorCallback
+// as though returned by Callback. On other operating systems QuotaWalk returns
+// an error.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    quotas, err := godirwalk.QuotaWalk(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    for osPathname, quota := range quotas {
+//        fmt.Printf("%s: %d of %d bytes\n",
+//            osPathname, quota.UsedBytes, quota.HardLimit)
+//    }
+func QuotaWalk(root string, opts *Options) (map[string]QuotaInfo, error) {
+	options := HelperOptions(opts)
+
+	qr, err := newQuotaReader()
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := make(map[string]QuotaInfo)
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if !de.IsDir() {
+			return nil
+		}
+		quota, ok, err := qr.quota(de.osPathname())
+		if err != nil {
+			return err
+		}
+		if ok {
+			quotas[osPathname] = quota
+		}
+		return nil
+	}
+
+	if err = Walk(root, &options); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
@@ -0,0 +1,126 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// fsIocFsgetxattr is the FS_IOC_FSGETXATTR ioctl(2) request, which obtains the
+// extended attributes of a file, including its project. It is _IOR('X', 31,
+// struct fsxattr), whose encoding differs on architectures that encode the
+// direction of ioctl(2) requests differently.
+var fsIocFsgetxattr = func() uintptr {
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le", "ppc64", "ppc64le":
+		return 0x401c581f
+	}
+	return 0x801c581f
+}()
+
+const (
+	// qGetquotaPrj is the quotactl(2) command obtaining the quota of a
+	// project, which is QCMD(Q_GETQUOTA, PRJQUOTA).
+	qGetquotaPrj = 0x800007<<8 | 2
+
+	// qifDqblksize is the size of the blocks in which quota limits are
+	// expressed.
+	qifDqblksize = 1024
+)
+
+// fsxattr mirrors the fsxattr structure of FS_IOC_FSGETXATTR.
+type fsxattr struct {
+	Xflags     uint32
+	Extsize    uint32
+	Nextents   uint32
+	Projid     uint32
+	Cowextsize uint32
+	Pad        [8]byte
+}
+
+// ifDqblk mirrors the if_dqblk structure of Q_GETQUOTA, including the padding
+// that aligns its size on 64-bit architectures.
+type ifDqblk struct {
+	Bhardlimit uint64
+	Bsoftlimit uint64
+	Curspace   uint64
+	Ihardlimit uint64
+	Isoftlimit uint64
+	Curinodes  uint64
+	Btime      uint64
+	Itime      uint64
+	Valid      uint32
+	Pad        uint32
+}
+
+// quotaReader obtains the quotas of directories.
+type quotaReader struct {
+	devices mountDevices
+}
+
+// newQuotaReader returns a quotaReader using the mount table of this process
+// to find the device holding each directory.
+func newQuotaReader() (quotaReader, error) {
+	entries, err := loadMountEntries()
+	if err != nil {
+		return quotaReader{}, err
+	}
+	return quotaReader{devices: newMountDevices(entries)}, nil
+}
+
+// quota returns the quota of the project the specified directory is assigned
+// to, or false when it is not subject to a project quota.
+func (qr quotaReader) quota(osDirname string) (QuotaInfo, bool, error) {
+	projid, err := projectID(osDirname)
+	if err != nil || projid == 0 {
+		return QuotaInfo{}, false, nil // file system does not support projects
+	}
+
+	osAbsname, err := filepath.Abs(osDirname)
+	if err != nil {
+		return QuotaInfo{}, false, err
+	}
+	device, ok := qr.devices.device(osAbsname)
+	if !ok {
+		return QuotaInfo{}, false, nil
+	}
+	dev, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return QuotaInfo{}, false, err
+	}
+
+	var dq ifDqblk
+	_, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL, qGetquotaPrj, uintptr(unsafe.Pointer(dev)), uintptr(projid), uintptr(unsafe.Pointer(&dq)), 0, 0)
+	switch errno {
+	case 0:
+	case syscall.ENOENT, syscall.ENODEV, syscall.ENOTBLK, syscall.ENOSYS, syscall.ESRCH, syscall.EINVAL:
+		return QuotaInfo{}, false, nil // project quotas are not enabled on the file system
+	default:
+		return QuotaInfo{}, false, &os.PathError{Op: "quotactl", Path: osDirname, Err: errno}
+	}
+
+	return QuotaInfo{
+		ProjectID:  projid,
+		UsedBytes:  dq.Curspace,
+		SoftLimit:  dq.Bsoftlimit * qifDqblksize,
+		HardLimit:  dq.Bhardlimit * qifDqblksize,
+		UsedInodes: dq.Curinodes,
+	}, true, nil
+}
+
+// projectID returns the project the specified file is assigned to.
+func projectID(osPathname string) (uint32, error) {
+	fh, err := os.Open(osPathname)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	var fsx fsxattr
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fh.Fd(), fsIocFsgetxattr, uintptr(unsafe.Pointer(&fsx))); errno != 0 {
+		return 0, errno
+	}
+	return fsx.Projid, nil
+}
@@ -0,0 +1,33 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuotaWalk(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "d/")
+	defer cleanup()
+
+	// Project quotas cannot be configured without privileges, so merely
+	// ensure any quotas reported are for directories within the tree.
+	quotas, err := QuotaWalk(root, nil)
+	ensureError(t, err)
+	for osPathname, quota := range quotas {
+		if osPathname != root && !strings.HasPrefix(osPathname, root+string(filepath.Separator)) {
+			t.Errorf("GOT: %q; WANT: within %q", osPathname, root)
+		}
+		if quota.ProjectID == 0 {
+			t.Errorf("%s: GOT: %v; WANT: non-zero project", osPathname, quota.ProjectID)
+		}
+	}
+	if _, ok := quotas[filepath.Join(root, "a", "b", "c")]; ok {
+		t.Errorf("GOT: quota for regular file; WANT: only directories")
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := QuotaWalk(filepath.Join(root, "missing"), nil)
+		ensureError(t, err, "missing")
+	})
+}
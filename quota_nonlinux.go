@@ -0,0 +1,16 @@
+// +build !linux
+
+package godirwalk
+
+import "errors"
+
+// quotaReader obtains the quotas of directories.
+type quotaReader struct{}
+
+// newQuotaReader returns an error, because this library only knows how to
+// obtain the quotas of directories on Linux.
+func newQuotaReader() (quotaReader, error) {
+	return quotaReader{}, errors.New("cannot obtain directory quotas on this operating system")
+}
+
+func (quotaReader) quota(_ string) (QuotaInfo, bool, error) { return QuotaInfo{}, false, nil }
@@ -1,5 +1,7 @@
 package godirwalk
 
+import "sort"
+
 // ReadDirents returns a sortable slice of pointers to Dirent structures, each
 // representing the file system name and mode type for one of the immediate
 // descendant of the specified directory. If the specified directory is a
@@ -18,7 +20,26 @@ package godirwalk
 //    }
 func ReadDirents(osDirname string, scratchBuffer []byte) (Dirents, error) {
 	// Invokes build flag enabled version of this function.
-	return readdirents(osDirname, scratchBuffer)
+	return readdirents(osDirname, scratchBuffer, readdirOptions{})
+}
+
+// readdirOptions specifies how readdirents reads a directory, when Walk is
+// provided options that change how directories are read. Its zero value reads
+// a directory as ReadDirents does.
+type readdirOptions struct {
+	// bypassCache requests that the file system bypass its attribute cache.
+	bypassCache bool
+
+	// maxEntries, when positive, is the number of entries read before
+	// ErrDirTooLarge is returned along with them.
+	maxEntries int
+
+	// sequentialHint advises the operating system that the directory will be
+	// read sequentially.
+	sequentialHint bool
+
+	// decode, when not nil, decodes the raw directory entries.
+	decode func([]byte) ([]*Dirent, error)
 }
 
 // ReadDirnames returns a slice of strings, representing the immediate
@@ -47,3 +68,168 @@ func ReadDirnames(osDirname string, scratchBuffer []byte) ([]string, error) {
 	// Invokes build flag enabled version of this function.
 	return readdirnames(osDirname, scratchBuffer)
 }
+
+// Flatten returns a slice holding each Dirent in l followed by all of its
+// descendants when it is a directory, which are read by recursively invoking
+// ReadDirents, transforming a listing of immediate descendants into a
+// recursive listing without the need for Walk. The immediate descendants of
+// each directory are sorted by name unless the Unsorted option is set, but the
+// returned slice is not sorted across directory boundaries; invoke sort.Sort
+// on it for that.
+//
+// The provided Options may be nil. When not nil, only its ScratchBuffer,
+// Unsorted, FollowSymbolicLinks, and ErrorCallback fields are used, the last
+// of which is invoked when a directory cannot be read, as it would be by Walk.
+//
+//    children, err := godirwalk.ReadDirents(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    all, err := children.Flatten(nil)
+func (l Dirents) Flatten(opts *Options) (Dirents, error) {
+	var options Options
+	if opts != nil {
+		options = *opts
+	}
+	if options.ErrorCallback == nil {
+		options.ErrorCallback = defaultErrorCallback
+	}
+	if len(options.ScratchBuffer) < MinimumScratchBufferSize {
+		options.ScratchBuffer = make([]byte, DefaultScratchBufferSize)
+	}
+
+	var flat Dirents
+
+	var flatten func(Dirents) error
+	flatten = func(l Dirents) error {
+		for _, de := range l {
+			flat = append(flat, de)
+
+			osPathname := de.osPathname()
+			isDir := de.IsDir()
+			if de.IsSymlink() && options.FollowSymbolicLinks {
+				var err error
+				if isDir, err = isSymlinkToDirectory(de, osPathname); err != nil {
+					if action := options.ErrorCallback(osPathname, err); action == SkipNode {
+						continue
+					}
+					return err
+				}
+			}
+			if !isDir {
+				continue
+			}
+
+			children, err := ReadDirents(osPathname, options.ScratchBuffer)
+			if err != nil {
+				if action := options.ErrorCallback(osPathname, err); action == SkipNode {
+					continue
+				}
+				return err
+			}
+			if !options.Unsorted {
+				sort.Sort(children)
+			}
+			if err = flatten(children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := flatten(l); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+// WalkChildren walks the file system hierarchy rooted at each directory in l,
+// in order, invoking Walk with fn as the callback function, so callers that
+// already hold a listing of immediate descendants may choose which of them to
+// descend into. Entries that are not directories, or when FollowSymbolicLinks
+// is true, symbolic links to directories, are skipped without invoking fn.
+//
+// The provided Options may be nil. When not nil, its Callback and
+// CheckpointFile fields are ignored. Its ErrorCallback is also invoked when
+// the referent of a symbolic link cannot be resolved.
+//
+//    children, err := godirwalk.ReadDirents(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    printNode := func(osPathname string, de *godirwalk.Dirent) error {
+//        fmt.Printf("%s %s\n", de.ModeType(), osPathname)
+//        return nil
+//    }
+//    err = children.WalkChildren(nil, printNode)
+func (l Dirents) WalkChildren(opts *Options, fn WalkFunc) error {
+	var options Options
+	if opts != nil {
+		options = copyOptions(opts)
+	}
+	options.Callback = fn
+	options.CheckpointFile = ""
+	if options.ErrorCallback == nil {
+		options.ErrorCallback = defaultErrorCallback
+	}
+
+	for _, de := range l {
+		osPathname := de.osPathname()
+		isDir := de.IsDir()
+		if de.IsSymlink() && options.FollowSymbolicLinks {
+			var err error
+			if isDir, err = isSymlinkToDirectory(de, osPathname); err != nil {
+				if action := options.ErrorCallback(osPathname, err); action == SkipNode {
+					continue
+				}
+				return err
+			}
+		}
+		if !isDir {
+			continue
+		}
+		if err := Walk(osPathname, &options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDirentsRecursive returns a slice of pointers to Dirent structures, one
+// for each descendant of the specified directory, in the order Walk visits
+// them. It is simpler to use than Walk for ad hoc queries, but because every
+// Dirent is held in memory until it returns, it is only appropriate for
+// hierarchies known to be small, and is unsuitable for walking hierarchies such
+// as / or /home.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    descendants, err := godirwalk.ReadDirentsRecursive(osDirname, nil)
+//    if err != nil {
+//        return err
+//    }
+//    for _, de := range descendants {
+//        fmt.Printf("%s %s\n", de.ModeType(), de.Path())
+//    }
+func ReadDirentsRecursive(pathname string, opts *Options) (Dirents, error) {
+	options := HelperOptions(opts)
+
+	var descendants Dirents
+	var visitedRoot bool
+
+	options.Callback = func(_ string, de *Dirent) error {
+		if !visitedRoot {
+			visitedRoot = true
+			return nil
+		}
+		node := *de
+		descendants = append(descendants, &node)
+		return nil
+	}
+
+	if err := Walk(pathname, &options); err != nil {
+		return nil, err
+	}
+	return descendants, nil
+}
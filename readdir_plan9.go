@@ -0,0 +1,151 @@
+package godirwalk
+
+// On Plan 9, reading a directory returns the machine-independent
+// representation of the Dir structure of each of its entries, as described by
+// stat(5), which this library decodes using the syscall package. A file system
+// node is named by its pathname regardless of whether it is reached through a
+// device, such as "#c/cons", or a bind or mount in the namespace of the
+// process, so pathnames need no special handling.
+//
+// The parameters requesting the file system bypass its attribute cache, and
+// advising it that the directory will be read sequentially, are ignored, as
+// Plan 9 provides no equivalent.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// nextDir decodes the first Dir structure in buf, returning it along with the
+// remaining bytes of buf.
+func nextDir(buf []byte) (*syscall.Dir, []byte, error) {
+	if len(buf) < 2 {
+		return nil, nil, syscall.ErrShortStat
+	}
+	n := 2 + (int(buf[0]) | int(buf[1])<<8) // size field excludes itself
+	if n < syscall.STATFIXLEN || n > len(buf) {
+		return nil, nil, syscall.ErrBadStat
+	}
+	dir, err := syscall.UnmarshalDir(buf[:n])
+	if err != nil {
+		return nil, nil, err
+	}
+	return dir, buf[n:], nil
+}
+
+// modeTypeFromDir returns the mode type of the node described by dir. Plan 9
+// has no symbolic links or device files, so every node is either a directory
+// or a regular file.
+func modeTypeFromDir(dir *syscall.Dir) os.FileMode {
+	if dir.Mode&syscall.DMDIR != 0 {
+		return os.ModeDir
+	}
+	return 0
+}
+
+// readDirs invokes fn with each Dir structure read from the specified open
+// directory, stopping early when fn returns false.
+func readDirs(dh *os.File, scratchBuffer []byte, fn func(*syscall.Dir, []byte) bool) error {
+	if len(scratchBuffer) < MinimumScratchBufferSize {
+		scratchBuffer = make([]byte, DefaultScratchBufferSize)
+	}
+	for {
+		n, err := dh.Read(scratchBuffer)
+		if err == io.EOF || (err == nil && n == 0) {
+			return nil // end of directory reached
+		}
+		if err != nil {
+			return err
+		}
+		buf := scratchBuffer[:n]
+		for len(buf) > 0 {
+			raw := buf
+			var dir *syscall.Dir
+			if dir, buf, err = nextDir(buf); err != nil {
+				return err
+			}
+			if !fn(dir, raw[:len(raw)-len(buf)]) {
+				return nil
+			}
+		}
+	}
+}
+
+func readdirents(osDirname string, scratchBuffer []byte, ro readdirOptions) (Dirents, error) {
+	dh, err := os.Open(osDirname)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pathnames of entries are assembled from the cleaned directory name, so
+	// they match what filepath.Join would return.
+	osCleanDirname := filepath.Clean(osDirname)
+
+	var entries Dirents
+	var tooLarge bool
+	var decodeErr error
+	add := func(name string, mode os.FileMode) bool {
+		if name == "" || name == "." || name == ".." {
+			return true // skip unimportant entries
+		}
+		if ro.maxEntries > 0 && len(entries) == ro.maxEntries {
+			tooLarge = true
+			return false
+		}
+		entries = append(entries, &Dirent{path: joinPathname(osCleanDirname, name), name: name, modeType: mode})
+		return true
+	}
+
+	err = readDirs(dh, scratchBuffer, func(dir *syscall.Dir, raw []byte) bool {
+		if ro.decode == nil {
+			return add(dir.Name, modeTypeFromDir(dir))
+		}
+		var decoded []*Dirent
+		if decoded, decodeErr = ro.decode(raw); decodeErr != nil {
+			return false
+		}
+		for _, child := range decoded {
+			if child != nil && !add(child.name, child.modeType) {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if er := dh.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tooLarge {
+		return entries, ErrDirTooLarge
+	}
+	return entries, nil
+}
+
+func readdirnames(osDirname string, scratchBuffer []byte) ([]string, error) {
+	dh, err := os.Open(osDirname)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	err = readDirs(dh, scratchBuffer, func(dir *syscall.Dir, _ []byte) bool {
+		if dir.Name != "" && dir.Name != "." && dir.Name != ".." {
+			entries = append(entries, dir.Name)
+		}
+		return true
+	})
+	if er := dh.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
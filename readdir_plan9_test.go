@@ -0,0 +1,48 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestNextDir(t *testing.T) {
+	marshal := func(t *testing.T, name string, mode uint32) []byte {
+		t.Helper()
+		dir := syscall.Dir{Name: name, Mode: mode, Uid: "glenda", Gid: "glenda", Muid: "glenda"}
+		buf := make([]byte, syscall.STATFIXLEN+len(name)+3*len("glenda"))
+		n, err := dir.Marshal(buf)
+		ensureError(t, err)
+		return buf[:n]
+	}
+
+	buf := append(marshal(t, "lib", syscall.DMDIR|0775), marshal(t, "cons", 0600)...)
+
+	dir, rest, err := nextDir(buf)
+	ensureError(t, err)
+	if got, want := dir.Name, "lib"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := modeTypeFromDir(dir), os.ModeDir; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	dir, rest, err = nextDir(rest)
+	ensureError(t, err)
+	if got, want := dir.Name, "cons"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := modeTypeFromDir(dir), os.FileMode(0); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := len(rest), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		_, _, err := nextDir(buf[:10])
+		if got, want := err, syscall.ErrBadStat; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -3,6 +3,8 @@ package godirwalk
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -112,3 +114,120 @@ func BenchmarkReadDirnamesThisLibrary(b *testing.B) {
 	}
 	_ = count
 }
+
+func TestDirentsFlatten(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e", "f/")
+	defer cleanup()
+	ensureError(t, os.Symlink("a", filepath.Join(root, "g")))
+
+	children, err := ReadDirents(root, nil)
+	ensureError(t, err)
+	sort.Sort(children)
+
+	flatten := func(options *Options) []string {
+		t.Helper()
+		flat, err := children.Flatten(options)
+		ensureError(t, err)
+		var actual []string
+		for _, de := range flat {
+			rel, err := filepath.Rel(root, de.Path())
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		return actual
+	}
+
+	t.Run("default", func(t *testing.T) {
+		if got, want := strings.Join(flatten(nil), " "), "a a/b a/b/c a/d e f g"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("follow symbolic links", func(t *testing.T) {
+		if got, want := strings.Join(flatten(&Options{FollowSymbolicLinks: true}), " "), "a a/b a/b/c a/d e f g g/b g/b/c g/d"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		missing := Dirents{&Dirent{path: filepath.Join(root, "missing"), name: "missing", modeType: os.ModeDir}}
+		_, err := missing.Flatten(nil)
+		ensureError(t, err, "missing")
+
+		flat, err := missing.Flatten(&Options{
+			ErrorCallback: func(string, error) ErrorAction { return SkipNode },
+		})
+		ensureError(t, err)
+		if got, want := len(flat), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestDirentsWalkChildren(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e", "f/")
+	defer cleanup()
+	ensureError(t, os.Symlink("a", filepath.Join(root, "g")))
+
+	children, err := ReadDirents(root, nil)
+	ensureError(t, err)
+	sort.Sort(children)
+
+	walkChildren := func(options *Options) []string {
+		t.Helper()
+		var actual []string
+		err := children.WalkChildren(options, func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		})
+		ensureError(t, err)
+		return actual
+	}
+
+	t.Run("default", func(t *testing.T) {
+		if got, want := strings.Join(walkChildren(nil), " "), "a a/b a/b/c a/d f"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("follow symbolic links", func(t *testing.T) {
+		if got, want := strings.Join(walkChildren(&Options{FollowSymbolicLinks: true}), " "), "a a/b a/b/c a/d f g g/b g/b/c g/d"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		missing := Dirents{&Dirent{path: filepath.Join(root, "missing"), name: "missing", modeType: os.ModeDir}}
+		err := missing.WalkChildren(nil, func(string, *Dirent) error { return nil })
+		ensureError(t, err, "missing")
+	})
+}
+
+func TestReadDirentsRecursive(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e", "f/")
+	defer cleanup()
+
+	descendants, err := ReadDirentsRecursive(root, nil)
+	ensureError(t, err)
+
+	expected := Dirents{
+		&Dirent{path: filepath.Join(root, "a"), name: "a", modeType: os.ModeDir},
+		&Dirent{path: filepath.Join(root, "a/b"), name: "b", modeType: os.ModeDir},
+		&Dirent{path: filepath.Join(root, "a/b/c"), name: "c"},
+		&Dirent{path: filepath.Join(root, "a/d"), name: "d"},
+		&Dirent{path: filepath.Join(root, "e"), name: "e"},
+		&Dirent{path: filepath.Join(root, "f"), name: "f", modeType: os.ModeDir},
+	}
+	ensureDirentsMatch(t, descendants, expected)
+
+	for i, de := range descendants {
+		if got, want := de.Path(), expected[i].path; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	_, err = ReadDirentsRecursive(filepath.Join(root, "missing"), nil)
+	ensureError(t, err, "missing")
+}
@@ -1,4 +1,4 @@
-// +build !windows
+// +build !windows,!plan9
 
 package godirwalk
 
@@ -9,11 +9,20 @@ import (
 	"unsafe"
 )
 
-func readdirents(osDirname string, scratchBuffer []byte) (Dirents, error) {
+func readdirents(osDirname string, scratchBuffer []byte, ro readdirOptions) (Dirents, error) {
 	dh, err := os.Open(osDirname)
 	if err != nil {
 		return nil, err
 	}
+	if ro.bypassCache {
+		if err = bypassAttrCache(dh); err != nil {
+			_ = dh.Close() // ignore potential error returned by Close
+			return nil, err
+		}
+	}
+	if ro.sequentialHint {
+		adviseSequential(dh)
+	}
 	fd := int(dh.Fd())
 
 	if len(scratchBuffer) < MinimumScratchBufferSize {
@@ -23,6 +32,10 @@ func readdirents(osDirname string, scratchBuffer []byte) (Dirents, error) {
 	var entries Dirents
 	var de *syscall.Dirent
 
+	// Pathnames of entries are assembled from the cleaned directory name, so
+	// they match what filepath.Join would return.
+	osCleanDirname := filepath.Clean(osDirname)
+
 	for {
 		n, err := syscall.ReadDirent(fd, scratchBuffer)
 		if err != nil {
@@ -34,6 +47,24 @@ func readdirents(osDirname string, scratchBuffer []byte) (Dirents, error) {
 		}
 		// Loop over the bytes returned by reading the directory entries.
 		buf := scratchBuffer[:n]
+		if ro.decode != nil {
+			decoded, err := ro.decode(buf)
+			if err != nil {
+				_ = dh.Close() // ignore potential error returned by Close
+				return nil, err
+			}
+			for _, child := range decoded {
+				if child == nil || child.name == "." || child.name == ".." {
+					continue // skip unimportant entries
+				}
+				if ro.maxEntries > 0 && len(entries) == ro.maxEntries {
+					_ = dh.Close() // ignore potential error returned by Close
+					return entries, ErrDirTooLarge
+				}
+				entries = append(entries, &Dirent{path: joinPathname(osCleanDirname, child.name), name: child.name, modeType: child.modeType})
+			}
+			continue
+		}
 		for len(buf) > 0 {
 			de = (*syscall.Dirent)(unsafe.Pointer(&buf[0])) // point entry to first syscall.Dirent in buffer
 			buf = buf[de.Reclen:]                           // advance buffer for next iteration through loop
@@ -49,13 +80,18 @@ func readdirents(osDirname string, scratchBuffer []byte) (Dirents, error) {
 			}
 			osChildname := string(nameSlice)
 
+			if ro.maxEntries > 0 && len(entries) == ro.maxEntries {
+				_ = dh.Close() // ignore potential error returned by Close
+				return entries, ErrDirTooLarge
+			}
+
 			mode, err := modeType(de, osDirname, osChildname)
 			if err != nil {
 				_ = dh.Close() // ignore potential error returned by Close
 				return nil, err
 			}
 
-			entries = append(entries, &Dirent{path: filepath.Join(osDirname, osChildname), name: osChildname, modeType: mode})
+			entries = append(entries, &Dirent{path: joinPathname(osCleanDirname, osChildname), name: osChildname, modeType: mode})
 		}
 	}
 
@@ -0,0 +1,90 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkEntryDecoder(t *testing.T) {
+	root, cleanup := setupTree(t, "real")
+	defer cleanup()
+
+	t.Run("synthetic entries", func(t *testing.T) {
+		var calls int
+		var actual []string
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			EntryDecoder: func(buf []byte) ([]*Dirent, error) {
+				if len(buf) == 0 {
+					t.Errorf("GOT: empty buffer; WANT: raw directory entries")
+				}
+				calls++
+				if calls > 1 {
+					return nil, nil // the synthetic directory holds nothing
+				}
+				return []*Dirent{
+					NewDirentWithModeType("..", os.ModeDir),
+					NewDirentWithModeType("b", 0),
+					NewDirentWithModeType("a", os.ModeDir),
+				}, nil
+			},
+			Callback: func(osPathname string, de *Dirent) error {
+				if osPathname != root {
+					if got, want := de.Path(), osPathname; got != want {
+						t.Errorf("GOT: %q; WANT: %q", got, want)
+					}
+					actual = append(actual, de.String())
+				}
+				return nil
+			},
+			ErrorCallback: func(string, error) ErrorAction {
+				return SkipNode // the synthetic directory does not exist
+			},
+		})
+		ensureError(t, err)
+
+		expected := []string{
+			"Dirent{path: " + filepath.Join(root, "a") + ", type: d}",
+			"Dirent{path: " + filepath.Join(root, "b") + ", type: -}",
+		}
+		if got, want := strings.Join(actual, "; "), strings.Join(expected, "; "); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errDecode := errors.New("malformed entry")
+		err := Walk(root, &Options{
+			EntryDecoder: func([]byte) ([]*Dirent, error) { return nil, errDecode },
+			Callback:     func(string, *Dirent) error { return nil },
+		})
+		if !errors.Is(err, errDecode) {
+			t.Errorf("GOT: %v; WANT: %v", err, errDecode)
+		}
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		var count int
+		err := Walk(root, &Options{
+			MaxEntriesPerDir: 1,
+			EntryDecoder: func([]byte) ([]*Dirent, error) {
+				return []*Dirent{NewDirentWithModeType("x", 0), NewDirentWithModeType("y", 0)}, nil
+			},
+			Callback: func(string, *Dirent) error {
+				count++
+				return nil
+			},
+		})
+		if !errors.Is(err, ErrDirTooLarge) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrDirTooLarge)
+		}
+		if got, want := count, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -4,23 +4,37 @@ package godirwalk
 // standard library, in order to provide the same API as this library provides.
 //
 // The scratch buffer parameter in these functions is the underscore because
-// presently that parameter is ignored by the functions for this architecture.
+// presently that parameter is ignored by the functions for this architecture,
+// as are the readdirOptions fields requesting the file system bypass its
+// attribute cache, advising it that the directory will be read sequentially,
+// and providing a decoder of the raw directory entries, which Windows does not
+// expose.
 //
 // Please send PR or link to article if you know of a more performant way of
 // enumerating directory contents and mode types on Windows.
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 )
 
-func readdirents(osDirname string, _ []byte) (Dirents, error) {
+func readdirents(osDirname string, _ []byte, ro readdirOptions) (Dirents, error) {
 	dh, err := os.Open(osDirname)
 	if err != nil {
 		return nil, err
 	}
 
-	fileinfos, err := dh.Readdir(0)
+	var fileinfos []os.FileInfo
+	if ro.maxEntries > 0 {
+		// Request one more entry than permitted to detect directories that
+		// have too many entries.
+		if fileinfos, err = dh.Readdir(ro.maxEntries + 1); err == io.EOF {
+			err = nil // empty directory
+		}
+	} else {
+		fileinfos, err = dh.Readdir(0)
+	}
 	if er := dh.Close(); err == nil {
 		err = er
 	}
@@ -28,11 +42,19 @@ func readdirents(osDirname string, _ []byte) (Dirents, error) {
 		return nil, err
 	}
 
+	var tooLarge bool
+	if ro.maxEntries > 0 && len(fileinfos) > ro.maxEntries {
+		fileinfos, tooLarge = fileinfos[:ro.maxEntries], true
+	}
+
 	entries := make(Dirents, len(fileinfos))
 	for i, info := range fileinfos {
 		entries[i] = &Dirent{path: filepath.Join(osDirname, info.Name()), name: info.Name(), modeType: info.Mode() & os.ModeType}
 	}
 
+	if tooLarge {
+		return entries, ErrDirTooLarge
+	}
 	return entries, nil
 }
 
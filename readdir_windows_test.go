@@ -0,0 +1,63 @@
+package godirwalk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Windows file system APIs return names encoded as UTF-16, in which characters
+// outside the basic multilingual plane are represented by surrogate pairs.
+// Ensure such names are converted to Go strings without loss.
+func TestReadDirentsAstralPlaneNames(t *testing.T) {
+	names := []string{
+		"\U0001F600 grinning face", // emoji
+		"\U0001D11E clef",          // musical symbol
+		"\U00020000",               // CJK extension B
+		"mixed-é-\U0001F680",       // BMP and astral characters together
+	}
+
+	root, cleanup := setupTree(t, names...)
+	defer cleanup()
+
+	actual, err := ReadDirents(root, nil)
+	ensureError(t, err)
+
+	var expected Dirents
+	for _, name := range names {
+		expected = append(expected, &Dirent{path: filepath.Join(root, name), name: name})
+	}
+	ensureDirentsMatch(t, actual, expected)
+
+	var visited []string
+	err = Walk(root, &Options{
+		Callback: func(osPathname string, de *Dirent) error {
+			if osPathname == root {
+				return nil
+			}
+			if got, want := de.Path(), osPathname; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if got, want := filepath.Base(osPathname), de.Name(); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			// Ensure the name survived the round trip by opening the file.
+			buf, err := ioutil.ReadFile(osPathname)
+			if err != nil {
+				return err
+			}
+			if got, want := string(buf), de.Name()+"\n"; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			visited = append(visited, de.Name())
+			return nil
+		},
+	})
+	ensureError(t, err)
+	ensureStringSlicesMatch(t, visited, names)
+
+	if _, err := os.Stat(filepath.Join(root, names[0])); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,38 @@
+package godirwalk
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RunMeta describes a single invocation of Walk, and is provided to the
+// RunStartCallback option before Walk visits any node.
+type RunMeta struct {
+	// ID uniquely identifies the walk, so log records emitted while walking
+	// may be correlated with one another.
+	ID string
+
+	// Start is the time at which the walk began.
+	Start time.Time
+
+	// Root is the cleaned pathname of the root of the walk.
+	Root string
+}
+
+// runCounter distinguishes the identifiers of walks started by this process
+// when random bytes are not available.
+var runCounter uint64
+
+// newRunID returns a new identifier for a walk, which is 32 hexadecimal digits
+// read from the cryptographic random number generator, or when that fails,
+// derived from the current time and a counter.
+func newRunID(start time.Time) string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err == nil {
+		return hex.EncodeToString(b[:])
+	}
+	return strconv.FormatInt(start.UnixNano(), 16) + "-" + strconv.FormatUint(atomic.AddUint64(&runCounter, 1), 16)
+}
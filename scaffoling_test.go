@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -156,6 +157,33 @@ func dumpDirectory() {
 	}
 }
 
+// setupTree creates a temporary directory hierarchy containing the specified
+// entries, each named relative to the new root using solidus separators, and
+// returns the root along with a function that removes the hierarchy. Entries
+// that end in a solidus are created as directories; all others are created as
+// files.
+func setupTree(tb testing.TB, entries ...string) (string, func()) {
+	tb.Helper()
+	root, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cleanup := func() { _ = os.RemoveAll(root) }
+	for _, entry := range entries {
+		pathname := filepath.Join(root, filepath.FromSlash(entry))
+		if strings.HasSuffix(entry, "/") {
+			err = os.MkdirAll(pathname, os.ModePerm)
+		} else if err = os.MkdirAll(filepath.Dir(pathname), os.ModePerm); err == nil {
+			err = ioutil.WriteFile(pathname, []byte(entry+"\n"), os.ModePerm)
+		}
+		if err != nil {
+			cleanup()
+			tb.Fatalf("cannot create test tree entry: %s", err)
+		}
+	}
+	return root, cleanup
+}
+
 ////////////////////////////////////////
 // helpers to create file system entries for test scaffolding
 
@@ -0,0 +1,157 @@
+package godirwalk
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// Scanner enumerates the immediate descendants of a directory one at a time,
+// without holding the entire listing in memory, in the order the operating
+// system returns them, unless Sorted is used to request they be sorted by
+// name.
+//
+//    scanner, err := godirwalk.NewScanner(osDirname)
+//    if err != nil {
+//        return err
+//    }
+//    for scanner.Scan() {
+//        de := scanner.Dirent()
+//        fmt.Printf("%s %s\n", de.ModeType(), de.Name())
+//    }
+//    if err := scanner.Err(); err != nil {
+//        return err
+//    }
+type Scanner struct {
+	raw     rawScanner // operating system specific state
+	dh      *os.File   // nil once closed
+	sorted  bool
+	started bool
+	entries Dirents // remaining entries when sorted
+	current *Dirent
+	err     error
+}
+
+// NewScanner returns a Scanner that enumerates the immediate descendants of the
+// specified directory. If the specified directory is a symbolic link, it will
+// be resolved.
+func NewScanner(osDirname string) (*Scanner, error) {
+	return NewScannerWithScratchBuffer(osDirname, nil)
+}
+
+// NewScannerWithScratchBuffer returns a Scanner that enumerates the immediate
+// descendants of the specified directory, using the provided scratch buffer,
+// if it is at least MinimumScratchBufferSize bytes, when reading directory
+// entries from the file system.
+func NewScannerWithScratchBuffer(osDirname string, scratchBuffer []byte) (*Scanner, error) {
+	dh, err := os.Open(osDirname)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scanner{dh: dh}
+	s.raw.init(dh, osDirname, scratchBuffer)
+	return s, nil
+}
+
+// Sorted specifies whether the Scanner yields entries sorted by name, as Walk
+// visits them, rather than in the order the operating system returns
+// them. Because the order of the entries is only known once all of them have
+// been read, the Scanner reads the entire directory into memory upon the first
+// call to Scan when sorted is true. Sorted has no effect once Scan has been
+// called.
+func (s *Scanner) Sorted(sorted bool) {
+	if !s.started {
+		s.sorted = sorted
+	}
+}
+
+// Scan advances the Scanner to the next entry, which is then available using
+// the Dirent and Name methods, returning false when there are no more entries
+// or an error occurred, in which case Err returns the error. The directory is
+// closed once Scan returns false.
+func (s *Scanner) Scan() bool {
+	s.current = nil
+	if s.dh == nil && s.entries == nil {
+		return false
+	}
+
+	if !s.started {
+		s.started = true
+		if s.sorted {
+			for {
+				de, err := s.raw.next()
+				if err != nil {
+					if err != io.EOF {
+						s.fail(err)
+						return false
+					}
+					break
+				}
+				s.entries = append(s.entries, de)
+			}
+			s.fail(s.Close())
+			sort.Sort(s.entries)
+		}
+	}
+
+	if s.sorted {
+		if len(s.entries) == 0 {
+			s.entries = nil
+			return false
+		}
+		s.current, s.entries = s.entries[0], s.entries[1:]
+		return true
+	}
+
+	de, err := s.raw.next()
+	if err != nil {
+		if err != io.EOF {
+			s.fail(err)
+		}
+		s.fail(s.Close())
+		return false
+	}
+	s.current = de
+	return true
+}
+
+// fail records the first error encountered, and closes the directory.
+func (s *Scanner) fail(err error) {
+	if err == nil {
+		return
+	}
+	if s.err == nil {
+		s.err = err
+	}
+	_ = s.Close() // ignore potential error returned by Close
+	s.entries = nil
+}
+
+// Dirent returns the entry the most recent call to Scan advanced to, or nil
+// when Scan returned false.
+func (s *Scanner) Dirent() *Dirent { return s.current }
+
+// Name returns the name of the entry the most recent call to Scan advanced to,
+// or the empty string when Scan returned false.
+func (s *Scanner) Name() string {
+	if s.current == nil {
+		return ""
+	}
+	return s.current.name
+}
+
+// Err returns the first error encountered by the Scanner, other than reaching
+// the end of the directory.
+func (s *Scanner) Err() error { return s.err }
+
+// Close closes the directory, causing subsequent calls to Scan to return false
+// once any buffered entries have been yielded. It need only be called when a
+// program stops calling Scan before it returns false.
+func (s *Scanner) Close() error {
+	if s.dh == nil {
+		return nil
+	}
+	err := s.dh.Close()
+	s.dh = nil
+	return err
+}
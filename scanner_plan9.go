@@ -0,0 +1,53 @@
+package godirwalk
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// rawScanner reads the entries of a directory in the order the operating
+// system returns them.
+type rawScanner struct {
+	dh             *os.File
+	osCleanDirname string
+	scratchBuffer  []byte
+	workBuffer     []byte // bytes of scratchBuffer not yet processed
+}
+
+func (r *rawScanner) init(dh *os.File, osDirname string, scratchBuffer []byte) {
+	if len(scratchBuffer) < MinimumScratchBufferSize {
+		scratchBuffer = make([]byte, DefaultScratchBufferSize)
+	}
+	r.dh = dh
+	r.osCleanDirname = filepath.Clean(osDirname)
+	r.scratchBuffer = scratchBuffer
+}
+
+// next returns the next entry in the directory, or io.EOF when there are no
+// more entries.
+func (r *rawScanner) next() (*Dirent, error) {
+	for {
+		if len(r.workBuffer) == 0 {
+			n, err := r.dh.Read(r.scratchBuffer)
+			if err != nil {
+				return nil, err // io.EOF at end of directory
+			}
+			if n <= 0 {
+				return nil, io.EOF // end of directory reached
+			}
+			r.workBuffer = r.scratchBuffer[:n]
+		}
+
+		dir, rest, err := nextDir(r.workBuffer)
+		if err != nil {
+			return nil, err
+		}
+		r.workBuffer = rest
+
+		if dir.Name == "" || dir.Name == "." || dir.Name == ".." {
+			continue // skip unimportant entries
+		}
+		return &Dirent{path: joinPathname(r.osCleanDirname, dir.Name), name: dir.Name, modeType: modeTypeFromDir(dir)}, nil
+	}
+}
@@ -0,0 +1,107 @@
+package godirwalk
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	var entries []string
+	for i := 0; i < 100; i++ {
+		entries = append(entries, fmt.Sprintf("f%d", (i*37)%100)) // not created in sorted order
+	}
+	entries = append(entries, "d/")
+	root, cleanup := setupTree(t, entries...)
+	defer cleanup()
+
+	scan := func(t *testing.T, sorted bool) []string {
+		t.Helper()
+		scanner, err := NewScannerWithScratchBuffer(root, testScratchBuffer)
+		ensureError(t, err)
+		scanner.Sorted(sorted)
+		var names []string
+		for scanner.Scan() {
+			de := scanner.Dirent()
+			if got, want := de.Name(), scanner.Name(); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if got, want := de.Path(), filepath.Join(root, de.Name()); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if got, want := de.IsDir(), de.Name() == "d"; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", de.Name(), got, want)
+			}
+			names = append(names, scanner.Name())
+		}
+		ensureError(t, scanner.Err())
+		if scanner.Scan() {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+		return names
+	}
+
+	t.Run("unsorted preserves readdir order", func(t *testing.T) {
+		expected, err := ReadDirnames(root, nil)
+		ensureError(t, err)
+		actual := scan(t, false)
+		if got, want := fmt.Sprint(actual), fmt.Sprint(expected); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		actual := scan(t, true)
+		if got, want := len(actual), 101; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if !sort.StringsAreSorted(actual) {
+			t.Errorf("GOT: %v; WANT: sorted", actual)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := NewScanner(filepath.Join(root, "missing"))
+		ensureError(t, err, "missing")
+	})
+
+	t.Run("close early", func(t *testing.T) {
+		scanner, err := NewScanner(root)
+		ensureError(t, err)
+		if !scanner.Scan() {
+			t.Fatalf("GOT: %v; WANT: %v", false, true)
+		}
+		ensureError(t, scanner.Close())
+		if scanner.Scan() {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+		ensureError(t, scanner.Err())
+	})
+}
+
+func TestScannerMinimumScratchBuffer(t *testing.T) {
+	// With the smallest permitted scratch buffer, the directory is read in
+	// many batches, the final entry of some of which is closer to the end of
+	// the buffer than the size of the structure describing it.
+	var entries []string
+	for i := 0; i < 2000; i++ {
+		entries = append(entries, fmt.Sprintf("%0*d", 1+i%17, i))
+	}
+	root, cleanup := setupTree(t, entries...)
+	defer cleanup()
+
+	scanner, err := NewScannerWithScratchBuffer(root, make([]byte, MinimumScratchBufferSize))
+	ensureError(t, err)
+	defer scanner.Close()
+
+	var count int
+	for scanner.Scan() {
+		count++
+	}
+	ensureError(t, scanner.Err())
+
+	if got, want := count, len(entries); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
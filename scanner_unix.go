@@ -0,0 +1,79 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// rawScanner reads the entries of a directory in the order the operating
+// system returns them.
+type rawScanner struct {
+	fd             int
+	osDirname      string
+	osCleanDirname string
+	scratchBuffer  []byte
+	workBuffer     []byte         // bytes of scratchBuffer not yet processed
+	tail           syscall.Dirent // final entry of workBuffer, when it is shorter than a syscall.Dirent
+}
+
+func (r *rawScanner) init(dh *os.File, osDirname string, scratchBuffer []byte) {
+	if len(scratchBuffer) < MinimumScratchBufferSize {
+		scratchBuffer = make([]byte, DefaultScratchBufferSize)
+	}
+	r.fd = int(dh.Fd())
+	r.osDirname = osDirname
+	r.osCleanDirname = filepath.Clean(osDirname)
+	r.scratchBuffer = scratchBuffer
+}
+
+// next returns the next entry in the directory, or io.EOF when there are no
+// more entries.
+func (r *rawScanner) next() (*Dirent, error) {
+	for {
+		if len(r.workBuffer) == 0 {
+			n, err := syscall.ReadDirent(r.fd, r.scratchBuffer)
+			if err != nil {
+				return nil, err
+			}
+			if n <= 0 {
+				return nil, io.EOF // end of directory reached
+			}
+			r.workBuffer = r.scratchBuffer[:n]
+		}
+
+		de := &r.tail
+		if len(r.workBuffer) >= int(unsafe.Sizeof(*de)) {
+			de = (*syscall.Dirent)(unsafe.Pointer(&r.workBuffer[0])) // point entry to first syscall.Dirent in buffer
+		} else {
+			// Fewer bytes remain than the size of a syscall.Dirent, so
+			// pointing into the buffer would refer past its end. Copy the
+			// final entry instead.
+			*de = syscall.Dirent{}
+			copy((*[unsafe.Sizeof(*de)]byte)(unsafe.Pointer(de))[:], r.workBuffer)
+		}
+		r.workBuffer = r.workBuffer[de.Reclen:] // advance buffer for next iteration through loop
+
+		if inoFromDirent(de) == 0 {
+			continue // this item has been deleted, but its entry not yet removed from directory listing
+		}
+
+		nameSlice := nameFromDirent(de)
+		namlen := len(nameSlice)
+		if (namlen == 0) || (namlen == 1 && nameSlice[0] == '.') || (namlen == 2 && nameSlice[0] == '.' && nameSlice[1] == '.') {
+			continue // skip unimportant entries
+		}
+		osChildname := string(nameSlice)
+
+		mode, err := modeType(de, r.osDirname, osChildname)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Dirent{path: joinPathname(r.osCleanDirname, osChildname), name: osChildname, modeType: mode}, nil
+	}
+}
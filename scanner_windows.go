@@ -0,0 +1,38 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// scannerBatchSize is the number of entries rawScanner requests from the
+// operating system at once.
+const scannerBatchSize = 256
+
+// rawScanner reads the entries of a directory in the order the operating
+// system returns them. The scratch buffer is ignored on Windows.
+type rawScanner struct {
+	dh        *os.File
+	osDirname string
+	infos     []os.FileInfo // entries read but not yet returned
+}
+
+func (r *rawScanner) init(dh *os.File, osDirname string, _ []byte) {
+	r.dh = dh
+	r.osDirname = osDirname
+}
+
+// next returns the next entry in the directory, or io.EOF when there are no
+// more entries.
+func (r *rawScanner) next() (*Dirent, error) {
+	if len(r.infos) == 0 {
+		infos, err := r.dh.Readdir(scannerBatchSize)
+		if len(infos) == 0 {
+			return nil, err // io.EOF at end of directory
+		}
+		r.infos = infos
+	}
+	info := r.infos[0]
+	r.infos = r.infos[1:]
+	return &Dirent{path: filepath.Join(r.osDirname, info.Name()), name: info.Name(), modeType: info.Mode() & os.ModeType}, nil
+}
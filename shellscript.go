@@ -0,0 +1,85 @@
+package godirwalk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteShellScript writes to w a POSIX shell script that, when executed in an
+// empty directory, recreates the structure of the file system hierarchy rooted
+// at the specified directory. Directories are created using `mkdir -p`,
+// symbolic links using `ln -s` with their original referents, named pipes using
+// `mkfifo`, and regular files using `touch` followed by `chmod` to restore
+// their permission bits. Every pathname in the script is relative to the
+// directory in which it is executed, and begins with `./`. File contents are
+// not reproduced, and other node types, such as devices and sockets, are noted
+// in comments but not created. This is useful for generating reproducible test
+// fixtures, and for documenting the expected layout of a directory hierarchy.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used. Symbolic links are never followed, so they may be
+// recreated as links.
+func WriteShellScript(w io.Writer, root string, opts *Options) error {
+	options := HelperOptions(opts)
+	options.FollowSymbolicLinks = false
+
+	root = filepath.Clean(root)
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("#!/bin/sh\nset -e\n")
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if osPathname == root {
+			return nil // script is executed in directory that represents root
+		}
+		rel, err := filepath.Rel(root, osPathname)
+		if err != nil {
+			return err
+		}
+		// Names are prefixed so that none may be mistaken for an option.
+		name := shellQuote("./" + filepath.ToSlash(rel))
+
+		switch {
+		case de.IsSymlink():
+			referent, err := os.Readlink(osPathname)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(bw, "ln -s -- %s %s\n", shellQuote(filepath.ToSlash(referent)), name)
+			return err
+		case de.IsDir():
+			_, err = fmt.Fprintf(bw, "mkdir -p %s\n", name)
+			return err
+		case de.modeType&os.ModeNamedPipe != 0:
+			_, err = fmt.Fprintf(bw, "mkfifo %s\n", name)
+			return err
+		case de.IsRegular():
+			fi, err := os.Lstat(osPathname)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(bw, "touch %s\nchmod %04o %s\n", name, fi.Mode().Perm(), name)
+			return err
+		default:
+			// Comments cannot span lines, so replace any newlines in the name.
+			_, err = fmt.Fprintf(bw, "# skipped %s: %s\n", de.ModeType(), strings.Replace(name, "\n", " ", -1))
+			return err
+		}
+	}
+
+	if err := Walk(root, &options); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// shellQuote returns the string enclosed in single quotes, so the shell
+// interprets every character literally, replacing each embedded single quote
+// with a sequence that closes the quoted string, writes an escaped single
+// quote, and reopens the quoted string.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
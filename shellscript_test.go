@@ -0,0 +1,109 @@
+package godirwalk
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteShellScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links and permission bits not portable to windows")
+	}
+
+	root, cleanup := setupTree(t, "-rf", "a/b", "a/it's", "c/", "d")
+	defer cleanup()
+
+	ensureError(t, os.Chmod(filepath.Join(root, "a/b"), 0600))
+	ensureError(t, os.Chmod(filepath.Join(root, "a/it's"), 0755))
+	ensureError(t, os.Chmod(filepath.Join(root, "d"), 0644))
+	ensureError(t, os.Chmod(filepath.Join(root, "-rf"), 0644))
+	ensureError(t, os.Symlink("../d", filepath.Join(root, "c/toD")))
+	ensureError(t, os.Symlink("-n", filepath.Join(root, "c/toN")))
+
+	var script bytes.Buffer
+	ensureError(t, WriteShellScript(&script, root, nil))
+
+	expected := `#!/bin/sh
+set -e
+touch './-rf'
+chmod 0644 './-rf'
+mkdir -p './a'
+touch './a/b'
+chmod 0600 './a/b'
+touch './a/it'\''s'
+chmod 0755 './a/it'\''s'
+mkdir -p './c'
+ln -s -- '../d' './c/toD'
+ln -s -- '-n' './c/toN'
+touch './d'
+chmod 0644 './d'
+`
+	if got, want := script.String(), expected; got != want {
+		t.Errorf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+
+	t.Run("reproduces structure", func(t *testing.T) {
+		sh, err := exec.LookPath("sh")
+		if err != nil {
+			t.Skip("cannot find shell")
+		}
+
+		target, cleanupTarget := setupTree(t)
+		defer cleanupTarget()
+
+		cmd := exec.Command(sh)
+		cmd.Dir = target
+		cmd.Stdin = &script
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s: %s", err, output)
+		}
+
+		listing := func(osDirname string) []string {
+			var entries []string
+			err := Walk(osDirname, &Options{
+				Callback: func(osPathname string, de *Dirent) error {
+					fi, err := os.Lstat(osPathname)
+					if err != nil {
+						return err
+					}
+					rel, err := filepath.Rel(osDirname, osPathname)
+					if err != nil {
+						return err
+					}
+					if de.IsRegular() {
+						entries = append(entries, fi.Mode().String()+" "+rel)
+					} else {
+						entries = append(entries, de.ModeType().String()+" "+rel)
+					}
+					return nil
+				},
+			})
+			ensureError(t, err)
+			return entries
+		}
+
+		ensureStringSlicesMatch(t, listing(target), listing(root))
+
+		referent, err := os.Readlink(filepath.Join(target, "c/toD"))
+		ensureError(t, err)
+		if got, want := referent, "../d"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		referent, err = os.Readlink(filepath.Join(target, "c/toN"))
+		ensureError(t, err)
+		if got, want := referent, "-n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		buf, err := ioutil.ReadFile(filepath.Join(target, "d"))
+		ensureError(t, err)
+		if got, want := len(buf), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
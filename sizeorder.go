@@ -0,0 +1,78 @@
+package godirwalk
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// sizeOrderedFile is a regular file gathered by walkGlobalSizeOrder, along with
+// the pathname to provide to Callback.
+type sizeOrderedFile struct {
+	rankedDirent
+	osPathname string
+}
+
+// walkGlobalSizeOrder walks the hierarchy rooted at the specified pathname,
+// gathering its regular files, then invokes Callback for each of them in
+// descending order by size, as described for the GlobalSizeOrder option.
+func walkGlobalSizeOrder(pathname string, options *Options) error {
+	gather := copyOptions(options)
+	gather.GlobalSizeOrder = false
+	gather.CallbackWithShard = nil
+	gather.PostChildrenCallback = nil
+	gather.PathSink = nil
+	gather.CallbackQueueSize = 0
+	gather.CheckpointFile = ""
+	gather.SyncAfterCallback = false
+
+	var files []sizeOrderedFile
+	gather.Callback = func(osPathname string, de *Dirent) error {
+		if !de.IsRegular() {
+			return nil
+		}
+		fi := de.fileInfo
+		if fi == nil {
+			var err error
+			if fi, err = os.Lstat(de.osPathname()); err != nil {
+				return err
+			}
+		}
+		retained := *de
+		retained.fileInfo = fi
+		files = append(files, sizeOrderedFile{rankedDirent: rankedDirent{rank: fi.Size(), de: &retained}, osPathname: osPathname})
+		return nil
+	}
+	if err := Walk(pathname, &gather); err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[j].less(files[i].rankedDirent) })
+
+	handleError := errorCallback(options)
+	deferSiblings := options.DeferSiblingErrors && !options.FailFast
+	var deferred error // first error whose handling awaits remaining files
+	for _, file := range files {
+		if options.PathSink != nil {
+			if _, err := io.WriteString(options.PathSink, file.de.path+"\n"); err != nil {
+				return err
+			}
+		}
+		err := callUpstream(file.osPathname, file.de, options)
+		if err == nil {
+			continue
+		}
+		if err == filepath.SkipDir {
+			return deferred
+		}
+		if action := handleError(file.osPathname, err); action == SkipNode {
+			continue
+		}
+		if !deferSiblings {
+			return err
+		}
+		deferred = firstError(deferred, err)
+	}
+	return deferred
+}
@@ -0,0 +1,103 @@
+package godirwalk
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkGlobalSizeOrder(t *testing.T) {
+	// Each file contains its entry name followed by a newline, so its size is
+	// one more than the length of its entry name.
+	root, cleanup := setupTree(t, "a", "d1/bbbbbbbb", "d1/cc", "d1/d2/dddddd", "d1/d2/ee", "ff", "g/", "zz/yy")
+	defer cleanup()
+
+	walkOrderWith := func(t *testing.T, options *Options, callback func(string) error) ([]string, error) {
+		t.Helper()
+		var actual []string
+		options.GlobalSizeOrder = true
+		options.ScratchBuffer = testScratchBuffer
+		options.Callback = func(osPathname string, de *Dirent) error {
+			if got, want := de.Path(), osPathname; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return callback(filepath.ToSlash(rel))
+		}
+		options.PostChildrenCallback = func(osPathname string, _ *Dirent) error {
+			t.Errorf("GOT: PostChildrenCallback for %q; WANT: none", osPathname)
+			return nil
+		}
+		err := Walk(root, options)
+		return actual, err
+	}
+
+	walkOrder := func(t *testing.T, callback func(string) error) ([]string, error) {
+		t.Helper()
+		return walkOrderWith(t, &Options{}, callback)
+	}
+
+	actual, err := walkOrder(t, func(string) error { return nil })
+	ensureError(t, err)
+	expected := "d1/d2/dddddd d1/bbbbbbbb d1/d2/ee d1/cc zz/yy ff a"
+	if got, want := strings.Join(actual, " "), expected; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("skip remaining", func(t *testing.T) {
+		actual, err := walkOrder(t, func(rel string) error {
+			if rel == "d1/d2/ee" {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		ensureError(t, err)
+		if got, want := strings.Join(actual, " "), "d1/d2/dddddd d1/bbbbbbbb d1/d2/ee"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		actual, err := walkOrder(t, func(string) error { return errStop })
+		if got, want := err, errStop; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(actual), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		errStop := errors.New("stop")
+		actual, err := walkOrderWith(t, &Options{
+			FailFast: true,
+			ErrorCallback: func(osPathname string, err error) ErrorAction {
+				t.Errorf("GOT: %q: %v; WANT: no error hook", osPathname, err)
+				return SkipNode
+			},
+		}, func(string) error { return errStop })
+		if got, want := err, errStop; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(actual), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("defer sibling errors", func(t *testing.T) {
+		actual, err := walkOrderWith(t, &Options{DeferSiblingErrors: true}, func(rel string) error {
+			if rel == "d1/bbbbbbbb" || rel == "ff" {
+				return errors.New(rel)
+			}
+			return nil
+		})
+		ensureError(t, err, "d1/bbbbbbbb")
+		if got, want := strings.Join(actual, " "), expected; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+/*
+Package sqlsink populates a SQL database with metadata about the file system
+nodes in a directory hierarchy.
+
+The SQL statements this package executes use the dialect of SQLite, in
+particular its `INSERT OR REPLACE` statement and `?` parameter placeholders.
+
+    db, err := sql.Open("sqlite3", "files.db")
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+    if err = sqlsink.PopulateDB(db, "some/directory", nil); err != nil {
+        return err
+    }
+*/
+package sqlsink
+
+import (
+	"database/sql"
+	"os"
+
+	"github.com/karrick/godirwalk"
+)
+
+// schema creates the files table when it does not already exist. The path
+// column is the primary key so that subsequent walks replace existing rows.
+const schema = `CREATE TABLE IF NOT EXISTS files (
+	path TEXT PRIMARY KEY,
+	name TEXT,
+	type TEXT,
+	size INTEGER,
+	mtime DATETIME
+)`
+
+// upsert inserts a row for a file system node, replacing any existing row for
+// the same path.
+const upsert = `INSERT OR REPLACE INTO files (path, name, type, size, mtime) VALUES (?, ?, ?, ?, ?)`
+
+// execer is the subset of methods common to sql.DB and sql.Tx needed to create
+// the schema.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// CreateSchema creates the files table in the database, unless it already
+// exists. The table has the columns path, name, type, size, and mtime.
+func CreateSchema(db *sql.DB) error { return createSchema(db) }
+
+func createSchema(db execer) error {
+	_, err := db.Exec(schema)
+	return err
+}
+
+// PopulateDB walks the file system hierarchy rooted at the specified directory,
+// and inserts one row into the files table for each node it encounters,
+// creating the table if necessary. Rows for nodes that were inserted by a
+// previous invocation are replaced, so the database may be updated
+// incrementally. The entire walk is performed in a single transaction, which
+// is rolled back when the walk returns an error, so the database never
+// reflects a walk that failed. A walk that succeeds may nevertheless not visit
+// every node: nodes for which ErrorCallback returns SkipNode, and nodes
+// excluded by options such as ExcludeRegexp or ShouldVisit, have no rows
+// inserted, and any rows a previous invocation inserted for them remain.
+//
+// Only the fields of the provided Options, which may be nil, that
+// godirwalk.HelperOptions copies are used.
+func PopulateDB(db *sql.DB, root string, opts *godirwalk.Options) error {
+	options := godirwalk.HelperOptions(opts)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = createSchema(tx); err != nil {
+		_ = tx.Rollback() // ignore potential error returned by Rollback
+		return err
+	}
+
+	stmt, err := tx.Prepare(upsert)
+	if err != nil {
+		_ = tx.Rollback() // ignore potential error returned by Rollback
+		return err
+	}
+
+	options.Callback = func(osPathname string, de *godirwalk.Dirent) error {
+		fi, err := os.Lstat(osPathname)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(osPathname, de.Name(), typeName(de.ModeType()), fi.Size(), fi.ModTime())
+		return err
+	}
+
+	err = godirwalk.Walk(root, &options)
+	if er := stmt.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		_ = tx.Rollback() // ignore potential error returned by Rollback
+		return err
+	}
+	return tx.Commit()
+}
+
+// typeName returns the value stored in the type column for the specified mode
+// type.
+func typeName(modeType os.FileMode) string {
+	switch {
+	case modeType&os.ModeSymlink != 0:
+		return "symlink"
+	case modeType&os.ModeDir != 0:
+		return "directory"
+	case modeType&os.ModeCharDevice != 0:
+		return "char-device"
+	case modeType&os.ModeDevice != 0:
+		return "device"
+	case modeType&os.ModeNamedPipe != 0:
+		return "pipe"
+	case modeType&os.ModeSocket != 0:
+		return "socket"
+	case modeType&os.ModeType == 0:
+		return "regular"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,218 @@
+package sqlsink
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/karrick/godirwalk"
+)
+
+// recordingDriver is a minimal database/sql driver that records the rows
+// upserted into the files table, so tests need not depend on a real database.
+type recordingDriver struct {
+	mu        sync.Mutex
+	created   bool
+	committed map[string][]driver.Value // rows visible after commit
+	pending   map[string][]driver.Value // rows in the open transaction
+	failOn    string                    // path whose insertion fails
+}
+
+func (d *recordingDriver) Open(_ string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{d: c.d, query: query}, nil
+}
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.pending = make(map[string][]driver.Value)
+	for k, v := range c.d.committed {
+		c.d.pending[k] = v
+	}
+	return &recordingTx{c.d}, nil
+}
+
+type recordingTx struct{ d *recordingDriver }
+
+func (tx *recordingTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.committed, tx.d.pending = tx.d.pending, nil
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.pending = nil
+	return nil
+}
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS files"):
+		s.d.created = true
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE INTO files"):
+		if !s.d.created {
+			return nil, errors.New("no such table: files")
+		}
+		path := args[0].(string)
+		if path == s.d.failOn {
+			return nil, errors.New("injected failure")
+		}
+		s.d.pending[path] = args
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(_ []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+var driverCount int
+
+// openRecordingDB registers a new recordingDriver and returns it along with a
+// database handle using it.
+func openRecordingDB(t *testing.T) (*recordingDriver, *sql.DB) {
+	t.Helper()
+	d := new(recordingDriver)
+	driverCount++
+	name := fmt.Sprintf("recording%d", driverCount)
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, db
+}
+
+func setupTree(t *testing.T) (string, func()) {
+	t.Helper()
+	root, err := ioutil.TempDir(os.TempDir(), "godirwalk-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(root, "d1"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(root, "d1/f1"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root, func() { _ = os.RemoveAll(root) }
+}
+
+func TestPopulateDB(t *testing.T) {
+	root, cleanup := setupTree(t)
+	defer cleanup()
+
+	d, db := openRecordingDB(t)
+	defer db.Close()
+
+	if err := PopulateDB(db, root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(d.committed), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	row := d.committed[filepath.Join(root, "d1/f1")]
+	if row == nil {
+		t.Fatalf("GOT: %v; WANT: row for f1", d.committed)
+	}
+	if got, want := row[1], "f1"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := row[2], "regular"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := row[3], int64(6); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	if got, want := d.committed[filepath.Join(root, "d1")][2], "directory"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("incremental", func(t *testing.T) {
+		if err := ioutil.WriteFile(filepath.Join(root, "f2"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := PopulateDB(db, root, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(d.committed), 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		before := len(d.committed)
+		d.failOn = filepath.Join(root, "d1/f1")
+		if err := ioutil.WriteFile(filepath.Join(root, "f3"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := PopulateDB(db, root, nil); err == nil {
+			t.Fatalf("GOT: %v; WANT: error", err)
+		}
+		if got, want := len(d.committed), before; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		// The insert for f1 still fails, but ErrorCallback skips it, so the
+		// walk commits, with the row for f1 left as the previous walk wrote it.
+		if err := ioutil.WriteFile(filepath.Join(root, "d1/f1"), []byte("hello, world\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := PopulateDB(db, root, &godirwalk.Options{
+			ErrorCallback: func(string, error) godirwalk.ErrorAction { return godirwalk.SkipNode },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(d.committed), 5; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := d.committed[filepath.Join(root, "d1/f1")][3], int64(6); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestCreateSchema(t *testing.T) {
+	d, db := openRecordingDB(t)
+	defer db.Close()
+
+	if err := CreateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.created, true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,5 @@
+package godirwalk
+
+// isStale always returns false, because Plan 9 does not report stale file
+// handles.
+func isStale(_ error) bool { return false }
@@ -0,0 +1,15 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isStale returns true if and only if the error indicates that a file handle
+// has become stale, as NFS clients report when the node it refers to has been
+// removed or replaced on the server.
+func isStale(err error) bool {
+	return errors.Is(err, syscall.ESTALE)
+}
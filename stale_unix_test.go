@@ -0,0 +1,69 @@
+// +build !windows,!plan9
+
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWalkRetryOnStale(t *testing.T) {
+	defer func(delay time.Duration) { staleRetryDelay = delay }(staleRetryDelay)
+	staleRetryDelay = 0
+
+	root := filepath.Join(testRoot, "d0/d1")
+
+	// walkWithStaleReads walks root, with the specified number of reads of the
+	// root directory failing with ESTALE, returning the number of reads
+	// attempted.
+	walkWithStaleReads := func(t *testing.T, staleReads int, retryOnStale bool) (int, error) {
+		t.Helper()
+		defer func(original func(string, []byte) (Dirents, error)) { readDirents = original }(readDirents)
+		var reads int
+		readDirents = func(osDirname string, scratchBuffer []byte) (Dirents, error) {
+			if osDirname == root {
+				if reads++; reads <= staleReads {
+					return nil, &os.PathError{Op: "open", Path: osDirname, Err: syscall.ESTALE}
+				}
+			}
+			return ReadDirents(osDirname, scratchBuffer)
+		}
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			RetryOnStale:  retryOnStale,
+			Callback:      func(string, *Dirent) error { return nil },
+		})
+		return reads, err
+	}
+
+	t.Run("recovers", func(t *testing.T) {
+		reads, err := walkWithStaleReads(t, 3, true)
+		ensureError(t, err)
+		if got, want := reads, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		reads, err := walkWithStaleReads(t, 4, true)
+		if !isStale(err) {
+			t.Errorf("GOT: %v; WANT: %v", err, syscall.ESTALE)
+		}
+		if got, want := reads, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		reads, err := walkWithStaleReads(t, 1, false)
+		if !isStale(err) {
+			t.Errorf("GOT: %v; WANT: %v", err, syscall.ESTALE)
+		}
+		if got, want := reads, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,5 @@
+package godirwalk
+
+// isStale always returns false, because Windows does not report stale file
+// handles.
+func isStale(_ error) bool { return false }
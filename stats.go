@@ -0,0 +1,42 @@
+package godirwalk
+
+import "sync/atomic"
+
+// WalkStats holds counters of the work Walk performs, which may help diagnose
+// slow walks. Walk resets the counters upon being invoked, and updates them
+// while walking, so they should not be read until Walk returns.
+type WalkStats struct {
+	// SymlinksFollowed is the number of times Walk resolved the referent of
+	// a symbolic link, such as to determine whether it refers to a directory.
+	// It may exceed the number of symbolic links visited, because some
+	// combinations of options cause a link to be resolved more than once.
+	SymlinksFollowed uint64
+
+	// EvalSymlinkCalls is the number of times Walk invoked
+	// filepath.EvalSymlinks to obtain the pathname returned by the RealPath
+	// method of a Dirent, each of which resolves every symbolic link in the
+	// pathname.
+	EvalSymlinkCalls uint64
+}
+
+// symlinkFollowed counts the resolution of the referent of a symbolic link.
+func (s *WalkStats) symlinkFollowed() {
+	if s != nil {
+		atomic.AddUint64(&s.SymlinksFollowed, 1)
+	}
+}
+
+// evalSymlinksCalled counts an invocation of filepath.EvalSymlinks.
+func (s *WalkStats) evalSymlinksCalled() {
+	if s != nil {
+		atomic.AddUint64(&s.EvalSymlinkCalls, 1)
+	}
+}
+
+// reset zeroes the counters.
+func (s *WalkStats) reset() {
+	if s != nil {
+		atomic.StoreUint64(&s.SymlinksFollowed, 0)
+		atomic.StoreUint64(&s.EvalSymlinkCalls, 0)
+	}
+}
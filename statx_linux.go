@@ -0,0 +1,149 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The statx mask bits this library refers to. These have the same values as
+// the STATX_* constants from golang.org/x/sys/unix.
+const (
+	statxType  = 0x1
+	statxMode  = 0x2
+	statxMtime = 0x40
+	statxSize  = 0x200
+)
+
+// atSymlinkNofollow directs statx to not follow a symbolic link named by the
+// final pathname component.
+const atSymlinkNofollow = 0x100
+
+// atFdcwd directs statx to resolve relative pathnames from the current working
+// directory. It is a variable because the negative value cannot be converted to
+// a uintptr as a constant.
+var atFdcwd = -0x64
+
+// sysStatx is the statx system call number for this architecture, or zero
+// when this library does not know it, in which case os.Lstat is used instead.
+var sysStatx = map[string]uintptr{
+	"386":      383,
+	"amd64":    332,
+	"arm":      397,
+	"arm64":    291,
+	"loong64":  291,
+	"mips":     4366,
+	"mipsle":   4366,
+	"mips64":   5326,
+	"mips64le": 5326,
+	"ppc64":    383,
+	"ppc64le":  383,
+	"riscv64":  291,
+	"s390x":    379,
+}[runtime.GOARCH]
+
+// statxTimestamp mirrors struct statx_timestamp from linux/stat.h.
+type statxTimestamp struct {
+	Sec  int64
+	Nsec uint32
+	_    int32
+}
+
+// statxT mirrors struct statx from linux/stat.h.
+type statxT struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	Uid            uint32
+	Gid            uint32
+	Mode           uint16
+	_              uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+	Atime          statxTimestamp
+	Btime          statxTimestamp
+	Ctime          statxTimestamp
+	Mtime          statxTimestamp
+	RdevMajor      uint32
+	RdevMinor      uint32
+	DevMajor       uint32
+	DevMinor       uint32
+	_              [14]uint64
+}
+
+// statxFileInfo is the os.FileInfo returned by lstatMask. Only the fields
+// requested by the mask are guaranteed to be populated; the others have their
+// zero-values.
+type statxFileInfo struct {
+	name string
+	stx  statxT
+}
+
+func (fi *statxFileInfo) Name() string     { return fi.name }
+func (fi *statxFileInfo) Size() int64      { return int64(fi.stx.Size) }
+func (fi *statxFileInfo) IsDir() bool      { return fi.Mode().IsDir() }
+func (fi *statxFileInfo) Sys() interface{} { return &fi.stx }
+
+func (fi *statxFileInfo) ModTime() time.Time {
+	return time.Unix(fi.stx.Mtime.Sec, int64(fi.stx.Mtime.Nsec))
+}
+
+// Mode converts the raw mode bits to an os.FileMode in the same way the os
+// package does.
+func (fi *statxFileInfo) Mode() os.FileMode {
+	mode := os.FileMode(fi.stx.Mode & 0777)
+	switch fi.stx.Mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		mode |= os.ModeDevice
+	case syscall.S_IFCHR:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case syscall.S_IFDIR:
+		mode |= os.ModeDir
+	case syscall.S_IFIFO:
+		mode |= os.ModeNamedPipe
+	case syscall.S_IFLNK:
+		mode |= os.ModeSymlink
+	case syscall.S_IFSOCK:
+		mode |= os.ModeSocket
+	}
+	if fi.stx.Mode&syscall.S_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if fi.stx.Mode&syscall.S_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if fi.stx.Mode&syscall.S_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// lstatMask returns the os.FileInfo for the specified pathname without
+// following symbolic links, requesting only the fields specified by mask from
+// the operating system using statx(2). When statx is not available, it falls
+// back to os.Lstat, which obtains all fields.
+func lstatMask(osPathname string, mask uint32) (os.FileInfo, error) {
+	if sysStatx == 0 {
+		return os.Lstat(osPathname)
+	}
+	p, err := syscall.BytePtrFromString(osPathname)
+	if err != nil {
+		return nil, &os.PathError{Op: "statx", Path: osPathname, Err: err}
+	}
+	// The type and mode are always needed to satisfy os.FileInfo.
+	fi := &statxFileInfo{name: filepath.Base(osPathname)}
+	_, _, errno := syscall.Syscall6(sysStatx, uintptr(atFdcwd), uintptr(unsafe.Pointer(p)), atSymlinkNofollow, uintptr(mask|statxType|statxMode), uintptr(unsafe.Pointer(&fi.stx)), 0)
+	if errno == syscall.ENOSYS {
+		return os.Lstat(osPathname) // kernel predates statx
+	}
+	if errno != 0 {
+		return nil, &os.PathError{Op: "statx", Path: osPathname, Err: errno}
+	}
+	return fi, nil
+}
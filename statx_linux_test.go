@@ -0,0 +1,47 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLstatMask(t *testing.T) {
+	for _, name := range []string{"d0/f1", "d0/d1", "d0/symlinks/toF1"} {
+		osPathname := filepath.Join(testRoot, name)
+
+		expected, err := os.Lstat(osPathname)
+		ensureError(t, err)
+
+		actual, err := lstatMask(osPathname, statxSize|statxMtime)
+		ensureError(t, err)
+
+		if got, want := actual.Name(), expected.Name(); got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", name, got, want)
+		}
+		if got, want := actual.Mode(), expected.Mode(); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+		if got, want := actual.Size(), expected.Size(); got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+		if got, want := actual.ModTime(), expected.ModTime(); !got.Equal(want) {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+	}
+}
+
+func TestLstatMaskTypeOnly(t *testing.T) {
+	actual, err := lstatMask(filepath.Join(testRoot, "d0/d1"), statxType)
+	ensureError(t, err)
+	if got, want := actual.IsDir(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestLstatMaskMissing(t *testing.T) {
+	_, err := lstatMask(filepath.Join(testRoot, "d0/missing"), statxType)
+	if !os.IsNotExist(err) {
+		t.Errorf("GOT: %v; WANT: %v", err, os.ErrNotExist)
+	}
+}
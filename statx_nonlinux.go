@@ -0,0 +1,12 @@
+// +build !linux
+
+package godirwalk
+
+import "os"
+
+// lstatMask returns the os.FileInfo for the specified pathname without
+// following symbolic links. Because statx(2) is only available on Linux, the
+// mask is ignored and all fields are obtained.
+func lstatMask(osPathname string, _ uint32) (os.FileInfo, error) {
+	return os.Lstat(osPathname)
+}
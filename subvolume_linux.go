@@ -0,0 +1,26 @@
+package godirwalk
+
+import "syscall"
+
+// The values btrfs reports for its file systems and the roots of its
+// subvolumes.
+const (
+	btrfsSuperMagic        = 0x9123683e
+	btrfsFirstFreeObjectID = 256 // inode number of the root of every subvolume
+)
+
+// isSubvolume returns true when the specified directory is the root of a btrfs
+// subvolume, which is recognized in the same way as btrfs-progs does, by its
+// inode number, rather than using BTRFS_IOC_INO_LOOKUP, which requires
+// privileges.
+func isSubvolume(osDirname string) bool {
+	var st syscall.Stat_t
+	if err := syscall.Stat(osDirname, &st); err != nil || st.Ino != btrfsFirstFreeObjectID {
+		return false // let reading the directory report any error
+	}
+	var sfs syscall.Statfs_t
+	if err := syscall.Statfs(osDirname, &sfs); err != nil {
+		return false
+	}
+	return uint32(sfs.Type) == btrfsSuperMagic
+}
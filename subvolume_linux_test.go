@@ -0,0 +1,43 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkSkipSubvolumes(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "d")
+	defer cleanup()
+	if isSubvolume(filepath.Join(root, "a")) {
+		t.Skip("temporary directory unexpectedly holds a subvolume")
+	}
+
+	var actual []string
+	err := Walk(root, &Options{
+		SkipSubvolumes: true,
+		ScratchBuffer:  testScratchBuffer,
+		Callback: func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		},
+		ErrorCallback: func(osPathname string, err error) ErrorAction {
+			t.Errorf("GOT: %q: %v; WANT: no error", osPathname, err)
+			return Halt
+		},
+	})
+	ensureError(t, err)
+
+	// Ordinary directories are not subvolume boundaries.
+	if got, want := strings.Join(actual, " "), ". a a/b a/b/c d"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		if got, want := isSubvolume(filepath.Join(root, "missing")), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
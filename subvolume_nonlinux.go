@@ -0,0 +1,6 @@
+// +build !linux
+
+package godirwalk
+
+// isSubvolume always returns false, because btrfs is only available on Linux.
+func isSubvolume(_ string) bool { return false }
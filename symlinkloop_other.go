@@ -0,0 +1,10 @@
+// +build !plan9
+
+package godirwalk
+
+import "syscall"
+
+// isSymlinkLoop returns true if and only if the operating system error
+// indicates a symbolic link could not be resolved because it refers through too
+// many symbolic links.
+func isSymlinkLoop(err error) bool { return err == syscall.ELOOP }
@@ -0,0 +1,17 @@
+// +build !plan9
+
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestNodeErrorSymlinkCycle(t *testing.T) {
+	de := &Dirent{name: "name", path: "dir/name"}
+	pe := &os.PathError{Op: "stat", Path: "dir/name", Err: syscall.ELOOP}
+	if err, ok := nodeError(de, pe).(*SymlinkCycleError); !ok {
+		t.Errorf("GOT: %T; WANT: %T", err, &SymlinkCycleError{})
+	}
+}
@@ -0,0 +1,4 @@
+package godirwalk
+
+// isSymlinkLoop always returns false, because Plan 9 has no symbolic links.
+func isSymlinkLoop(_ error) bool { return false }
@@ -0,0 +1,31 @@
+package godirwalk
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// timeMachineExcludeAttr is the extended attribute `tmutil addexclusion` sets
+// on each file system node it excludes from Time Machine backups.
+const timeMachineExcludeAttr = "com.apple.metadata:com_apple_backup_excludeItem"
+
+// xattrNoFollow is the XATTR_NOFOLLOW option of getxattr(2).
+const xattrNoFollow = 0x0001
+
+// isTimeMachineExcluded returns true if and only if the specified file system
+// node, without following symbolic links, has the extended attribute marking
+// it excluded from Time Machine backups. Any error obtaining the attribute,
+// including its absence, is treated as the node not being excluded.
+func isTimeMachineExcluded(osPathname string) bool {
+	p, err := syscall.BytePtrFromString(osPathname)
+	if err != nil {
+		return false
+	}
+	a, err := syscall.BytePtrFromString(timeMachineExcludeAttr)
+	if err != nil {
+		return false
+	}
+	// A nil value buffer requests only the size of the attribute.
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), 0, 0, 0, xattrNoFollow)
+	return errno == 0
+}
@@ -0,0 +1,59 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// excludeFromTimeMachine marks the specified node excluded from Time Machine
+// backups, as `tmutil addexclusion` does.
+func excludeFromTimeMachine(tb testing.TB, osPathname string) {
+	tb.Helper()
+	p, err := syscall.BytePtrFromString(osPathname)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	a, err := syscall.BytePtrFromString(timeMachineExcludeAttr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	value := []byte("com.apple.backupd")
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(&value[0])), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		tb.Skip(errno) // file system does not support extended attributes
+	}
+}
+
+func TestWalkCheckTimeMachineExclusions(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "cache/c", "d", "e")
+	defer cleanup()
+	excludeFromTimeMachine(t, filepath.Join(root, "cache"))
+	excludeFromTimeMachine(t, filepath.Join(root, "d"))
+
+	walkNames := func(t *testing.T, check bool) string {
+		t.Helper()
+		var names []string
+		err := Walk(root, &Options{
+			CheckTimeMachineExclusions: check,
+			ScratchBuffer:              testScratchBuffer,
+			Callback: func(osPathname string, _ *Dirent) error {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				names = append(names, filepath.ToSlash(rel))
+				return nil
+			},
+		})
+		ensureError(t, err)
+		return strings.Join(names, " ")
+	}
+
+	if got, want := walkNames(t, true), ". a a/b e"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := walkNames(t, false), ". a a/b cache cache/c d e"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
@@ -0,0 +1,7 @@
+// +build !darwin
+
+package godirwalk
+
+// isTimeMachineExcluded always returns false, because Time Machine is only
+// available on macOS.
+func isTimeMachineExcluded(_ string) bool { return false }
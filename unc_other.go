@@ -0,0 +1,7 @@
+// +build !windows
+
+package godirwalk
+
+// uncRoot returns the pathname unchanged, because UNC pathnames are only
+// meaningful on Windows.
+func uncRoot(pathname string) (string, string, error) { return pathname, "", nil }
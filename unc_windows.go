@@ -0,0 +1,32 @@
+package godirwalk
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// uncRoot returns the pathname from which Walk begins walking the specified
+// cleaned pathname when HandleUNC is true, along with the name of its
+// Dirent. The server and share components of a UNC pathname together name a
+// volume rather than two directories, so a pathname naming only a share is
+// walked from the root directory of that share, and a pathname naming only a
+// server is rejected, because the shares of a server are not directory
+// entries. Other pathnames, including device pathnames beginning with `\\?\`
+// or `\\.\`, are returned unchanged.
+func uncRoot(pathname string) (string, string, error) {
+	if len(pathname) < 3 || !os.IsPathSeparator(pathname[0]) || !os.IsPathSeparator(pathname[1]) {
+		return pathname, "", nil
+	}
+	if pathname[2] == '?' || pathname[2] == '.' {
+		return pathname, "", nil
+	}
+	components := strings.FieldsFunc(pathname[2:], func(r rune) bool { return r < 0x80 && os.IsPathSeparator(uint8(r)) })
+	switch len(components) {
+	case 0, 1:
+		return "", "", fmt.Errorf("cannot Walk UNC server without share: %s", pathname)
+	case 2:
+		return `\\` + components[0] + `\` + components[1] + `\`, components[1], nil
+	}
+	return pathname, "", nil
+}
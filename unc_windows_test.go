@@ -0,0 +1,82 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUNCRoot(t *testing.T) {
+	server := `\\server`
+	share := filepath.Join(server, "share")
+
+	for _, tc := range []struct {
+		pathname string
+		root     string
+		name     string
+		err      string
+	}{
+		{server, "", "", "without share"},
+		{share, `\\server\share\`, "share", ""},
+		{share + `\`, `\\server\share\`, "share", ""},
+		{filepath.Join(share, "dir"), filepath.Join(share, "dir"), "", ""},
+		{`C:\dir`, `C:\dir`, "", ""},
+		{`\\?\C:\dir`, `\\?\C:\dir`, "", ""},
+	} {
+		root, name, err := uncRoot(tc.pathname)
+		if tc.err != "" {
+			ensureError(t, err, tc.err)
+			continue
+		}
+		ensureError(t, err)
+		if got, want := root, tc.root; got != want {
+			t.Errorf("%q: GOT: %q; WANT: %q", tc.pathname, got, want)
+		}
+		if got, want := name, tc.name; got != want {
+			t.Errorf("%q: GOT: %q; WANT: %q", tc.pathname, got, want)
+		}
+	}
+}
+
+func TestWalkHandleUNC(t *testing.T) {
+	root, cleanup := setupTree(t, "d/f")
+	defer cleanup()
+
+	// Reach the test directory through the administrative share of its drive.
+	volume := filepath.VolumeName(root)
+	if len(volume) != 2 || volume[1] != ':' {
+		t.Skipf("GOT: volume %q; WANT: drive letter", volume)
+	}
+	uncRoot := filepath.Join(`\\localhost`, volume[:1]+"$", root[len(volume):])
+	if _, err := os.Lstat(uncRoot); err != nil {
+		t.Skip(err) // administrative shares are not available
+	}
+
+	var actual []string
+	err := Walk(uncRoot, &Options{
+		HandleUNC:     true,
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			if got, want := de.Path(), osPathname; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			actual = append(actual, osPathname)
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	expected := []string{uncRoot, filepath.Join(uncRoot, "d"), filepath.Join(uncRoot, "d", "f")}
+	if got, want := strings.Join(actual, "; "), strings.Join(expected, "; "); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("server", func(t *testing.T) {
+		err := Walk(`\\localhost`, &Options{
+			HandleUNC: true,
+			Callback:  func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "without share")
+	})
+}
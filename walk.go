@@ -3,9 +3,15 @@ package godirwalk
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
+	"sync"
+	"time"
 )
 
 // DefaultScratchBufferSize specifies the size of the scratch buffer that will
@@ -42,17 +48,134 @@ type Options struct {
 	//
 	// ErrorCallback is invoked both for errors that are returned by the
 	// runtime, and for errors returned by other user supplied callback
-	// functions.
+	// functions. Errors returned by the runtime are provided as returned, so
+	// they may be inspected using os.IsNotExist, os.IsPermission, and
+	// errors.Is, unless TypedErrors is set.
 	ErrorCallback func(string, error) ErrorAction
 
+	// TypedErrors specifies whether errors returned by the runtime while
+	// visiting a node are provided to ErrorCallback, and returned by Walk, as
+	// a *PathError, *PermissionError, or *SymlinkCycleError, each of which
+	// holds the Dirent of that node, and which may be identified using
+	// errors.As, without inspecting the error message. Because os.IsNotExist
+	// and os.IsPermission do not unwrap errors, use errors.Is with
+	// os.ErrNotExist or os.ErrPermission to inspect them when this option is
+	// set.
+	//
+	//    ErrorCallback: func(osPathname string, err error) godirwalk.ErrorAction {
+	//        var pe *godirwalk.PermissionError
+	//        if errors.As(err, &pe) {
+	//            log.Printf("skipping inaccessible %s", pe.Dirent.ModeType())
+	//            return godirwalk.SkipNode
+	//        }
+	//        return godirwalk.Halt
+	//    },
+	//    TypedErrors: true,
+	TypedErrors bool
+
+	// SilentPermissionErrors specifies whether Walk skips directories it is
+	// not permitted to read without invoking ErrorCallback, as though
+	// ErrorCallback had returned SkipNode, even when FailFast is set. Callback
+	// and PostChildrenCallback are still invoked for such directories, so
+	// that each directory reported to one is reported to the other. This
+	// declutters scans of system directories, where such errors are
+	// expected and uninteresting. Other errors, including permission errors
+	// not encountered while reading a directory, are handled as usual.
+	SilentPermissionErrors bool
+
 	// FollowSymbolicLinks specifies whether Walk will follow symbolic links
 	// that refer to directories. When set to false or left as its zero-value,
 	// Walk will still invoke the callback function with symbolic link nodes,
 	// but if the symbolic link refers to a directory, it will not recurse on
 	// that directory. When set to true, Walk will recurse on symbolic links
-	// that refer to a directory.
+	// that refer to a directory, and the RealPath method of the Dirent of each
+	// symbolic link below the root returns the pathname it resolves to. When
+	// set to false, the callback function may instead return FollowThis to
+	// have Walk recurse on the directory referred to by a single symbolic
+	// link.
 	FollowSymbolicLinks bool
 
+	// OnDanglingSymlink specifies how Walk handles symbolic links whose
+	// referents do not exist. When left as its zero-value,
+	// DanglingSymlinkDefault, such symbolic links are reported to Callback
+	// like any other, and when FollowSymbolicLinks is set, the error
+	// resolving the referent is then handled as described for
+	// ErrorCallback. The other DanglingSymlinkAction values handle them
+	// consistently regardless of FollowSymbolicLinks, at the cost of
+	// resolving the referent of every symbolic link.
+	OnDanglingSymlink DanglingSymlinkAction
+
+	// AnnotateSymlinkTargets specifies whether Walk resolves the referent of
+	// each symbolic link prior to invoking Callback for it, so the TargetType
+	// method of its Dirent returns the mode type of the referent, such as to
+	// display whether the link refers to a directory. This costs one stat(2)
+	// per symbolic link, and does not cause Walk to follow links that refer to
+	// directories unless FollowSymbolicLinks is also set.
+	AnnotateSymlinkTargets bool
+
+	// CountSymlinkHops specifies whether Walk counts the symbolic links in
+	// the chain that begins with each symbolic link prior to invoking
+	// Callback for it, so the SymlinkHops method of its Dirent returns how
+	// many links must be followed to reach its referent, such as to find
+	// long chains that slow the walk. This costs one readlink(2) and lstat(2)
+	// per link in each chain.
+	CountSymlinkHops bool
+
+	// Stats is optionally provided to obtain counters of the work Walk
+	// performs, which Walk resets upon being invoked.
+	Stats *WalkStats
+
+	// RequireDir specifies whether Walk returns an error when the specified
+	// root is not a directory, or when FollowSymbolicLinks or
+	// ResolveRootSymlink is true, a symbolic link to a directory. When set to
+	// false or left as its zero-value, Walk invokes Callback once for such a
+	// root, and returns.
+	RequireDir bool
+
+	// HandleUNC specifies whether Walk recognizes a root that is a Windows
+	// UNC pathname, such as `\\server\share\path`. The server and share
+	// components of such a pathname together name a volume, and neither may
+	// be walked as an ordinary directory, so when set to true, Walk walks a
+	// root naming only a share from the root directory of that share, whose
+	// Dirent is named after the share, and returns an error for a root naming
+	// only a server. This option has no effect on other operating systems.
+	HandleUNC bool
+
+	// ResolveRootSymlink specifies whether Walk resolves the specified root
+	// when it is a symbolic link, and walks the directory it refers to, even
+	// when FollowSymbolicLinks is false. The pathnames of the nodes below the
+	// root are still composed from the pathname of the symbolic link. When
+	// set to false or left as its zero-value, such a root is only resolved
+	// when FollowSymbolicLinks is true, and is otherwise reported as a single
+	// node.
+	ResolveRootSymlink bool
+
+	// RetryOnStale specifies whether Walk retries reading a directory when the
+	// operating system reports that its file handle is stale, which NFS
+	// clients do when the directory has been removed or replaced on the
+	// server. When set to true, Walk retries reading the directory up to three
+	// times, waiting 100 milliseconds before each retry, and only when every
+	// retry fails handles the error as described for ErrorCallback. This has
+	// no effect on Windows.
+	RetryOnStale bool
+
+	// CheckpointFile optionally names a file in which Walk records its
+	// progress, so a walk that is interrupted, such as one lasting hours over
+	// a large network attached storage array, may be resumed rather than
+	// repeated. While walking, Walk periodically writes the pathname of the
+	// root, followed by the pathname of the node whose Callback most recently
+	// returned, to the file, one pathname per line. When the file exists upon
+	// invoking Walk with the same root, Walk skips the nodes preceding that
+	// node, without invoking Callback for them, or for the directories
+	// containing it. Callback may therefore be invoked again for nodes
+	// visited after the file was last written. Walk also writes the file when
+	// it returns an error, and removes the file when it completes
+	// successfully. Because resuming relies upon nodes being visited in
+	// lexical order, Walk returns an error when this option is combined with
+	// the Unsorted, CallbackQueueSize, DirPriority, or SortByCreationTime
+	// options.
+	CheckpointFile string
+
 	// Unsorted controls whether or not Walk will sort the immediate descendants
 	// of a directory by their relative names prior to visiting each of those
 	// entries.
@@ -71,10 +194,109 @@ type Options struct {
 	// next.
 	Unsorted bool
 
+	// StableUnsorted controls whether Walk, when Unsorted is true, guarantees
+	// that repeated walks using the same Options structure visit the immediate
+	// descendants of each directory in the same order, even when the
+	// operating system does not enumerate them in the same order each time.
+	//
+	// When set to true, the first time Walk visits a directory it records the
+	// order in which the operating system enumerated its descendants, and on
+	// subsequent walks it replays that order, visiting any descendants that
+	// were not previously seen after the others, in the order the operating
+	// system enumerated them. Unlike sorting, this takes time linear to the
+	// number of descendants, but at the cost of retaining the name of every
+	// directory entry visited for as long as the Options structure is in use.
+	// This option has no effect unless Unsorted is also true.
+	StableUnsorted bool
+
+	// SortByCreationTime specifies whether Walk visits the children of each
+	// directory in the order they were created, according to the creation
+	// time NTFS records for each node, rather than in lexical order. Children
+	// created at the same time are visited in lexical order, unless Unsorted
+	// is also true. On operating systems other than Windows, which do not
+	// consistently record creation times, this option has no effect. Walk
+	// returns an error when this option is combined with CheckpointFile on
+	// every operating system.
+	SortByCreationTime bool
+
+	// DirPriority is an optional function that Walk invokes with the pathname
+	// and Dirent of each child directory prior to visiting the children of a
+	// directory, so that some directories may be visited before others, for
+	// instance to visit directories named "src" before those named
+	// "vendor". Children are visited in descending order of priority, where
+	// non-directory children have a priority of zero, and children having the
+	// same priority are visited in the order they otherwise would be. Walk
+	// returns an error when this option is combined with CheckpointFile.
+	DirPriority func(osPathname string, de *Dirent) int
+
+	// StreamingOnly specifies whether Walk reads the immediate descendants of
+	// each directory one at a time as it visits them, rather than reading all
+	// of them into memory before visiting the first, so that the memory used
+	// by a walk is bounded by the depth of the hierarchy rather than by the
+	// number of entries in its widest directory. Because the descendants are
+	// never held in memory together, this option implies Unsorted, and Walk
+	// ignores the StableUnsorted, DirPriority, ParallelDirs, MaxEntriesPerDir,
+	// RetryOnStale, BypassAttrCache, SequentialHint, and MaxOpenDirs options,
+	// the last because a directory remains open while its descendants are
+	// walked.
+	StreamingOnly bool
+
 	// Callback is a required function that Walk will invoke for every file
-	// system node it encounters.
+	// system node it encounters, unless CallbackWithShard or
+	// CallbackWithUserData is provided.
 	Callback WalkFunc
 
+	// CallbackWithShard is an alternative to Callback that Walk invokes in its
+	// place when Callback is nil. In addition to the arguments provided to
+	// Callback, it receives the shard of the node, which is the 64-bit FNV-1a
+	// hash of the node's slash-separated pathname, modulo ShardCount when
+	// ShardCount is positive. Because the shard depends only on the pathname,
+	// it is the same on every walk, so that several processes may walk the
+	// same hierarchy with each handling only the nodes of its own shard.
+	CallbackWithShard func(shard uint64, osPathname string, de *Dirent) error
+
+	// ShardCount specifies the number of shards among which CallbackWithShard
+	// distributes nodes. When set to zero or left as its zero-value, the
+	// shard is the unreduced hash.
+	ShardCount uint64
+
+	// CallbackWithUserData is an alternative to Callback that Walk invokes in
+	// its place when both Callback and CallbackWithShard are nil. In addition
+	// to the arguments provided to Callback, it receives UserData unchanged,
+	// so that a callback configured from data need not be a closure
+	// capturing that data.
+	CallbackWithUserData func(osPathname string, de *Dirent, userData interface{}) error
+
+	// UserData is an optional value Walk provides to CallbackWithUserData for
+	// every node, and otherwise ignores.
+	UserData interface{}
+
+	// BaselineState is an optional snapshot of the hierarchy, created by
+	// NewWalkState, against which Walk compares each node as it visits it,
+	// detecting changes in a single pass. When provided, Walk invokes
+	// ChangeCallback with each node it visits, before invoking the callback
+	// function with it, and after reading the children of each directory,
+	// with each node of the snapshot below that directory that no longer
+	// exists. Nodes the walk does not visit, such as those below a directory
+	// for which the callback function returns filepath.SkipDir, are not
+	// reported as removed, nor are the children of a directory that was only
+	// partially read because it has more entries than MaxEntriesPerDir. This
+	// option may not be combined with StreamingOnly.
+	BaselineState *WalkState
+
+	// ChangeCallback is the function Walk invokes with how each node differs
+	// from BaselineState, and is required when BaselineState is provided. An
+	// error it returns is handled as one returned by the callback function.
+	// It receives a Dirent without file information for each removed node.
+	// Like the callback function, it is invoked concurrently when
+	// EagerDescend is set, and must then be safe for concurrent use.
+	ChangeCallback func(change ChangeType, de *Dirent) error
+
+	// RunStartCallback is an optional function that Walk invokes once, before
+	// visiting any node, with a new identifier for the walk, the time it
+	// began, and its root, for correlating the log records of a long walk.
+	RunStartCallback func(meta RunMeta)
+
 	// PostChildrenCallback is an option function that Walk will invoke for
 	// every file system directory it encounters after its children have been
 	// processed.
@@ -87,8 +309,487 @@ type Options struct {
 	// MinimumScratchBufferSize, then a buffer with DefaultScratchBufferSize
 	// bytes will be created and used once per Walk invocation.
 	ScratchBuffer []byte
+
+	// PathSink is an optional io.Writer to which Walk writes the pathname of
+	// every file system node it encounters, followed by a newline. The
+	// pathname is written synchronously, immediately prior to invoking
+	// Callback for that node. Any error returned while writing to PathSink is
+	// handled as though it were returned by Callback.
+	PathSink io.Writer
+
+	// MaxOpenDirs specifies the maximum number of directory handles Walk will
+	// hold open at any one time. When set to zero or left as its zero-value,
+	// Walk places no limit on the number of open directory handles. When
+	// positive, Walk blocks before opening a directory until the number of
+	// open directory handles drops below this value. This prevents a walk from
+	// failing with EMFILE on systems with a low limit on open file
+	// descriptors.
+	MaxOpenDirs int
+
+	// MaxEntriesPerDir specifies the maximum number of entries Walk will read
+	// from any one directory. When set to zero or left as its zero-value, Walk
+	// places no limit on the number of entries per directory. When positive,
+	// and a directory has more entries than this value, Walk discards the
+	// remaining entries, and invokes ErrorCallback with the pathname of the
+	// directory and ErrDirTooLarge. When ErrorCallback returns SkipNode, Walk
+	// continues by visiting the entries it read. This bounds the memory Walk
+	// allocates while reading untrusted file systems.
+	MaxEntriesPerDir int
+
+	// MaxAllocBytes specifies the maximum number of bytes of memory that may be
+	// allocated while walking. When set to zero or left as its zero-value, Walk
+	// places no limit on allocation. When positive, Walk compares the
+	// cumulative number of bytes allocated since the walk began, as reported by
+	// runtime.ReadMemStats, against this value before reading each directory,
+	// and halts, returning an error wrapping ErrAllocLimit, once it is
+	// exceeded. This is a best-effort guard for programs running with little
+	// memory: the allocations of every goroutine in the program are counted,
+	// not merely those made by Walk, and because runtime.ReadMemStats briefly
+	// stops the world, setting this option slows the walk.
+	MaxAllocBytes int64
+
+	// ParallelDirs specifies whether Walk reads the immediate descendants of
+	// child directories concurrently. When set to false or left as its
+	// zero-value, Walk reads each directory only when it is about to visit that
+	// directory's children. When set to true, upon reading a directory, Walk
+	// reads each of its child directories in a separate goroutine, with no
+	// more than runtime.NumCPU() directories being read at once. The Callback
+	// and PostChildrenCallback functions are still invoked serially and in the
+	// same order they would be were this option not set, but the operating
+	// system calls to read directories take place concurrently. This may
+	// significantly improve performance on file systems that service
+	// concurrent requests well, such as those backed by solid state drives, at
+	// the expense of holding the listings of more directories in memory.
+	ParallelDirs bool
+
+	// EagerDescend specifies whether Walk begins walking each child directory
+	// as soon as it encounters it, in a separate goroutine, while it continues
+	// visiting the remaining children of the parent. No more than
+	// runtime.NumCPU() child directories are walked in separate goroutines at
+	// once; when all of them are busy, Walk walks the child directory itself
+	// before continuing with its siblings. This may significantly improve
+	// throughput on deep hierarchies, but Callback, PostChildrenCallback,
+	// ErrorCallback, and PathSink when provided, must be safe for concurrent
+	// use, and nodes are visited in no particular order, although
+	// PostChildrenCallback is still invoked for a directory only after all of
+	// its descendants have been visited. When an error halts the walk, Walk
+	// stops descending into directories, waits for the walks in progress to
+	// return, and returns the first such error. Walk ignores the
+	// StableUnsorted option, and returns an error when this option is combined
+	// with CheckpointFile. Functions that build a result from a walk, such as
+	// WalkGraph and MerkleHash, ignore this option, as described for
+	// HelperOptions.
+	EagerDescend bool
+
+	// SkipNetworkFilesystems specifies whether Walk will skip the contents of
+	// directories that reside on network or FUSE file systems, such as nfs,
+	// cifs, and sshfs, which are typically much slower to enumerate than local
+	// file systems. When set to true, Walk still invokes the callback function
+	// and PostChildrenCallback with such directories, but does not recurse on
+	// them. Detection relies on the mount table, and is presently only
+	// supported on Linux.
+	SkipNetworkFilesystems bool
+
+	// BoundaryMarkers optionally lists names of file system nodes, such as
+	// ".git", which mark the directory containing any of them as a boundary,
+	// such as that of a nested repository. Walk still invokes the callback
+	// function and PostChildrenCallback with such directories, but does not
+	// recurse on them. The root is never treated as a boundary, so a walk may
+	// begin at a directory containing a marker.
+	BoundaryMarkers []string
+
+	// SkipSubvolumes specifies whether Walk stops at the root directory of
+	// each btrfs subvolume below the root of the walk, which appears as an
+	// ordinary directory but has its own device ID. Walk still invokes the
+	// callback function with such directories, then invokes ErrorCallback
+	// with ErrSubvolumeBoundary, and when it returns SkipNode, invokes
+	// PostChildrenCallback with them without recursing on them. Because the
+	// default ErrorCallback halts the walk, one that returns SkipNode for
+	// ErrSubvolumeBoundary is generally required. This option is presently
+	// only supported on Linux.
+	SkipSubvolumes bool
+
+	// SkipLockedEncryptedDirs specifies whether Walk skips directories
+	// protected by an fscrypt policy whose key is not available, which would
+	// otherwise present the names of their children as encoded ciphertext.
+	// Walk still invokes the callback function with such directories, then
+	// invokes ErrorCallback with ErrDirectoryEncrypted, and when it returns
+	// SkipNode, invokes PostChildrenCallback with them without recursing on
+	// them. Because the default ErrorCallback halts the walk, one that
+	// returns SkipNode for ErrDirectoryEncrypted is generally required. This
+	// option is presently only supported on Linux.
+	SkipLockedEncryptedDirs bool
+
+	// SkipZFSSnapshots specifies whether Walk skips the ZFS snapshots of the
+	// hierarchy, so they are not confused with live data. When set to true,
+	// Walk neither invokes the callback function with, nor recurses on, any
+	// directory named ".zfs", through which the snapshots of a ZFS dataset
+	// are accessed. On Linux and FreeBSD, Walk also does not recurse on
+	// directories at which a ZFS snapshot is mounted, as reported by the
+	// mount table, regardless of their names, although it still invokes the
+	// callback function and PostChildrenCallback with them.
+	SkipZFSSnapshots bool
+
+	// IncludeZFSSnapshots specifies whether Walk visits the ".zfs" directory
+	// at the root of each ZFS dataset, and thus its snapshots, even when the
+	// dataset hides the directory from directory listings, as it does by
+	// default. This option is presently only supported on Linux and FreeBSD,
+	// and Walk returns an error when it is combined with SkipZFSSnapshots.
+	IncludeZFSSnapshots bool
+
+	// PreloadFileInfo specifies whether Walk obtains the os.FileInfo for every
+	// file system node prior to invoking the callback function with it. When
+	// set to true, the os.FileInfo is available from the CachedFileInfo
+	// method of the provided Dirent, saving callbacks that always need it
+	// from invoking os.Lstat themselves. When set to false or left as its
+	// zero-value, Walk does not invoke os.Lstat, and CachedFileInfo returns
+	// nil.
+	PreloadFileInfo bool
+
+	// StatMask optionally specifies which fields of the os.FileInfo are
+	// obtained when PreloadFileInfo is true, using the same bit values as the
+	// STATX_* constants from golang.org/x/sys/unix. On Linux, the fields are
+	// obtained using statx(2), which may avoid fetching information that is
+	// expensive to obtain on some file systems. The file type and permission
+	// bits are always requested, so a mask of STATX_TYPE yields the most
+	// minimal stat, while a mask of STATX_SIZE|STATX_MTIME also provides the
+	// size and modification time. Fields that are not requested have their
+	// zero-values. When left as its zero-value, or on other operating systems,
+	// all fields are obtained using os.Lstat.
+	StatMask uint32
+
+	// UseIOURing specifies whether Walk uses io_uring on Linux to obtain the
+	// os.FileInfo of all children of each directory with a single system call
+	// when both PreloadFileInfo and StatMask are set, rather than invoking
+	// statx(2) once per child. Because the kernel performs these operations
+	// using its own worker threads, this improves throughput only when
+	// obtaining file information is slow relative to a system call, such as
+	// when it is not cached, so it should be measured, for instance using
+	// BenchmarkPreloadFileInfoIOURing. Directories are still opened and
+	// read using ordinary system calls, because io_uring provides no
+	// operation to read directory entries. When the kernel does not provide
+	// io_uring or its statx operation, which require Linux 5.6 or later, or
+	// does not permit the process to use it, Walk silently invokes statx(2)
+	// for each child instead. On other operating systems this option has no
+	// effect.
+	UseIOURing bool
+
+	// BypassAttrCache specifies whether Walk attempts to bypass any cache of
+	// directory attributes the operating system maintains, such as the one
+	// maintained by NFS clients, which may otherwise cause Walk to observe
+	// stale directory contents. On Linux, Walk has the file system revalidate
+	// the attributes of each directory using statx(2) with
+	// AT_STATX_FORCE_SYNC prior to reading it, which causes NFS clients to
+	// discard their cached entries for directories that have changed; on
+	// macOS, Walk disables caching on each directory handle using
+	// fcntl(F_NOCACHE). On other operating systems this option has no effect.
+	// This makes walks significantly slower, and should only be used when
+	// consistency is critical.
+	BypassAttrCache bool
+
+	// SequentialHint specifies whether Walk advises the operating system that
+	// each directory will be read sequentially. On Linux, Walk invokes
+	// posix_fadvise(2) with POSIX_FADV_SEQUENTIAL on each directory handle,
+	// ignoring any error, since the advice is merely a hint. The advice only
+	// enlarges the read-ahead window of the handle, which most file systems do
+	// not consult when reading directory entries, although ext4 does for
+	// directories without a hashed index, so it may improve read-ahead of the
+	// blocks of such directories on rotational disks, but otherwise has no
+	// effect. On other operating systems this option has no effect.
+	SequentialHint bool
+
+	// EntryDecoder optionally replaces the decoder Walk uses to parse the
+	// buffer of raw directory entries returned by each getdents(2) system
+	// call, or its equivalent, while reading a directory, so the parsing of
+	// what the file system returns may be fuzzed in isolation, synthetic
+	// entries may be injected in tests, or entries may be decoded for a file
+	// system having its own entry format. Each Dirent it returns must be
+	// created using NewDirentWithModeType, from the name of the entry, and
+	// Walk ignores any named "." or "..". This option has no effect on
+	// Windows, which does not provide raw directory entries, or when
+	// StreamingOnly is true.
+	EntryDecoder func(buf []byte) ([]*Dirent, error)
+
+	// NoReuseHint specifies whether the Open and NewReader methods of the
+	// Dirent provided to the upstream callback functions advise the operating
+	// system that the contents of each file they open will be read only once,
+	// so that reading them, for instance to hash them, need not evict
+	// frequently accessed pages, such as directory metadata, from the page
+	// cache. On Linux, the files are advised using posix_fadvise(2) with
+	// POSIX_FADV_NOREUSE, ignoring any error, since the advice is merely a
+	// hint. On other operating systems this option has no effect.
+	NoReuseHint bool
+
+	// NoATime specifies whether the Open and NewReader methods of the Dirent
+	// provided to the upstream callback functions open files without updating
+	// their access times, so that scanning their contents neither defeats
+	// caches that rely on access times nor causes journal writes. On Linux,
+	// the files are opened with O_NOATIME, which is only permitted when the
+	// process owns the file or has the CAP_FOWNER capability; other files are
+	// opened normally. On other operating systems this option has no effect.
+	NoATime bool
+
+	// IncludeADS specifies whether Walk, after invoking Callback for each
+	// regular file, invokes it again for each of the file's NTFS alternate
+	// data streams, which may hide content from tools that only read the
+	// default stream of each file. The Dirent of each stream is named
+	// "filename:streamname", its IsAlternateDataStream method returns true,
+	// and its Open method opens the stream. A SkipDir returned by Callback for
+	// a stream is handled as though returned for the file. On Windows, the
+	// streams are enumerated using FindFirstStreamW and FindNextStreamW. On
+	// other operating systems this option has no effect.
+	IncludeADS bool
+
+	// AccumulateSubtreeSizes specifies whether Walk totals the sizes of the
+	// file system nodes it visits, so that the SubtreeSize method of the
+	// Dirent provided to PostChildrenCallback returns the total size of all
+	// non-directory descendants of that directory, similar to the output of
+	// `du --apparent-size`. The Dirent provided to Callback for each
+	// non-directory node returns that node's own size. Directory nodes
+	// themselves do not contribute to the totals. Unless the node's
+	// os.FileInfo was already obtained, this requires Walk to invoke os.Lstat
+	// for each non-directory node.
+	AccumulateSubtreeSizes bool
+
+	// SyncAfterCallback specifies whether Walk durably writes the files that
+	// the Callback function opens using the OpenForWrite method of the
+	// provided Dirent. When set to true, after Callback returns, Walk invokes
+	// the Sync method and then the Close method of each such file, so
+	// Callback must not close those files itself. Any error returned by
+	// either method is handled as though it were returned by Callback.
+	//
+	// Syncing a file waits for the storage device to acknowledge the write,
+	// which typically takes orders of magnitude longer than the write itself,
+	// so only set this option when a crash must not lose the writes made by
+	// Callback.
+	SyncAfterCallback bool
+
+	// AssertReadOnly specifies whether Walk verifies that Callback does not
+	// modify the nodes it is invoked with, to catch accidental mutations by
+	// callbacks that are meant to only read. When set to true, Walk obtains
+	// the file information of each node before and after invoking Callback
+	// with it, and when its modification time or size changed, or it no
+	// longer exists, handles ErrModifiedByCallback, wrapped with the pathname
+	// of the node, as though it were returned by Callback. Note that a
+	// directory is modified when a node is created, removed, or renamed
+	// within it, and that modifications made within the resolution of the
+	// modification times of the file system may not change its size.
+	AssertReadOnly bool
+
+	// GlobalSizeOrder specifies whether Walk first gathers every regular file
+	// in the hierarchy, then invokes Callback for each of them in descending
+	// order by size, with ties broken by pathname, rather than for every node
+	// in the order encountered, such as to review the largest files first.
+	// Callback is not invoked for directories or other nodes, and returning
+	// filepath.SkipDir from it skips the remaining files. Because the Dirent
+	// and os.FileInfo of every regular file are held in memory until the walk
+	// completes, memory use is proportional to the number of files in the
+	// hierarchy. Errors returned by Callback are handled as usual, including
+	// as directed by FailFast, and DeferSiblingErrors treats every regular
+	// file as a sibling of the others. Walk ignores the PostChildrenCallback,
+	// CallbackQueueSize, CheckpointFile, and SyncAfterCallback options when
+	// this option is set.
+	// Functions that build a result from a walk, such as MerkleHash, need
+	// Callback to be invoked for directories, and ignore this option, as
+	// described for HelperOptions.
+	GlobalSizeOrder bool
+
+	// FailFast specifies whether Walk halts upon the first error, whether that
+	// error is returned by the operating system or by one of the upstream
+	// callback functions. When set to true, Walk does not invoke
+	// ErrorCallback, even when one is provided, and returns the first error,
+	// wrapped with the pathname of the node that caused it when the error
+	// does not already identify that pathname. When set to false or left as
+	// its zero-value, errors are handled as described for ErrorCallback.
+	FailFast bool
+
+	// DeferSiblingErrors specifies whether Walk continues visiting the
+	// remaining children of a directory after ErrorCallback returns Halt for
+	// an error encountered while visiting one of them, for instance when a
+	// child directory cannot be read. When set to true, the first such error
+	// is returned only after the remaining siblings have been visited,
+	// producing more complete output for partially broken directories, and
+	// PostChildrenCallback is not invoked for their parent. The walk still
+	// halts once that error is returned, without visiting the siblings of any
+	// ancestor. This option is ignored when FailFast is true.
+	DeferSiblingErrors bool
+
+	// NameTransform is an optional function that Walk applies to the name of
+	// every file system node below the root prior to reporting it to the
+	// upstream callback functions, for instance to redact or remap names that
+	// would otherwise be logged. When provided, the Name and Path methods of
+	// each Dirent, and the pathname provided to Callback and
+	// PostChildrenCallback, are composed of the transformed names, while Walk
+	// continues to access each node using its actual pathname, as do the
+	// OpenForWrite, Accessible, and FollowSymlink methods of the
+	// Dirent. ErrorCallback is still invoked with actual pathnames. The name
+	// and pathname of the root are not transformed.
+	NameTransform func(name string) string
+
+	// TransformPath is an optional function that Walk invokes with the actual
+	// pathname and Dirent of a file system node, including the root, each
+	// time it provides the node to ShouldVisit, Callback, or
+	// PostChildrenCallback, which receive the pathname it returns in place of
+	// the one they would otherwise receive, so it takes precedence over
+	// NameTransform and CwdRelative. Walk, ErrorCallback, PathSink, and the
+	// Dirent, including its Path method, continue to use the actual pathname.
+	// It must be safe for concurrent use when Callback must be. For instance,
+	// a restoration tool may walk a backup below "/mnt/backup/2024" and report
+	// pathnames below "/home/user", as they were before being backed up.
+	TransformPath func(osPathname string, de *Dirent) string
+
+	// ExcludeRegexp is an optional regular expression that Walk matches
+	// against the name of every file system node below the root. Nodes whose
+	// names match are skipped without invoking any of the upstream callback
+	// functions, and when the node is a directory, none of its descendants are
+	// visited.
+	ExcludeRegexp *regexp.Regexp
+
+	// IncludeRegexp is an optional regular expression that Walk matches
+	// against the name of every file system node below the root other than
+	// directories, or symbolic links to directories when FollowSymbolicLinks
+	// is true. Nodes whose names do not match are skipped without invoking any
+	// of the upstream callback functions. Directories are always visited so
+	// that matching nodes beneath them may be found, much like find(1) with
+	// its -regex primary. ExcludeRegexp takes precedence over IncludeRegexp.
+	IncludeRegexp *regexp.Regexp
+
+	// ShouldVisit is an optional function that Walk invokes with every file
+	// system node below the root, after the ExcludeRegexp and IncludeRegexp
+	// filters, and before invoking any of the upstream callback functions with
+	// it. The pathname it receives is the one Callback would receive. Nodes
+	// for which it returns false are silently skipped, and when the node is a
+	// directory, none of its descendants are visited, whereas a directory for
+	// which Callback returns filepath.SkipDir has already been visited. This
+	// separates deciding what to visit from what to do with it.
+	ShouldVisit func(osPathname string, de *Dirent) bool
+
+	// CheckTimeMachineExclusions specifies whether Walk skips the descendants
+	// of the root that macOS excludes from Time Machine backups, without
+	// invoking the callback functions for them or recursing on them, so that
+	// backup tools built upon Walk back up what Time Machine would. A node is
+	// excluded when it has the com.apple.metadata:com_apple_backup_excludeItem
+	// extended attribute, as set by `tmutil addexclusion`; exclusions of fixed
+	// pathnames, which are recorded in the Time Machine preferences rather
+	// than on the nodes, are not consulted. On other operating systems this
+	// option has no effect.
+	CheckTimeMachineExclusions bool
+
+	// CwdRelative specifies whether the pathnames provided to Callback and
+	// PostChildrenCallback are expressed relative to the current working
+	// directory of the process at the time Walk is invoked, as command line
+	// tools typically display them, rather than having the argument to Walk
+	// as a prefix. Pathnames that cannot be expressed relative to the working
+	// directory, such as those on a different volume on Windows, are provided
+	// as absolute pathnames. ErrorCallback is still invoked with pathnames
+	// having the argument to Walk as a prefix, and the Path method of each
+	// Dirent is unaffected.
+	CwdRelative bool
+
+	// CallbackQueueSize specifies the capacity of a queue through which Walk
+	// passes file system nodes to Callback, decoupling reading directories
+	// from callbacks that are slow, such as those performing network I/O.
+	// When zero, the default, Walk invokes Callback synchronously for each
+	// node before descending into it. When positive, Walk enqueues each node,
+	// waiting only when the queue is full, and runtime.NumCPU() goroutines
+	// dequeue the nodes and invoke Callback, so Callback, and ErrorCallback
+	// when provided, must be safe for concurrent use, and are invoked in no
+	// particular order. Because Walk may have already descended into a
+	// directory by the time Callback is invoked for it, returning
+	// filepath.SkipDir from Callback has no effect. Callback is provided a
+	// copy of the Dirent of each node, made when it is enqueued, because Walk
+	// continues to update the Dirent of a directory, such as to total the
+	// sizes of its children. PostChildrenCallback is still invoked
+	// synchronously by Walk after it has enqueued the descendants of a
+	// directory, which may be before Callback has been invoked for them. When
+	// an error from Callback halts the walk, Walk stops enqueuing nodes,
+	// waits for the callbacks in progress to return, and returns that error.
+	// In all cases, Walk returns only after every callback it has started has
+	// returned.
+	CallbackQueueSize int
+
+	// firstSeenOrder maps each directory pathname to the index at which each
+	// of its immediate descendants was first enumerated, and is used when
+	// StableUnsorted is true. Because it is retained across walks, it is the
+	// only state Walk stores in the Options structure, and it is only
+	// accessed while holding firstSeenOrderMu.
+	firstSeenOrder map[string]map[string]int
+}
+
+// walker holds a copy of the Options provided to Walk, with the defaults Walk
+// fills in, along with the state of a single walk, so the upstream Options
+// structure is never modified and may be used by concurrent walks.
+type walker struct {
+	Options
+
+	// openDirs is a semaphore, created by Walk when MaxOpenDirs is positive,
+	// that limits the number of concurrently open directory handles.
+	openDirs chan struct{}
+
+	// parallelDirs is a semaphore, created by Walk when ParallelDirs is true,
+	// that limits the number of directories read concurrently.
+	parallelDirs chan struct{}
+
+	// eager is created by Walk when EagerDescend is true.
+	eager *eagerDescent
+
+	// zfs holds the ZFS mount points, loaded by Walk when SkipZFSSnapshots or
+	// IncludeZFSSnapshots is true.
+	zfs *zfsMounts
+
+	// mounts is the mount table, loaded by Walk when SkipNetworkFilesystems is
+	// true.
+	mounts mountTable
+
+	// callbackQueue is created by Walk when CallbackQueueSize is positive.
+	callbackQueue *callbackQueue
+
+	// uring is created by Walk when UseIOURing is true and io_uring is
+	// available.
+	uring *uring
+
+	// checkpoint is created by Walk when CheckpointFile is not empty.
+	checkpoint *checkpoint
+
+	// allocBase is the cumulative number of bytes allocated by the program
+	// when the walk began, obtained by Walk when MaxAllocBytes is positive.
+	allocBase uint64
+
+	// cwd is the current working directory of the process, obtained by Walk
+	// when CwdRelative is true.
+	cwd string
+
+	// root is the cleaned pathname of the root of the walk in progress.
+	root string
 }
 
+// DanglingSymlinkAction defines a set of actions the Walk function could take
+// upon encountering a symbolic link whose referent does not exist. See the
+// documentation for the OnDanglingSymlink field of the Options structure for
+// more information.
+type DanglingSymlinkAction int
+
+const (
+	// DanglingSymlinkDefault reports dangling symbolic links to Callback, and
+	// when FollowSymbolicLinks is set, also handles the error resolving the
+	// referent as described for ErrorCallback.
+	DanglingSymlinkDefault DanglingSymlinkAction = iota
+
+	// DanglingSymlinkSkip silently skips dangling symbolic links without
+	// invoking Callback.
+	DanglingSymlinkSkip
+
+	// DanglingSymlinkReport handles dangling symbolic links as errors, invoking
+	// ErrorCallback with the error resolving the referent rather than
+	// invoking Callback.
+	DanglingSymlinkReport
+
+	// DanglingSymlinkInclude reports dangling symbolic links to Callback as
+	// symbolic link nodes, without treating them as errors, even when
+	// FollowSymbolicLinks is set.
+	DanglingSymlinkInclude
+)
+
 // ErrorAction defines a set of actions the Walk function could take based on
 // the occurrence of an error while walking the file system. See the
 // documentation for the ErrorCallback field of the Options structure for more
@@ -137,7 +838,9 @@ type WalkFunc func(osPathname string, directoryEntry *Dirent) error
 // specified callback function for each file system node in the tree, including
 // root, symbolic links, and other node types. The nodes are walked in lexical
 // order, which makes the output deterministic but means that for very large
-// directories this function can be inefficient.
+// directories this function can be inefficient. When the root is not a
+// directory, the callback function is invoked once for the root, unless the
+// RequireDir option is set, in which case an error is returned.
 //
 // This function is often much faster than filepath.Walk because it does not
 // invoke os.Stat for every node it encounters, but rather obtains the file
@@ -150,6 +853,10 @@ type WalkFunc func(osPathname string, directoryEntry *Dirent) error
 // pathname of the file system node that caused the error. The ErrorCallback
 // function's return value determines the action that Walk will then take.
 //
+// Walk does not modify the provided Options, so the same Options may be
+// provided to concurrent invocations of Walk, so long as its ScratchBuffer is
+// nil and its callback functions are safe for concurrent use.
+//
 //    func main() {
 //        dirname := "."
 //        if len(os.Args) > 1 {
@@ -175,16 +882,30 @@ type WalkFunc func(osPathname string, directoryEntry *Dirent) error
 //        }
 //    }
 func Walk(pathname string, options *Options) error {
-	if options.Callback == nil {
+	if options.Callback == nil && options.CallbackWithShard == nil && options.CallbackWithUserData == nil {
 		return errors.New("cannot walk without a specified Callback function")
 	}
 
+	if options.GlobalSizeOrder {
+		return walkGlobalSizeOrder(pathname, options)
+	}
+
 	pathname = filepath.Clean(pathname)
 
 	var fi os.FileInfo
 	var err error
 
-	if options.FollowSymbolicLinks {
+	var name string // name of the root Dirent, when not its base name
+	if options.HandleUNC {
+		if pathname, name, err = uncRoot(pathname); err != nil {
+			return err
+		}
+	}
+	if name == "" {
+		name = filepath.Base(pathname)
+	}
+
+	if options.FollowSymbolicLinks || options.ResolveRootSymlink {
 		fi, err = os.Stat(pathname)
 		if err != nil {
 			return err
@@ -197,45 +918,691 @@ func Walk(pathname string, options *Options) error {
 	}
 
 	mode := fi.Mode()
-	if mode&os.ModeDir == 0 {
+	if mode&os.ModeDir == 0 && options.RequireDir {
 		return fmt.Errorf("cannot Walk non-directory: %s", pathname)
 	}
 
-	// If ErrorCallback is nil, set to a default value that halts the walk
-	// process on all operating system errors. This is done to allow error
-	// handling to be more succinct in the walk code.
-	if options.ErrorCallback == nil {
-		options.ErrorCallback = defaultErrorCallback
+	// Walk copies the Options, so it may fill in defaults and record the
+	// state of this walk without modifying the upstream structure.
+	w := &walker{Options: copyOptions(options), root: pathname}
+
+	// Always set ErrorCallback to allow error handling to be more succinct in
+	// the walk code. When FailFast is set, also disable DeferSiblingErrors so
+	// the walk halts immediately.
+	w.ErrorCallback = errorCallback(options)
+	if w.FailFast {
+		w.DeferSiblingErrors = false
+	}
+
+	if len(w.ScratchBuffer) < MinimumScratchBufferSize {
+		w.ScratchBuffer = make([]byte, DefaultScratchBufferSize)
+	}
+
+	if w.MaxOpenDirs > 0 {
+		w.openDirs = make(chan struct{}, w.MaxOpenDirs)
+	}
+	if w.ParallelDirs {
+		w.parallelDirs = make(chan struct{}, parallelDirsLimit)
+	}
+	if w.EagerDescend {
+		w.eager = &eagerDescent{workers: make(chan struct{}, runtime.NumCPU())}
+	}
+	if w.SkipNetworkFilesystems {
+		if w.mounts, err = loadMountTable(); err != nil {
+			return err
+		}
+	}
+	if w.SkipZFSSnapshots || w.IncludeZFSSnapshots {
+		if w.SkipZFSSnapshots && w.IncludeZFSSnapshots {
+			return errors.New("cannot walk with both SkipZFSSnapshots and IncludeZFSSnapshots options")
+		}
+		if w.zfs, err = loadZFSMounts(); err != nil {
+			return err
+		}
+	}
+	if w.CheckpointFile != "" {
+		if w.Unsorted || w.StreamingOnly || w.EagerDescend || w.CallbackQueueSize > 0 || w.DirPriority != nil || w.SortByCreationTime {
+			return errors.New("cannot checkpoint walk with Unsorted, StreamingOnly, EagerDescend, CallbackQueueSize, DirPriority, or SortByCreationTime options")
+		}
+		if w.checkpoint, err = loadCheckpoint(w.CheckpointFile, pathname); err != nil {
+			return err
+		}
+	}
+
+	if w.BaselineState != nil {
+		if w.ChangeCallback == nil {
+			return errors.New("cannot walk with BaselineState without a specified ChangeCallback function")
+		}
+		if w.StreamingOnly {
+			return errors.New("cannot walk with both BaselineState and StreamingOnly options")
+		}
 	}
 
-	if len(options.ScratchBuffer) < MinimumScratchBufferSize {
-		options.ScratchBuffer = make([]byte, DefaultScratchBufferSize)
+	w.Stats.reset()
+
+	if w.CwdRelative {
+		if w.cwd, err = os.Getwd(); err != nil {
+			return err
+		}
 	}
 
 	dirent := &Dirent{
 		path:     pathname,
-		name:     filepath.Base(pathname),
+		name:     name,
 		modeType: mode & os.ModeType,
 	}
+	if w.PreloadFileInfo {
+		dirent.fileInfo = fi
+	}
+
+	if w.MaxAllocBytes > 0 {
+		w.allocBase = totalAlloc()
+	}
 
-	err = walk(pathname, dirent, options)
+	if w.CallbackQueueSize > 0 {
+		w.callbackQueue = startCallbackQueue(w)
+	}
+
+	if w.UseIOURing && w.PreloadFileInfo && w.StatMask != 0 {
+		// Walk invokes statx(2) for each child when io_uring is not available.
+		if w.uring, _ = newURing(); w.uring != nil {
+			defer w.uring.close()
+		}
+	}
+
+	if w.RunStartCallback != nil {
+		start := time.Now()
+		w.RunStartCallback(RunMeta{ID: newRunID(start), Start: start, Root: pathname})
+	}
+
+	err = walk(pathname, dirent, w, nil)
+	if de, ok := err.(deferredError); ok {
+		err = de.err
+	}
+	if w.eager != nil {
+		if er := w.eager.halted(); er != nil {
+			err = er // report the error that halted the walk rather than errEagerHalted
+		}
+	}
+
+	if w.callbackQueue != nil {
+		if er := w.callbackQueue.close(); err == nil || err == errCallbackQueueHalted {
+			err = er
+		}
+	}
 	if err == filepath.SkipDir {
-		return nil // silence SkipDir for top level
+		err = nil // silence SkipDir for top level
+	}
+	if w.checkpoint != nil {
+		if er := w.checkpoint.finish(err); err == nil {
+			err = er
+		}
 	}
 	return err
 }
 
+// errorCallback returns the function Walk invokes upon an error given the
+// provided Options: ErrorCallback, or when it is nil, or when FailFast is set
+// so every error halts the walk, defaultErrorCallback.
+func errorCallback(options *Options) func(string, error) ErrorAction {
+	if options.ErrorCallback == nil || options.FailFast {
+		return defaultErrorCallback
+	}
+	return options.ErrorCallback
+}
+
 // defaultErrorCallback always returns Halt because if the upstream code did not
 // provide an ErrorCallback function, walking the file system hierarchy ought to
 // halt upon any operating system error.
 func defaultErrorCallback(_ string, _ error) ErrorAction { return Halt }
 
+// invokeCallback writes the pathname to the PathSink when one is provided, then
+// either enqueues the file system node when CallbackQueueSize is positive, or
+// invokes the upstream Callback function for it.
+func invokeCallback(osPathname string, dirent *Dirent, options *walker) error {
+	if options.PathSink != nil {
+		if _, err := io.WriteString(options.PathSink, dirent.path+"\n"); err != nil {
+			return err
+		}
+	}
+	if options.callbackQueue != nil {
+		return options.callbackQueue.enqueue(osPathname, dirent)
+	}
+	return callCallback(osPathname, dirent, options)
+}
+
+// callCallback invokes the upstream Callback function for the file system
+// node. When SyncAfterCallback is set, it then syncs and closes any files the
+// callback opened using the OpenForWrite method of the Dirent, and when
+// AssertReadOnly is set, it returns ErrModifiedByCallback when the node was
+// modified in the meantime.
+func callCallback(osPathname string, dirent *Dirent, options *walker) (err error) {
+	if options.AssertReadOnly {
+		if before, er := os.Lstat(osPathname); er == nil {
+			defer func(osPathname string) {
+				if err != nil && err != filepath.SkipDir {
+					return // report the error returned by Callback
+				}
+				after, er := os.Lstat(osPathname)
+				if os.IsNotExist(er) || er == nil && (!after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size()) {
+					err = wrapPath(ErrModifiedByCallback, osPathname)
+				}
+			}(osPathname)
+		}
+	}
+
+	osPathname = reportedPathname(osPathname, dirent, options)
+	if !options.SyncAfterCallback {
+		return callUpstream(osPathname, dirent, &options.Options)
+	}
+
+	dirent.syncOpened = true
+	err = callUpstream(osPathname, dirent, &options.Options)
+	opened := dirent.opened
+	dirent.syncOpened, dirent.opened = false, nil
+
+	for _, fh := range opened {
+		if er := fh.Sync(); err == nil {
+			err = er
+		}
+		if er := fh.Close(); err == nil {
+			err = er
+		}
+	}
+	return err
+}
+
+// callUpstream invokes Callback, or when it is nil, CallbackWithShard, or when
+// that is also nil, CallbackWithUserData.
+func callUpstream(osPathname string, dirent *Dirent, options *Options) error {
+	if options.Callback != nil {
+		return options.Callback(osPathname, dirent)
+	}
+	if options.CallbackWithShard == nil {
+		return options.CallbackWithUserData(osPathname, dirent, options.UserData)
+	}
+	return options.CallbackWithShard(pathShard(osPathname, options.ShardCount), osPathname, dirent)
+}
+
+// pathShard returns the 64-bit FNV-1a hash of the slash-separated form of the
+// pathname, modulo count when count is positive.
+func pathShard(osPathname string, count uint64) uint64 {
+	h := fnv.New64a()
+	_, _ = io.WriteString(h, filepath.ToSlash(osPathname)) // hash.Hash never returns an error
+	if count == 0 {
+		return h.Sum64()
+	}
+	return h.Sum64() % count
+}
+
+// ErrDirTooLarge is provided to ErrorCallback when a directory has more entries
+// than permitted by the MaxEntriesPerDir option.
+var ErrDirTooLarge = errors.New("directory has too many entries")
+
+// ErrSubvolumeBoundary is provided to ErrorCallback when the SkipSubvolumes
+// option is set and a directory below the root is the root of a btrfs
+// subvolume.
+var ErrSubvolumeBoundary = errors.New("directory is a subvolume boundary")
+
+// ErrModifiedByCallback is handled as though it were returned by Callback when
+// the AssertReadOnly option is set and Callback modified the node it was
+// invoked with.
+var ErrModifiedByCallback = errors.New("node modified by callback")
+
+// FollowThis may be returned by the callback function for a symbolic link to
+// have Walk recurse on the directory it refers to, as it would were the
+// FollowSymbolicLinks option set. It is ignored when returned for any other
+// node, or when the CallbackQueueSize option is positive, because Walk does not
+// wait for queued callbacks to return.
+var FollowThis = errors.New("follow this symbolic link")
+
+// ErrDirectoryEncrypted is provided to ErrorCallback when the
+// SkipLockedEncryptedDirs option is set and a directory is encrypted with a key
+// that is not available.
+var ErrDirectoryEncrypted = errors.New("directory is encrypted and locked")
+
+// ErrAllocLimit is returned by Walk, wrapped with the pathname of the directory
+// it was about to read, when the memory allocated while walking exceeds the
+// MaxAllocBytes option.
+var ErrAllocLimit = errors.New("cannot walk: allocation limit exceeded")
+
+// readDirents is the function used by Walk to read the immediate descendants of
+// a directory. It is a variable so tests may observe when directory handles are
+// opened and closed.
+var readDirents = ReadDirents
+
+// parallelDirsLimit is the number of directories Walk reads concurrently when
+// ParallelDirs is set. It is a variable so tests may read more directories
+// concurrently than there are CPUs.
+var parallelDirsLimit = runtime.NumCPU()
+
+// staleRetries and staleRetryDelay are the number of times readChildren retries
+// reading a directory that returned ESTALE when RetryOnStale is set, and how
+// long it waits before each retry. The latter is a variable so tests need not
+// wait.
+const staleRetries = 3
+
+var staleRetryDelay = 100 * time.Millisecond
+
+// readChildren reads the immediate descendants of the specified directory,
+// waiting as necessary so no more than MaxOpenDirs directory handles are open
+// at once, and retrying when the directory is stale and RetryOnStale is set.
+func readChildren(osDirname string, scratchBuffer []byte, options *walker) (Dirents, error) {
+	children, err := readChildrenOnce(osDirname, scratchBuffer, options)
+	for retry := 0; retry < staleRetries && options.RetryOnStale && isStale(err); retry++ {
+		time.Sleep(staleRetryDelay)
+		children, err = readChildrenOnce(osDirname, scratchBuffer, options)
+	}
+	return children, err
+}
+
+func readChildrenOnce(osDirname string, scratchBuffer []byte, options *walker) (Dirents, error) {
+	if options.openDirs != nil {
+		options.openDirs <- struct{}{}
+		defer func() { <-options.openDirs }()
+	}
+	if options.BypassAttrCache || options.MaxEntriesPerDir > 0 || options.SequentialHint || options.EntryDecoder != nil {
+		return readdirents(osDirname, scratchBuffer, readdirOptions{
+			bypassCache:    options.BypassAttrCache,
+			maxEntries:     options.MaxEntriesPerDir,
+			sequentialHint: options.SequentialHint,
+			decode:         options.EntryDecoder,
+		})
+	}
+	return readDirents(osDirname, scratchBuffer)
+}
+
+// reportedPathname returns the pathname to provide to upstream callback
+// functions for the file system node, which differs from the pathname used to
+// access the node when NameTransform or TransformPath is provided or
+// CwdRelative is true.
+func reportedPathname(osPathname string, dirent *Dirent, options *walker) string {
+	if options.TransformPath != nil {
+		return options.TransformPath(osPathname, dirent)
+	}
+	if dirent.osPath != "" {
+		osPathname = dirent.path
+	}
+	if options.cwd == "" {
+		return osPathname
+	}
+	abs := osPathname
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(options.cwd, abs)
+	}
+	if rel, err := filepath.Rel(options.cwd, abs); err == nil {
+		return rel
+	}
+	return abs
+}
+
+// pendingChildren holds the result of reading the immediate descendants of a
+// directory in a separate goroutine. The done channel is closed once the
+// children and err fields are populated.
+type pendingChildren struct {
+	osDirname string
+	done      chan struct{}
+	children  Dirents
+	err       error
+}
+
+// scratchBufferPool provides scratch buffers to goroutines that read
+// directories concurrently, and to directories streamed while their
+// descendants are walked, because they cannot share the scratch buffer from the
+// Options structure.
+var scratchBufferPool = sync.Pool{
+	New: func() interface{} { return make([]byte, DefaultScratchBufferSize) },
+}
+
+// prefetchChildren starts reading the immediate descendants of each directory
+// in deChildren, returning a slice whose elements correspond to the elements of
+// deChildren, and are nil for children that are not directories. Directories
+// are read in the order they appear in deChildren, so the first directory Walk
+// will visit is the first one read. Closing the returned stop channel prevents
+// any more directory reads from starting, and the returned WaitGroup completes
+// once all started reads have finished.
+func prefetchChildren(osDirname string, deChildren Dirents, options *walker) ([]*pendingChildren, chan struct{}, *sync.WaitGroup) {
+	pending := make([]*pendingChildren, len(deChildren))
+	for i, deChild := range deChildren {
+		if !deChild.IsDir() {
+			continue
+		}
+		osChildname := filepath.Join(osDirname, deChild.name)
+		if !isOnSkippedFilesystem(osChildname, options) {
+			pending[i] = &pendingChildren{osDirname: osChildname, done: make(chan struct{})}
+		}
+	}
+
+	stop := make(chan struct{})
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		for _, p := range pending {
+			if p == nil {
+				continue
+			}
+			select {
+			case <-stop:
+				return
+			case options.parallelDirs <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(p *pendingChildren) {
+				defer wg.Done()
+				scratchBuffer := scratchBufferPool.Get().([]byte)
+				p.children, p.err = readChildren(p.osDirname, scratchBuffer, options)
+				scratchBufferPool.Put(scratchBuffer)
+				<-options.parallelDirs
+				close(p.done)
+			}(p)
+		}
+	}()
+
+	return pending, stop, wg
+}
+
+// lstat returns the os.FileInfo for the specified pathname without following
+// symbolic links, only requesting the fields specified by StatMask when it is
+// provided.
+func lstat(osPathname string, options *walker) (os.FileInfo, error) {
+	if options.StatMask != 0 {
+		return lstatMask(osPathname, options.StatMask)
+	}
+	return os.Lstat(osPathname)
+}
+
+// firstSeenOrderMu guards the firstSeenOrder field of every Options structure,
+// which concurrent walks using the same Options may share.
+var firstSeenOrderMu sync.Mutex
+
+// copyOptions returns a copy of the provided Options, first creating the
+// firstSeenOrder map it shares with the copy when StableUnsorted is in effect.
+func copyOptions(options *Options) Options {
+	firstSeenOrderMu.Lock()
+	defer firstSeenOrderMu.Unlock()
+	if options.Unsorted && options.StableUnsorted && options.firstSeenOrder == nil {
+		options.firstSeenOrder = make(map[string]map[string]int)
+	}
+	return *options
+}
+
+// replayFirstSeenOrder returns the immediate descendants of the specified
+// directory in the order they were enumerated the first time the directory was
+// visited, followed by any descendants not seen before, in the order provided.
+// It records the order of any newly seen descendants for subsequent walks.
+func replayFirstSeenOrder(osDirname string, deChildren Dirents, options *walker) Dirents {
+	firstSeenOrderMu.Lock()
+	defer firstSeenOrderMu.Unlock()
+
+	seen, ok := options.firstSeenOrder[osDirname]
+	if !ok {
+		seen = make(map[string]int, len(deChildren))
+		options.firstSeenOrder[osDirname] = seen
+	}
+
+	// Place each previously seen descendant in the slot for its recorded
+	// index, and append newly seen descendants afterwards.
+	slots := make(Dirents, len(seen))
+	var unseen Dirents
+	for _, deChild := range deChildren {
+		if i, ok := seen[deChild.name]; ok {
+			slots[i] = deChild
+			continue
+		}
+		unseen = append(unseen, deChild)
+	}
+
+	ordered := deChildren[:0]
+	for _, deChild := range slots {
+		if deChild != nil {
+			ordered = append(ordered, deChild) // nil when descendant has since been removed
+		}
+	}
+	for _, deChild := range unseen {
+		seen[deChild.name] = len(seen)
+		ordered = append(ordered, deChild)
+	}
+	return ordered
+}
+
+// sortByDirPriority stably sorts the children in descending order of the
+// priority returned by the DirPriority function for each child directory,
+// treating non-directory children as having a priority of zero.
+func sortByDirPriority(children Dirents, dirPriority func(string, *Dirent) int) {
+	priorities := make([]int, len(children))
+	for i, de := range children {
+		if de.IsDir() {
+			priorities[i] = dirPriority(de.path, de)
+		}
+	}
+	sort.Stable(&byPriority{children: children, priorities: priorities})
+}
+
+// byPriority sorts children in descending order of their priorities, which are
+// swapped along with them.
+type byPriority struct {
+	children   Dirents
+	priorities []int
+}
+
+func (p *byPriority) Len() int { return len(p.children) }
+
+func (p *byPriority) Less(i, j int) bool { return p.priorities[i] > p.priorities[j] }
+
+func (p *byPriority) Swap(i, j int) {
+	p.children[i], p.children[j] = p.children[j], p.children[i]
+	p.priorities[i], p.priorities[j] = p.priorities[j], p.priorities[i]
+}
+
+// deferredError wraps an error whose handling DeferSiblingErrors postponed
+// until the siblings of the node that produced it were visited, so the
+// ancestors of its parent return it immediately rather than deferring it again.
+type deferredError struct{ err error }
+
+func (e deferredError) Error() string { return e.err.Error() }
+
+// haltsParent returns true when an error returned by walk for a child halts the
+// walk of its parent, rather than being deferred until the remaining siblings
+// have been walked.
+func haltsParent(err error, options *walker) bool {
+	if err == nil || err == filepath.SkipDir {
+		return false
+	}
+	if _, ok := err.(deferredError); ok {
+		return true
+	}
+	return !options.DeferSiblingErrors || err == errCallbackQueueHalted || err == errEagerHalted || errors.Is(err, ErrAllocLimit)
+}
+
+// firstError returns deferred when not nil, otherwise err.
+func firstError(deferred, err error) error {
+	if deferred != nil {
+		return deferred
+	}
+	return err
+}
+
+// isExcluded returns true when the name of the specified child does not pass
+// the ExcludeRegexp and IncludeRegexp filters of the provided Options.
+func isExcluded(osChildname string, deChild *Dirent, options *walker) (bool, error) {
+	if options.ExcludeRegexp != nil && options.ExcludeRegexp.MatchString(deChild.name) {
+		return true, nil
+	}
+	if options.IncludeRegexp == nil || options.IncludeRegexp.MatchString(deChild.name) || deChild.IsDir() {
+		return false, nil
+	}
+	if deChild.IsSymlink() && options.FollowSymbolicLinks {
+		options.Stats.symlinkFollowed()
+		isDir, err := isSymlinkToDirectory(deChild, osChildname)
+		if os.IsNotExist(err) {
+			return true, nil // dangling symbolic links do not refer to directories
+		}
+		return !isDir, err
+	}
+	return true, nil
+}
+
+// totalAlloc returns the cumulative number of bytes allocated by the program.
+func totalAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.TotalAlloc
+}
+
+// isOnSkippedFilesystem returns true if and only if the specified directory
+// resides on a network file system and the upstream code requested that such
+// directories not be walked.
+func isOnSkippedFilesystem(osDirname string, options *walker) bool {
+	if options.mounts == nil {
+		return false
+	}
+	osAbsname, err := filepath.Abs(osDirname)
+	if err != nil {
+		return false
+	}
+	return options.mounts.isNetwork(osAbsname)
+}
+
+// isBoundary returns true if and only if the specified directory is not the
+// root and contains any of the boundary markers the upstream code specified.
+func isBoundary(osDirname string, options *walker) bool {
+	if len(options.BoundaryMarkers) == 0 || osDirname == options.root {
+		return false
+	}
+	for _, marker := range options.BoundaryMarkers {
+		if _, err := os.Lstat(filepath.Join(osDirname, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// walkStreams invokes Callback for each alternate data stream of the regular
+// file specified by pathname and Dirent.
+func walkStreams(osPathname string, dirent *Dirent, options *walker) error {
+	names, err := alternateDataStreams(osPathname)
+	if err != nil {
+		err = options.nodeError(dirent, err)
+		if action := options.ErrorCallback(osPathname, err); action == SkipNode {
+			return nil
+		}
+		return wrapPath(err, osPathname)
+	}
+	for _, name := range names {
+		osStreamname := osPathname + ":" + name
+		stream := &Dirent{
+			path:    dirent.path + ":" + name,
+			name:    dirent.name + ":" + name,
+			ads:     true,
+			noReuse: dirent.noReuse,
+			noATime: dirent.noATime,
+		}
+		if dirent.osPath != "" {
+			stream.osPath = osStreamname
+		}
+		if err = invokeCallback(osStreamname, stream, options); err == nil {
+			continue
+		}
+		if err == filepath.SkipDir || err == errCallbackQueueHalted {
+			return err
+		}
+		if action := options.ErrorCallback(osStreamname, err); action != SkipNode {
+			return err
+		}
+	}
+	return nil
+}
+
 // walk recursively traverses the file system node specified by pathname and the
-// Dirent.
-func walk(osPathname string, dirent *Dirent, options *Options) error {
-	err := options.Callback(osPathname, dirent)
+// Dirent. When pending is not nil, the immediate descendants of the node have
+// already been requested in a separate goroutine.
+func walk(osPathname string, dirent *Dirent, options *walker, pending *pendingChildren) (err error) {
+	if options.eager != nil && options.eager.halted() != nil {
+		return errEagerHalted
+	}
+
+	var resumed bool // whether Callback was invoked for the node by a previous walk
+	if cp := options.checkpoint; cp != nil && cp.resume != nil {
+		switch cp.position(osPathname) {
+		case checkpointBefore:
+			return nil
+		case checkpointAncestor:
+			resumed = true
+		}
+	}
+
+	if options.AccumulateSubtreeSizes && !dirent.IsDir() {
+		fi := dirent.fileInfo
+		if fi == nil {
+			var err error
+			if fi, err = os.Lstat(osPathname); err != nil {
+				err = options.nodeError(dirent, err)
+				if action := options.ErrorCallback(osPathname, err); action == SkipNode {
+					return nil
+				}
+				return wrapPath(err, osPathname)
+			}
+		}
+		dirent.subtreeSize = fi.Size()
+	}
+
+	var dangling bool
+	if dirent.IsSymlink() && options.OnDanglingSymlink != DanglingSymlinkDefault {
+		options.Stats.symlinkFollowed()
+		if _, err := os.Stat(osPathname); os.IsNotExist(err) {
+			switch options.OnDanglingSymlink {
+			case DanglingSymlinkSkip:
+				return nil
+			case DanglingSymlinkReport:
+				err = options.nodeError(dirent, err)
+				if action := options.ErrorCallback(osPathname, err); action == SkipNode {
+					return nil
+				}
+				return wrapPath(err, osPathname)
+			}
+			dangling = true // report the symbolic link, but do not attempt to follow it
+		}
+	}
+
+	if dirent.IsSymlink() && options.AnnotateSymlinkTargets && !dangling {
+		// Any error resolving the link leaves its target type unknown.
+		options.Stats.symlinkFollowed()
+		if fi, err := os.Stat(osPathname); err == nil {
+			dirent.targetType, dirent.hasTargetType = fi.Mode()&os.ModeType, true
+		}
+	}
+
+	dirent.root = options.root
+	dirent.noReuse = options.NoReuseHint
+	dirent.noATime = options.NoATime
+
+	if dirent.IsSymlink() && options.FollowSymbolicLinks && !dangling {
+		// Any error resolving the link is reported when it is followed below.
+		options.Stats.evalSymlinksCalled()
+		dirent.realPath, _ = filepath.EvalSymlinks(osPathname)
+	}
+
+	if dirent.IsSymlink() && options.CountSymlinkHops {
+		// A chain that cannot be followed to its end has the hops followed
+		// until then.
+		chain, _, _ := symlinkChain(osPathname)
+		dirent.symlinkHops = len(chain)
+	}
+
+	if !resumed && options.BaselineState != nil {
+		err = reportChange(osPathname, dirent, options)
+	}
+	if !resumed && err == nil {
+		err = invokeCallback(osPathname, dirent, options)
+	}
+	var follow bool // whether the callback requested following this symbolic link
+	if err == FollowThis {
+		follow, err = dirent.IsSymlink(), nil
+	}
 	if err != nil {
-		if err == filepath.SkipDir {
+		if err == filepath.SkipDir || err == errCallbackQueueHalted {
 			return err
 		}
 		if action := options.ErrorCallback(osPathname, err); action == SkipNode {
@@ -243,17 +1610,28 @@ func walk(osPathname string, dirent *Dirent, options *Options) error {
 		}
 		return err
 	}
+	if options.checkpoint != nil && !resumed {
+		if err = options.checkpoint.completed(osPathname); err != nil {
+			return wrapPath(err, osPathname)
+		}
+	}
+
+	if options.IncludeADS && dirent.IsRegular() {
+		return walkStreams(osPathname, dirent, options)
+	}
 
 	if dirent.IsSymlink() {
-		if !options.FollowSymbolicLinks {
+		if !options.FollowSymbolicLinks && !follow || dangling {
 			return nil
 		}
+		options.Stats.symlinkFollowed()
 		isDir, err := isSymlinkToDirectory(dirent, osPathname)
 		if err != nil {
+			err = options.nodeError(dirent, err)
 			if action := options.ErrorCallback(osPathname, err); action == SkipNode {
 				return nil
 			}
-			return err
+			return wrapPath(err, osPathname)
 		}
 		if !isDir {
 			return nil
@@ -264,37 +1642,240 @@ func walk(osPathname string, dirent *Dirent, options *Options) error {
 
 	// If get here, then specified pathname refers to a directory or a
 	// symbolic link to a directory.
-	deChildren, err := ReadDirents(osPathname, options.ScratchBuffer)
+
+	if isOnSkippedFilesystem(osPathname, options) {
+		return postChildren(osPathname, dirent, options)
+	}
+	if isBoundary(osPathname, options) {
+		return postChildren(osPathname, dirent, options)
+	}
+	if options.SkipZFSSnapshots && options.zfs.isSnapshot(osPathname) {
+		return postChildren(osPathname, dirent, options)
+	}
+	if options.SkipSubvolumes && osPathname != options.root && isSubvolume(osPathname) {
+		if action := options.ErrorCallback(osPathname, ErrSubvolumeBoundary); action == SkipNode {
+			return postChildren(osPathname, dirent, options)
+		}
+		return wrapPath(ErrSubvolumeBoundary, osPathname)
+	}
+	if options.SkipLockedEncryptedDirs && isLockedEncrypted(osPathname) {
+		if action := options.ErrorCallback(osPathname, ErrDirectoryEncrypted); action == SkipNode {
+			return postChildren(osPathname, dirent, options)
+		}
+		return wrapPath(ErrDirectoryEncrypted, osPathname)
+	}
+	if options.MaxAllocBytes > 0 && totalAlloc()-options.allocBase > uint64(options.MaxAllocBytes) {
+		return wrapPath(ErrAllocLimit, osPathname)
+	}
+	var deChildren Dirents
+	var scanner *Scanner
+	if options.StreamingOnly {
+		// Each directory being streamed needs its own scratch buffer, because
+		// its entries are read while its descendants are walked.
+		scratchBuffer := scratchBufferPool.Get().([]byte)
+		defer scratchBufferPool.Put(scratchBuffer)
+		if scanner, err = NewScannerWithScratchBuffer(osPathname, scratchBuffer); err == nil {
+			defer scanner.Close()
+		}
+	} else if pending != nil {
+		<-pending.done
+		deChildren, err = pending.children, pending.err
+	} else {
+		scratchBuffer := options.ScratchBuffer
+		if options.eager != nil {
+			// Concurrent walks cannot share the scratch buffer.
+			scratchBuffer = scratchBufferPool.Get().([]byte)
+			defer scratchBufferPool.Put(scratchBuffer)
+		}
+		deChildren, err = readChildren(osPathname, scratchBuffer, options)
+	}
+	var partial bool // whether only some of the children were read
+	if err == ErrDirTooLarge {
+		if action := options.ErrorCallback(osPathname, err); action != SkipNode {
+			return wrapPath(err, osPathname)
+		}
+		err, partial = nil, true // continue with the entries that were read
+	}
 	if err != nil {
+		if options.SilentPermissionErrors && os.IsPermission(err) {
+			return postChildren(osPathname, dirent, options)
+		}
+		err = options.nodeError(dirent, err)
 		if action := options.ErrorCallback(osPathname, err); action == SkipNode {
 			return nil
 		}
-		return err
+		return wrapPath(err, osPathname)
+	}
+
+	if scanner == nil {
+		if options.IncludeZFSSnapshots {
+			deChildren = options.zfs.includeControlDir(osPathname, deChildren)
+		}
+		if !options.Unsorted {
+			sort.Sort(deChildren) // sort children entries unless upstream says to leave unsorted
+		} else if options.StableUnsorted && options.eager == nil {
+			deChildren = replayFirstSeenOrder(osPathname, deChildren, options)
+		}
+		if options.SortByCreationTime {
+			sortByCreationTime(osPathname, deChildren)
+		}
+		if options.DirPriority != nil {
+			sortByDirPriority(deChildren, options.DirPriority)
+		}
+	}
+
+	if options.BaselineState != nil && !resumed && !partial {
+		if err = reportRemoved(osPathname, dirent, deChildren, options); err != nil {
+			if action := options.ErrorCallback(osPathname, err); action != SkipNode {
+				return err
+			}
+			err = nil
+		}
 	}
 
-	if !options.Unsorted {
-		sort.Sort(deChildren) // sort children entries unless upstream says to leave unsorted
+	var pendingGrandchildren []*pendingChildren
+	if options.ParallelDirs && scanner == nil {
+		var stop chan struct{}
+		var wg *sync.WaitGroup
+		pendingGrandchildren, stop, wg = prefetchChildren(osPathname, deChildren, options)
+		defer func() {
+			close(stop)
+			wg.Wait() // do not return while reads are still in flight
+		}()
 	}
 
-	for _, deChild := range deChildren {
-		osChildname := filepath.Join(osPathname, deChild.name)
-		err = walk(osChildname, deChild, options)
+	var deferred error // first error whose handling awaits remaining siblings
+
+	if options.PreloadFileInfo && options.uring != nil && scanner == nil {
+		preloadWithURing(osPathname, deChildren, options)
+	}
+
+	var eagerChildren []*eagerChild
+	var eagerWG sync.WaitGroup
+	if options.eager != nil {
+		defer func() {
+			// Upon returning early, do not leave walks of children in
+			// progress, but stop them from descending any further.
+			if haltsParent(err, options) {
+				options.eager.halt(err)
+			}
+			eagerWG.Wait()
+		}()
+	}
+
+	for i := 0; ; i++ {
+		var deChild *Dirent
+		if scanner != nil {
+			if !scanner.Scan() {
+				if err = scanner.Err(); err != nil {
+					err = options.nodeError(dirent, err)
+					if action := options.ErrorCallback(osPathname, err); action != SkipNode {
+						return wrapPath(err, osPathname)
+					}
+				}
+				break
+			}
+			deChild = scanner.Dirent()
+		} else if i < len(deChildren) {
+			deChild = deChildren[i]
+		} else {
+			break
+		}
+		osChildname := joinPathname(osPathname, deChild.name)
+		if options.SkipZFSSnapshots && deChild.name == zfsControlDir && deChild.IsDir() {
+			continue
+		}
+		if options.NameTransform != nil {
+			deChild.osPath = osChildname
+			deChild.name = options.NameTransform(deChild.name)
+			deChild.path = filepath.Join(dirent.path, deChild.name)
+		}
+		if options.ExcludeRegexp != nil || options.IncludeRegexp != nil {
+			excluded, err := isExcluded(osChildname, deChild, options)
+			if err != nil {
+				err = options.nodeError(deChild, err)
+				if action := options.ErrorCallback(osChildname, err); action == SkipNode {
+					continue // ignore and continue with next sibling
+				}
+				if options.DeferSiblingErrors {
+					deferred = firstError(deferred, wrapPath(err, osChildname))
+					continue
+				}
+				return wrapPath(err, osChildname)
+			}
+			if excluded {
+				continue
+			}
+		}
+		if options.CheckTimeMachineExclusions && isTimeMachineExcluded(osChildname) {
+			continue
+		}
+		if options.ShouldVisit != nil && !options.ShouldVisit(reportedPathname(osChildname, deChild, options), deChild) {
+			continue
+		}
+		if options.PreloadFileInfo && deChild.fileInfo == nil {
+			if deChild.fileInfo, err = lstat(osChildname, options); err != nil {
+				err = options.nodeError(deChild, err)
+				if action := options.ErrorCallback(osChildname, err); action == SkipNode {
+					continue // ignore and continue with next sibling
+				}
+				if options.DeferSiblingErrors {
+					deferred = firstError(deferred, wrapPath(err, osChildname))
+					continue
+				}
+				return wrapPath(err, osChildname)
+			}
+		}
+		var p *pendingChildren
+		if pendingGrandchildren != nil {
+			p = pendingGrandchildren[i]
+		}
+		if options.eager != nil && deChild.IsDir() {
+			select {
+			case options.eager.workers <- struct{}{}:
+				ec := &eagerChild{osPathname: osChildname, dirent: deChild}
+				eagerChildren = append(eagerChildren, ec)
+				eagerWG.Add(1)
+				go func() {
+					defer eagerWG.Done()
+					ec.err = walk(ec.osPathname, ec.dirent, options, p)
+					<-options.eager.workers
+				}()
+				continue
+			default:
+				// Every worker is busy, so walk the child in this goroutine.
+			}
+		}
+		err = walk(osChildname, deChild, options, p)
+		dirent.subtreeSize += deChild.subtreeSize
 		if err == nil {
 			continue
 		}
 		if err != filepath.SkipDir {
-			return err
+			if haltsParent(err, options) {
+				return err
+			}
+			deferred = firstError(deferred, err)
+			continue
 		}
 		// When received SkipDir on a directory or a symbolic link to a
 		// directory, stop processing that directory but continue processing
 		// siblings.  When received on a non-directory, stop processing
 		// remaining siblings.
+		if deChild.IsSymlink() {
+			options.Stats.symlinkFollowed()
+		}
 		isDir, err := isDirectoryOrSymlinkToDirectory(deChild, osChildname)
 		if err != nil {
+			err = options.nodeError(deChild, err)
 			if action := options.ErrorCallback(osChildname, err); action == SkipNode {
 				continue // ignore and continue with next sibling
 			}
-			return err // caller does not approve of this error
+			if options.DeferSiblingErrors {
+				deferred = firstError(deferred, wrapPath(err, osChildname))
+				continue
+			}
+			return wrapPath(err, osChildname) // caller does not approve of this error
 		}
 		if !isDir {
 			break // stop processing remaining siblings, but allow post children callback
@@ -302,11 +1883,36 @@ func walk(osPathname string, dirent *Dirent, options *Options) error {
 		// continue processing remaining siblings
 	}
 
+	if eagerChildren != nil {
+		eagerWG.Wait()
+		for _, ec := range eagerChildren {
+			dirent.subtreeSize += ec.dirent.subtreeSize
+			// SkipDir from a directory skips only that directory.
+			if ec.err == nil || ec.err == filepath.SkipDir {
+				continue
+			}
+			if haltsParent(ec.err, options) {
+				return ec.err
+			}
+			deferred = firstError(deferred, ec.err)
+		}
+	}
+
+	if deferred != nil {
+		return deferredError{deferred}
+	}
+
+	return postChildren(osPathname, dirent, options)
+}
+
+// postChildren invokes PostChildrenCallback, when provided, for a directory for
+// which Callback was invoked, after its children were walked or it was pruned.
+func postChildren(osPathname string, dirent *Dirent, options *walker) error {
 	if options.PostChildrenCallback == nil {
 		return nil
 	}
 
-	err = options.PostChildrenCallback(osPathname, dirent)
+	err := options.PostChildrenCallback(reportedPathname(osPathname, dirent, options), dirent)
 	if err == nil || err == filepath.SkipDir {
 		return err
 	}
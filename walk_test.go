@@ -1,9 +1,24 @@
 package godirwalk
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func filepathWalk(tb testing.TB, osDirname string) []string {
@@ -248,6 +263,1870 @@ func TestPostChildrenCallback(t *testing.T) {
 	ensureStringSlicesMatch(t, actual, expected)
 }
 
+func TestWalkPathSink(t *testing.T) {
+	var sink bytes.Buffer
+	var actual []string
+
+	err := Walk(filepath.Join(testRoot, "d0/d1"), &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, _ *Dirent) error {
+			// Ensure pathname is written to sink prior to Callback invocation.
+			if !strings.HasSuffix(sink.String(), osPathname+"\n") {
+				t.Errorf("GOT: %q; WANT: suffix %q", sink.String(), osPathname+"\n")
+			}
+			actual = append(actual, osPathname)
+			return nil
+		},
+		PathSink: &sink,
+	})
+
+	ensureError(t, err)
+
+	expected := []string{
+		filepath.Join(testRoot, "d0/d1"),
+		filepath.Join(testRoot, "d0/d1/f2"),
+	}
+
+	ensureStringSlicesMatch(t, actual, expected)
+	ensureStringSlicesMatch(t, strings.Split(strings.TrimSuffix(sink.String(), "\n"), "\n"), expected)
+}
+
+func TestWalkMaxOpenDirs(t *testing.T) {
+	var entries []string
+	for i := 0; i < 32; i++ {
+		entries = append(entries, fmt.Sprintf("d%02d/e/f", i))
+	}
+	root, cleanup := setupTree(t, entries...)
+	defer cleanup()
+
+	defer func(original int) { parallelDirsLimit = original }(parallelDirsLimit)
+	parallelDirsLimit = 8
+
+	// maxOpen walks the tree reading directories in parallel, and returns the
+	// greatest number of directories that were being read at once.
+	maxOpen := func(maxOpenDirs int) int32 {
+		var open, maxOpen int32
+
+		defer func(original func(string, []byte) (Dirents, error)) { readDirents = original }(readDirents)
+		readDirents = func(osDirname string, scratchBuffer []byte) (Dirents, error) {
+			n := atomic.AddInt32(&open, 1)
+			defer atomic.AddInt32(&open, -1)
+			for {
+				m := atomic.LoadInt32(&maxOpen)
+				if n <= m || atomic.CompareAndSwapInt32(&maxOpen, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond) // give other reads time to overlap this one
+			return ReadDirents(osDirname, scratchBuffer)
+		}
+
+		var count int
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(_ string, _ *Dirent) error {
+				count++
+				return nil
+			},
+			MaxOpenDirs:  maxOpenDirs,
+			ParallelDirs: true,
+		})
+
+		ensureError(t, err)
+
+		if got, want := count, 1+3*len(entries); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		return atomic.LoadInt32(&maxOpen)
+	}
+
+	const maxOpenDirs = 2
+
+	if got, want := maxOpen(0), int32(maxOpenDirs); got <= want {
+		t.Fatalf("GOT: %v; WANT: > %v", got, want)
+	}
+	if got, want := maxOpen(maxOpenDirs), int32(maxOpenDirs); got > want {
+		t.Errorf("GOT: %v; WANT: <= %v", got, want)
+	}
+}
+
+func TestWalkParallelDirs(t *testing.T) {
+	walkOrder := func(parallelDirs bool) []string {
+		var actual []string
+		err := Walk(filepath.Join(testRoot, "d0"), &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, _ *Dirent) error {
+				actual = append(actual, osPathname)
+				return nil
+			},
+			PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+				actual = append(actual, "post "+osPathname)
+				return nil
+			},
+			FollowSymbolicLinks: true,
+			ErrorCallback:       func(_ string, _ error) ErrorAction { return SkipNode },
+			ParallelDirs:        parallelDirs,
+		})
+		ensureError(t, err)
+		return actual
+	}
+
+	expected := walkOrder(false)
+	actual := walkOrder(true)
+
+	if got, want := len(actual), len(expected); got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i := range expected {
+		if got, want := actual[i], expected[i]; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+}
+
+func TestWalkPreloadFileInfo(t *testing.T) {
+	t.Run("preload", func(t *testing.T) {
+		var count int
+		err := Walk(filepath.Join(testRoot, "d0"), &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, de *Dirent) error {
+				count++
+				fi := de.CachedFileInfo()
+				if fi == nil {
+					t.Fatalf("%s: GOT: nil; WANT: os.FileInfo", osPathname)
+				}
+				if got, want := fi.Name(), de.Name(); got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+				if got, want := fi.Mode()&os.ModeType, de.ModeType(); got != want {
+					t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+				}
+				return nil
+			},
+			PreloadFileInfo: true,
+		})
+		ensureError(t, err)
+		if count == 0 {
+			t.Errorf("GOT: %v; WANT: > 0", count)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		err := Walk(filepath.Join(testRoot, "d0"), &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, de *Dirent) error {
+				if fi := de.CachedFileInfo(); fi != nil {
+					t.Errorf("%s: GOT: %v; WANT: nil", osPathname, fi)
+				}
+				return nil
+			},
+		})
+		ensureError(t, err)
+	})
+}
+
+func TestWalkStableUnsorted(t *testing.T) {
+	// Simulate an operating system that enumerates directory entries in a
+	// different order every time a directory is read.
+	var reads int
+	defer func(original func(string, []byte) (Dirents, error)) { readDirents = original }(readDirents)
+	readDirents = func(osDirname string, scratchBuffer []byte) (Dirents, error) {
+		children, err := ReadDirents(osDirname, scratchBuffer)
+		if err != nil {
+			return nil, err
+		}
+		reads++
+		if len(children) > 0 {
+			rotate := reads % len(children)
+			children = append(children[rotate:], children[:rotate]...)
+		}
+		return children, nil
+	}
+
+	options := &Options{
+		ScratchBuffer:  testScratchBuffer,
+		Unsorted:       true,
+		StableUnsorted: true,
+	}
+
+	walkOrder := func() []string {
+		var actual []string
+		options.Callback = func(osPathname string, _ *Dirent) error {
+			actual = append(actual, osPathname)
+			return nil
+		}
+		ensureError(t, Walk(filepath.Join(testRoot, "d0"), options))
+		return actual
+	}
+
+	expected := walkOrder()
+
+	for i := 0; i < 3; i++ {
+		actual := walkOrder()
+		if got, want := len(actual), len(expected); got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for j := range expected {
+			if got, want := actual[j], expected[j]; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		}
+	}
+}
+
+func TestWalkBypassAttrCache(t *testing.T) {
+	var actual, expected []string
+	for _, bypassAttrCache := range []bool{true, false} {
+		var entries []string
+		err := Walk(filepath.Join(testRoot, "d0"), &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, _ *Dirent) error {
+				entries = append(entries, osPathname)
+				return nil
+			},
+			BypassAttrCache: bypassAttrCache,
+		})
+		ensureError(t, err)
+		if bypassAttrCache {
+			actual = entries
+		} else {
+			expected = entries
+		}
+	}
+	ensureStringSlicesMatch(t, actual, expected)
+}
+
+func TestWalkSequentialHint(t *testing.T) {
+	var actual, expected []string
+	for _, sequentialHint := range []bool{true, false} {
+		var entries []string
+		err := Walk(filepath.Join(testRoot, "d0"), &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, _ *Dirent) error {
+				entries = append(entries, osPathname)
+				return nil
+			},
+			SequentialHint: sequentialHint,
+		})
+		ensureError(t, err)
+		if sequentialHint {
+			actual = entries
+		} else {
+			expected = entries
+		}
+	}
+	ensureStringSlicesMatch(t, actual, expected)
+}
+
+func TestWalkAccumulateSubtreeSizes(t *testing.T) {
+	// Each file created by setupTree contains its name followed by newline.
+	files := []string{"a/b/c", "a/b/dd", "a/eee", "f/gggg", "h"}
+	root, cleanup := setupTree(t, append(files, "e/")...)
+	defer cleanup()
+
+	expected := map[string]int64{
+		".":   0,
+		"a":   0,
+		"a/b": 0,
+		"e":   0,
+		"f":   0,
+	}
+	for _, name := range files {
+		size := int64(len(name) + 1)
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			expected[dir] += size
+			if dir == "." {
+				break
+			}
+		}
+	}
+
+	actual := make(map[string]int64)
+	err := Walk(root, &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			if de.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, osPathname)
+			if err != nil {
+				return err
+			}
+			if got, want := de.SubtreeSize(), int64(len(filepath.ToSlash(rel))+1); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+			}
+			return nil
+		},
+		PostChildrenCallback: func(osPathname string, de *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			if err != nil {
+				return err
+			}
+			actual[filepath.ToSlash(rel)] = de.SubtreeSize()
+			return nil
+		},
+		AccumulateSubtreeSizes: true,
+	})
+	ensureError(t, err)
+
+	if got, want := len(actual), len(expected); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	for dir, want := range expected {
+		if got := actual[dir]; got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", dir, got, want)
+		}
+	}
+
+	t.Run("callback queue", func(t *testing.T) {
+		// Run with the race detector to see Callback read the size of a
+		// directory while its children are totaled.
+		var mu sync.Mutex
+		var total int64
+		err := Walk(root, &Options{
+			CallbackQueueSize: 4,
+			Callback: func(osPathname string, de *Dirent) error {
+				if de.IsDir() {
+					if got, want := de.SubtreeSize(), int64(0); got != want {
+						t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+					}
+					return nil
+				}
+				mu.Lock()
+				total += de.SubtreeSize()
+				mu.Unlock()
+				return nil
+			},
+			AccumulateSubtreeSizes: true,
+		})
+		ensureError(t, err)
+		if got, want := total, expected["."]; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkSyncAfterCallback(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "c")
+	defer cleanup()
+
+	var opened []*os.File
+
+	err := Walk(root, &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(_ string, de *Dirent) error {
+			if de.IsDir() {
+				return nil
+			}
+			fh, err := de.OpenForWrite()
+			if err != nil {
+				return err
+			}
+			opened = append(opened, fh)
+			_, err = fh.WriteString("synced\n")
+			return err
+		},
+		SyncAfterCallback: true,
+	})
+	ensureError(t, err)
+
+	if got, want := len(opened), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for _, fh := range opened {
+		if _, err := fh.WriteString("after"); err == nil {
+			t.Errorf("%s: GOT: %v; WANT: file closed by Walk", fh.Name(), err)
+		}
+		buf, err := ioutil.ReadFile(fh.Name())
+		ensureError(t, err)
+		if got, want := string(buf[:7]), "synced\n"; got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", fh.Name(), got, want)
+		}
+	}
+}
+
+func TestWalkFailFast(t *testing.T) {
+	injected := errors.New("injected")
+	var actual []string
+	var errorCallbackVisited bool
+
+	options := &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			actual = append(actual, osPathname)
+			if de.Name() == "d1" {
+				return injected
+			}
+			return nil
+		},
+		ErrorCallback: func(_ string, _ error) ErrorAction {
+			errorCallbackVisited = true
+			return SkipNode
+		},
+		FailFast: true,
+	}
+
+	err := Walk(filepath.Join(testRoot, "d0"), options)
+
+	if got, want := err, injected; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := errorCallbackVisited, false; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	expected := []string{
+		filepath.Join(testRoot, "d0"),
+		filepath.Join(testRoot, "d0", maxName),
+		filepath.Join(testRoot, "d0/d1"),
+	}
+	ensureStringSlicesMatch(t, actual, expected)
+
+	if got, want := options.ErrorCallback("", injected), SkipNode; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want) // ensure upstream ErrorCallback restored
+	}
+}
+
+func TestWalkNameTransform(t *testing.T) {
+	root := filepath.Join(testRoot, "d0/d1")
+	var actual []string
+
+	err := Walk(root, &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, de *Dirent) error {
+			actual = append(actual, osPathname)
+			if got, want := de.Path(), osPathname; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if de.IsDir() {
+				return nil
+			}
+			if got, want := de.Name(), "REDACTED-f2"; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			// Ensure the node remains accessible using its actual pathname.
+			fh, err := de.OpenForWrite()
+			if err != nil {
+				return err
+			}
+			return fh.Close()
+		},
+		PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+			actual = append(actual, "post "+osPathname)
+			return nil
+		},
+		NameTransform: func(name string) string { return "REDACTED-" + name },
+	})
+
+	ensureError(t, err)
+
+	expected := []string{
+		root,
+		filepath.Join(root, "REDACTED-f2"),
+		"post " + root,
+	}
+	ensureStringSlicesMatch(t, actual, expected)
+}
+
+func TestWalkCwdRelative(t *testing.T) {
+	// Resolve symbolic links in the test root, because the working directory
+	// is reported with them resolved.
+	d0, err := filepath.EvalSymlinks(filepath.Join(testRoot, "d0"))
+	ensureError(t, err)
+	root := filepath.Join(d0, "d1")
+
+	test := func(t *testing.T, cwd, prefix string) {
+		t.Helper()
+		defer chdir(t, cwd)()
+
+		var actual []string
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			CwdRelative:   true,
+			Callback: func(osPathname string, de *Dirent) error {
+				actual = append(actual, osPathname)
+				if got, want := de.Path(), root; !strings.HasPrefix(got, want) {
+					t.Errorf("GOT: %q; WANT prefix: %q", got, want)
+				}
+				return nil
+			},
+			PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+				actual = append(actual, "post "+osPathname)
+				return nil
+			},
+		})
+		ensureError(t, err)
+
+		expected := []string{
+			prefix,
+			filepath.Join(prefix, "f2"),
+			"post " + prefix,
+		}
+		ensureStringSlicesMatch(t, actual, expected)
+	}
+
+	t.Run("parent", func(t *testing.T) {
+		test(t, d0, "d1")
+	})
+
+	t.Run("sibling", func(t *testing.T) {
+		test(t, filepath.Join(d0, "skips"), filepath.Join("..", "d1"))
+	})
+
+	t.Run("self", func(t *testing.T) {
+		test(t, root, ".")
+	})
+}
+
+func TestWalkCallbackQueueSize(t *testing.T) {
+	root := filepath.Join(testRoot, "d0")
+
+	walkPathnames := func(options *Options) ([]string, error) {
+		var mu sync.Mutex
+		var actual []string
+		options.ScratchBuffer = testScratchBuffer
+		callback := options.Callback
+		options.Callback = func(osPathname string, de *Dirent) error {
+			mu.Lock()
+			actual = append(actual, osPathname)
+			mu.Unlock()
+			if callback != nil {
+				return callback(osPathname, de)
+			}
+			return nil
+		}
+		err := Walk(root, options)
+		return actual, err
+	}
+
+	expected, err := walkPathnames(&Options{})
+	ensureError(t, err)
+
+	t.Run("delivers all nodes", func(t *testing.T) {
+		actual, err := walkPathnames(&Options{CallbackQueueSize: 2})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, actual, expected)
+	})
+
+	t.Run("halts upon error", func(t *testing.T) {
+		_, err := walkPathnames(&Options{
+			CallbackQueueSize: 1,
+			Callback: func(osPathname string, _ *Dirent) error {
+				if filepath.Base(osPathname) == "f2" {
+					return errors.New("some error")
+				}
+				return nil
+			},
+		})
+		ensureError(t, err, "some error")
+	})
+
+	t.Run("skips upon error", func(t *testing.T) {
+		var mu sync.Mutex
+		var errored []string
+		actual, err := walkPathnames(&Options{
+			CallbackQueueSize: 1,
+			Callback: func(osPathname string, _ *Dirent) error {
+				if filepath.Base(osPathname) == "f2" {
+					return errors.New("some error")
+				}
+				return nil
+			},
+			ErrorCallback: func(osPathname string, _ error) ErrorAction {
+				mu.Lock()
+				errored = append(errored, osPathname)
+				mu.Unlock()
+				return SkipNode
+			},
+		})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, actual, expected)
+		ensureStringSlicesMatch(t, errored, []string{filepath.Join(root, "d1/f2")})
+	})
+}
+
+func TestWalkContext(t *testing.T) {
+	type key struct{}
+	root := filepath.Join(testRoot, "d0/d1")
+
+	t.Run("value", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), key{}, "some value")
+		var actual []string
+		err := WalkContext(ctx, root, func(ctx context.Context, osPathname string, _ *Dirent) error {
+			if got, want := ctx.Value(key{}), "some value"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			actual = append(actual, osPathname)
+			return nil
+		}, &Options{ScratchBuffer: testScratchBuffer})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, actual, []string{root, filepath.Join(root, "f2")})
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var actual []string
+		err := WalkContext(ctx, root, func(_ context.Context, osPathname string, _ *Dirent) error {
+			actual = append(actual, osPathname)
+			cancel()
+			return nil
+		}, &Options{
+			ScratchBuffer: testScratchBuffer,
+			ErrorCallback: func(string, error) ErrorAction { return SkipNode },
+		})
+		if got, want := err, context.Canceled; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringSlicesMatch(t, actual, []string{root})
+	})
+}
+
+func TestWalkOnDanglingSymlink(t *testing.T) {
+	root, cleanup := setupTree(t, "f")
+	defer cleanup()
+	dangling := filepath.Join(root, "dangling")
+	ensureError(t, os.Symlink("missing", dangling))
+	f := filepath.Join(root, "f")
+
+	for _, tc := range []struct {
+		name             string
+		action           DanglingSymlinkAction
+		follow           bool
+		visited, errored []string
+	}{
+		{"default", DanglingSymlinkDefault, false, []string{root, dangling, f}, nil},
+		{"default following", DanglingSymlinkDefault, true, []string{root, dangling, f}, []string{dangling}},
+		{"skip", DanglingSymlinkSkip, false, []string{root, f}, nil},
+		{"skip following", DanglingSymlinkSkip, true, []string{root, f}, nil},
+		{"report", DanglingSymlinkReport, false, []string{root, f}, []string{dangling}},
+		{"report following", DanglingSymlinkReport, true, []string{root, f}, []string{dangling}},
+		{"include", DanglingSymlinkInclude, false, []string{root, dangling, f}, nil},
+		{"include following", DanglingSymlinkInclude, true, []string{root, dangling, f}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var visited, errored []string
+			err := Walk(root, &Options{
+				ScratchBuffer:       testScratchBuffer,
+				FollowSymbolicLinks: tc.follow,
+				OnDanglingSymlink:   tc.action,
+				Callback: func(osPathname string, _ *Dirent) error {
+					visited = append(visited, osPathname)
+					return nil
+				},
+				ErrorCallback: func(osPathname string, _ error) ErrorAction {
+					errored = append(errored, osPathname)
+					return SkipNode
+				},
+			})
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, visited, tc.visited)
+			ensureStringSlicesMatch(t, errored, tc.errored)
+		})
+	}
+}
+
+func TestWalkDirPriority(t *testing.T) {
+	root, cleanup := setupTree(t, "a", "pkg/", "src/f", "vendor/g", "z")
+	defer cleanup()
+
+	var actual []string
+	err := Walk(root, &Options{
+		ScratchBuffer: testScratchBuffer,
+		Callback: func(osPathname string, _ *Dirent) error {
+			if osPathname != root {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			return nil
+		},
+		DirPriority: func(osPathname string, de *Dirent) int {
+			if got, want := osPathname, filepath.Join(root, de.Name()); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			switch de.Name() {
+			case "src":
+				return 1
+			case "vendor":
+				return -1
+			}
+			return 0
+		},
+	})
+	ensureError(t, err)
+
+	if got, want := strings.Join(actual, " "), "src src/f a pkg z vendor vendor/g"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestWalkRegexp(t *testing.T) {
+	root, cleanup := setupTree(t, "a.go", "a_test.go", "b.txt", "pkg/c.go", "pkg/d.txt", "testdata/e.go")
+	defer cleanup()
+
+	walkNames := func(t *testing.T, options *Options) []string {
+		t.Helper()
+		var actual []string
+		options.ScratchBuffer = testScratchBuffer
+		options.Callback = func(osPathname string, _ *Dirent) error {
+			if osPathname != root {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			return nil
+		}
+		ensureError(t, Walk(root, options))
+		return actual
+	}
+
+	t.Run("exclude", func(t *testing.T) {
+		actual := walkNames(t, &Options{ExcludeRegexp: regexp.MustCompile(`^testdata$|_test\.go$`)})
+		if got, want := strings.Join(actual, " "), "a.go b.txt pkg pkg/c.go pkg/d.txt"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("include", func(t *testing.T) {
+		actual := walkNames(t, &Options{IncludeRegexp: regexp.MustCompile(`\.go$`)})
+		if got, want := strings.Join(actual, " "), "a.go a_test.go pkg pkg/c.go testdata testdata/e.go"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("both", func(t *testing.T) {
+		actual := walkNames(t, &Options{
+			ExcludeRegexp: regexp.MustCompile(`^testdata$|_test\.go$`),
+			IncludeRegexp: regexp.MustCompile(`\.go$`),
+		})
+		if got, want := strings.Join(actual, " "), "a.go pkg pkg/c.go"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestWalkDeferSiblingErrors(t *testing.T) {
+	root, cleanup := setupTree(t, "a", "b/x", "c", "d/y", "e/f/g", "e/f/h", "e/i")
+	defer cleanup()
+
+	walkFailing := func(t *testing.T, failing string, options *Options) ([]string, error) {
+		t.Helper()
+		var actual []string
+		options.ScratchBuffer = testScratchBuffer
+		options.Callback = func(osPathname string, _ *Dirent) error {
+			if osPathname == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			if osPathname == filepath.Join(root, failing) {
+				return errors.New("failing " + failing)
+			}
+			return nil
+		}
+		return actual, Walk(root, options)
+	}
+
+	t.Run("without", func(t *testing.T) {
+		actual, err := walkFailing(t, "b", &Options{})
+		ensureError(t, err, "failing b")
+		if got, want := strings.Join(actual, " "), "a b"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("with", func(t *testing.T) {
+		var postChildren []string
+		actual, err := walkFailing(t, "b", &Options{
+			DeferSiblingErrors: true,
+			PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+				postChildren = append(postChildren, osPathname)
+				return nil
+			},
+		})
+		ensureError(t, err, "failing b")
+		if got, want := strings.Join(actual, " "), "a b c d d/y e e/f e/f/g e/f/h e/i"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		// Root has a child that failed, so it is the only directory omitted.
+		expected := []string{filepath.Join(root, "d"), filepath.Join(root, "e/f"), filepath.Join(root, "e")}
+		ensureStringSlicesMatch(t, postChildren, expected)
+	})
+
+	t.Run("ancestor siblings not visited", func(t *testing.T) {
+		actual, err := walkFailing(t, "e/f/g", &Options{DeferSiblingErrors: true})
+		ensureError(t, err, "failing e/f/g")
+		if got, want := strings.Join(actual, " "), "a b b/x c d d/y e e/f e/f/g e/f/h"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		actual, err := walkFailing(t, "b", &Options{DeferSiblingErrors: true, FailFast: true})
+		ensureError(t, err, "failing b")
+		if got, want := strings.Join(actual, " "), "a b"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestWalkMaxEntriesPerDir(t *testing.T) {
+	root, cleanup := setupTree(t, "d/a", "d/b", "d/c", "d/e", "d/f", "g")
+	defer cleanup()
+
+	t.Run("skip node", func(t *testing.T) {
+		var tooLarge []string
+		var count int
+		err := Walk(root, &Options{
+			MaxEntriesPerDir: 3,
+			ScratchBuffer:    testScratchBuffer,
+			Callback: func(osPathname string, _ *Dirent) error {
+				if filepath.Dir(osPathname) == filepath.Join(root, "d") {
+					count++
+				}
+				return nil
+			},
+			ErrorCallback: func(osPathname string, err error) ErrorAction {
+				if err != ErrDirTooLarge {
+					t.Errorf("GOT: %v; WANT: %v", err, ErrDirTooLarge)
+				}
+				tooLarge = append(tooLarge, osPathname)
+				return SkipNode
+			},
+		})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, tooLarge, []string{filepath.Join(root, "d")})
+		if got, want := count, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("halt", func(t *testing.T) {
+		err := Walk(root, &Options{
+			MaxEntriesPerDir: 3,
+			ScratchBuffer:    testScratchBuffer,
+			Callback:         func(string, *Dirent) error { return nil },
+		})
+		if !errors.Is(err, ErrDirTooLarge) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrDirTooLarge)
+		}
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		err := Walk(root, &Options{
+			MaxEntriesPerDir: 5,
+			ScratchBuffer:    testScratchBuffer,
+			Callback:         func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err)
+	})
+}
+
+func TestWalkNonDirectoryRoot(t *testing.T) {
+	root, cleanup := setupTree(t, "file", "dir/")
+	defer cleanup()
+
+	osFilename := filepath.Join(root, "file")
+	osSymlinkname := filepath.Join(root, "symlink")
+	if err := os.Symlink("dir", osSymlinkname); err != nil {
+		t.Skip(err)
+	}
+
+	t.Run("regular file", func(t *testing.T) {
+		var actual []string
+		err := Walk(osFilename, &Options{
+			Callback: func(osPathname string, de *Dirent) error {
+				if !de.IsRegular() {
+					t.Errorf("GOT: %v; WANT: regular file", de.ModeType())
+				}
+				actual = append(actual, osPathname)
+				return nil
+			},
+		})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, actual, []string{osFilename})
+	})
+
+	t.Run("symbolic link", func(t *testing.T) {
+		var actual []string
+		err := Walk(osSymlinkname, &Options{
+			Callback: func(osPathname string, de *Dirent) error {
+				if !de.IsSymlink() {
+					t.Errorf("GOT: %v; WANT: symbolic link", de.ModeType())
+				}
+				actual = append(actual, osPathname)
+				return nil
+			},
+		})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, actual, []string{osSymlinkname})
+	})
+
+	t.Run("require dir", func(t *testing.T) {
+		for _, osPathname := range []string{osFilename, osSymlinkname} {
+			err := Walk(osPathname, &Options{
+				RequireDir: true,
+				Callback: func(osPathname string, _ *Dirent) error {
+					t.Errorf("GOT: %q; WANT: no callback", osPathname)
+					return nil
+				},
+			})
+			ensureError(t, err, "cannot Walk non-directory")
+		}
+	})
+
+	t.Run("require dir following symbolic links", func(t *testing.T) {
+		var actual []string
+		err := Walk(osSymlinkname, &Options{
+			RequireDir:          true,
+			FollowSymbolicLinks: true,
+			Callback: func(osPathname string, _ *Dirent) error {
+				actual = append(actual, osPathname)
+				return nil
+			},
+		})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, actual, []string{osSymlinkname})
+	})
+}
+
+func TestWalkMaxAllocBytes(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "d/e")
+	defer cleanup()
+
+	var sink []byte
+
+	t.Run("exceeded", func(t *testing.T) {
+		err := Walk(root, &Options{
+			MaxAllocBytes: 1,
+			Callback: func(string, *Dirent) error {
+				sink = make([]byte, 1024) // ensure limit is exceeded before reading first directory
+				return nil
+			},
+			ErrorCallback: func(string, error) ErrorAction { return SkipNode },
+		})
+		if !errors.Is(err, ErrAllocLimit) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrAllocLimit)
+		}
+		if got, want := len(sink), 1024; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("not exceeded", func(t *testing.T) {
+		var count int
+		err := Walk(root, &Options{
+			MaxAllocBytes: 1 << 30,
+			Callback: func(string, *Dirent) error {
+				count++
+				return nil
+			},
+		})
+		ensureError(t, err)
+		if got, want := count, 6; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkCallbackWithShard(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "a/c", "d", "e/")
+	defer cleanup()
+
+	walkShards := func(t *testing.T, shardCount uint64) map[string]uint64 {
+		t.Helper()
+		shards := make(map[string]uint64)
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			ShardCount:    shardCount,
+			CallbackWithShard: func(shard uint64, osPathname string, _ *Dirent) error {
+				shards[osPathname] = shard
+				return nil
+			},
+		})
+		ensureError(t, err)
+		return shards
+	}
+
+	t.Run("stable", func(t *testing.T) {
+		first, second := walkShards(t, 0), walkShards(t, 0)
+		if got, want := len(first), 6; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for osPathname, shard := range first {
+			if got, want := second[osPathname], shard; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+			}
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(filepath.ToSlash(osPathname)))
+			if got, want := shard, h.Sum64(); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+			}
+		}
+	})
+
+	t.Run("shard count", func(t *testing.T) {
+		unreduced := walkShards(t, 0)
+		for osPathname, shard := range walkShards(t, 3) {
+			if got, want := shard, unreduced[osPathname]%3; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+			}
+		}
+	})
+
+	t.Run("callback takes precedence", func(t *testing.T) {
+		var count int
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(string, *Dirent) error {
+				count++
+				return nil
+			},
+			CallbackWithShard: func(uint64, string, *Dirent) error {
+				t.Errorf("GOT: CallbackWithShard invoked; WANT: Callback invoked")
+				return nil
+			},
+		})
+		ensureError(t, err)
+		if got, want := count, 6; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkCallbackWithUserData(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "d")
+	defer cleanup()
+
+	type config struct{ visited map[string]bool }
+	userData := &config{visited: make(map[string]bool)}
+
+	err := Walk(root, &Options{
+		ScratchBuffer: testScratchBuffer,
+		UserData:      userData,
+		CallbackWithUserData: func(osPathname string, _ *Dirent, data interface{}) error {
+			cfg, ok := data.(*config)
+			if !ok || cfg != userData {
+				t.Fatalf("GOT: %v; WANT: %v", data, userData)
+			}
+			cfg.visited[osPathname] = true
+			return nil
+		},
+	})
+	ensureError(t, err)
+	if got, want := len(userData.visited), 4; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		err := Walk(root, &Options{
+			CallbackWithUserData: func(_ string, _ *Dirent, data interface{}) error {
+				if data != nil {
+					t.Errorf("GOT: %v; WANT: nil", data)
+				}
+				return nil
+			},
+		})
+		ensureError(t, err)
+	})
+
+	t.Run("callback takes precedence", func(t *testing.T) {
+		var count int
+		err := Walk(root, &Options{
+			ScratchBuffer: testScratchBuffer,
+			UserData:      userData,
+			Callback: func(string, *Dirent) error {
+				count++
+				return nil
+			},
+			CallbackWithUserData: func(string, *Dirent, interface{}) error {
+				t.Errorf("GOT: CallbackWithUserData invoked; WANT: Callback invoked")
+				return nil
+			},
+		})
+		ensureError(t, err)
+		if got, want := count, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkRealPath(t *testing.T) {
+	root, cleanup := setupTree(t, "d/f", "g")
+	defer cleanup()
+	osLinkname := filepath.Join(root, "toD")
+	if err := os.Symlink("d", osLinkname); err != nil {
+		t.Skip(err)
+	}
+	ensureError(t, os.Symlink("missing", filepath.Join(root, "dangling")))
+
+	expected, err := filepath.EvalSymlinks(osLinkname)
+	ensureError(t, err)
+
+	walkRealPaths := func(t *testing.T, followSymbolicLinks bool) map[string]string {
+		t.Helper()
+		realPaths := make(map[string]string)
+		err := Walk(root, &Options{
+			FollowSymbolicLinks: followSymbolicLinks,
+			OnDanglingSymlink:   DanglingSymlinkInclude,
+			ScratchBuffer:       testScratchBuffer,
+			Callback: func(osPathname string, de *Dirent) error {
+				if got, want := de.Path(), osPathname; got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+				realPaths[osPathname] = de.RealPath()
+				return nil
+			},
+		})
+		ensureError(t, err)
+		return realPaths
+	}
+
+	t.Run("followed", func(t *testing.T) {
+		realPaths := walkRealPaths(t, true)
+		if got, want := realPaths[osLinkname], expected; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		for _, name := range []string{"d", "d/f", "g", "dangling", "toD/f"} {
+			if got, want := realPaths[filepath.Join(root, name)], ""; got != want {
+				t.Errorf("%s: GOT: %q; WANT: %q", name, got, want)
+			}
+		}
+	})
+
+	t.Run("not followed", func(t *testing.T) {
+		if got, want := walkRealPaths(t, false)[osLinkname], ""; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestWalkResolveRootSymlink(t *testing.T) {
+	root, cleanup := setupTree(t, "d/a", "d/b/c")
+	defer cleanup()
+	osLinkname := filepath.Join(root, "link")
+	if err := os.Symlink("d", osLinkname); err != nil {
+		t.Skip(err)
+	}
+
+	walkLink := func(t *testing.T, options *Options) []string {
+		t.Helper()
+		var actual []string
+		options.ScratchBuffer = testScratchBuffer
+		options.Callback = func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		}
+		ensureError(t, Walk(osLinkname, options))
+		return actual
+	}
+
+	t.Run("resolved", func(t *testing.T) {
+		actual := walkLink(t, &Options{ResolveRootSymlink: true})
+		if got, want := strings.Join(actual, " "), "link link/a link/b link/b/c"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("not resolved", func(t *testing.T) {
+		actual := walkLink(t, &Options{})
+		if got, want := strings.Join(actual, " "), "link"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestWalkNoReuseHint(t *testing.T) {
+	root, cleanup := setupTree(t, "a", "b/c")
+	defer cleanup()
+
+	for _, noReuseHint := range []bool{true, false} {
+		var contents []string
+		err := Walk(root, &Options{
+			NoReuseHint:   noReuseHint,
+			ScratchBuffer: testScratchBuffer,
+			Callback: func(osPathname string, de *Dirent) error {
+				if got, want := de.noReuse, noReuseHint; got != want {
+					t.Errorf("%s: GOT: %v; WANT: %v", osPathname, got, want)
+				}
+				if !de.IsRegular() {
+					return nil
+				}
+				fh, err := de.Open()
+				if err != nil {
+					return err
+				}
+				buf, err := ioutil.ReadAll(fh)
+				if er := fh.Close(); err == nil {
+					err = er
+				}
+				contents = append(contents, string(buf))
+				return err
+			},
+		})
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, contents, []string{"a\n", "b/c\n"})
+	}
+}
+
+func TestWalkStreamingOnly(t *testing.T) {
+	const width = 500
+
+	entries := make([]string, 0, width+1)
+	entries = append(entries, "deep/a/b")
+	for i := 0; i < width; i++ {
+		entries = append(entries, fmt.Sprintf("wide/%05d", i))
+	}
+	root, cleanup := setupTree(t, entries...)
+	defer cleanup()
+
+	defer func(original func(string, []byte) (Dirents, error)) { readDirents = original }(readDirents)
+	readDirents = func(osDirname string, scratchBuffer []byte) (Dirents, error) {
+		t.Errorf("GOT: %q read into memory; WANT: streamed", osDirname)
+		return ReadDirents(osDirname, scratchBuffer)
+	}
+
+	want := map[string]bool{
+		root:                                  false,
+		filepath.Join(root, "deep", "a", "b"): false,
+		filepath.Join(root, "wide"):           false,
+		filepath.Join(root, "wide", "00000"):  false,
+		filepath.Join(root, "wide", fmt.Sprintf("%05d", width-1)): false,
+	}
+
+	var count int
+
+	err := Walk(root, &Options{
+		StreamingOnly: true,
+		Callback: func(osPathname string, _ *Dirent) error {
+			if _, ok := want[osPathname]; ok {
+				want[osPathname] = true
+			}
+			count++
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	if got, want := count, width+5; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	for osPathname, visited := range want {
+		if !visited {
+			t.Errorf("GOT: %q not visited; WANT: visited", osPathname)
+		}
+	}
+}
+
+func TestWalkAnnotateSymlinkTargets(t *testing.T) {
+	root, cleanup := setupTree(t, "d/f", "g")
+	defer cleanup()
+	if err := os.Symlink("d", filepath.Join(root, "toD")); err != nil {
+		t.Skip(err)
+	}
+	ensureError(t, os.Symlink("g", filepath.Join(root, "toG")))
+	ensureError(t, os.Symlink("missing", filepath.Join(root, "dangling")))
+
+	type target struct {
+		modeType os.FileMode
+		ok       bool
+	}
+
+	walkTargets := func(t *testing.T, annotate bool) map[string]target {
+		t.Helper()
+		targets := make(map[string]target)
+		err := Walk(root, &Options{
+			AnnotateSymlinkTargets: annotate,
+			ScratchBuffer:          testScratchBuffer,
+			Callback: func(osPathname string, de *Dirent) error {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				modeType, ok := de.TargetType()
+				targets[filepath.ToSlash(rel)] = target{modeType, ok}
+				return nil
+			},
+		})
+		ensureError(t, err)
+		return targets
+	}
+
+	t.Run("annotated", func(t *testing.T) {
+		targets := walkTargets(t, true)
+		for name, want := range map[string]target{
+			"toD":      {os.ModeDir, true},
+			"toG":      {0, true},
+			"dangling": {0, false},
+			"d":        {0, false},
+			"g":        {0, false},
+		} {
+			if got := targets[name]; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+			}
+		}
+		// The link to the directory is annotated, but not followed.
+		if _, ok := targets["toD/f"]; ok {
+			t.Errorf("GOT: toD/f visited; WANT: not visited")
+		}
+	})
+
+	t.Run("not annotated", func(t *testing.T) {
+		if got, want := walkTargets(t, false)["toD"], (target{}); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkBoundaryMarkers(t *testing.T) {
+	root, cleanup := setupTree(t,
+		".git/HEAD",
+		"a/b/c",
+		"nested/.git/HEAD",
+		"nested/src/main.go",
+		"other/go.mod",
+		"other/pkg/x.go",
+		"z",
+	)
+	defer cleanup()
+
+	var actual []string
+	err := Walk(root, &Options{
+		BoundaryMarkers: []string{".git", "go.mod"},
+		ScratchBuffer:   testScratchBuffer,
+		Callback: func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	// The root contains a marker, but is walked, while the directories
+	// containing markers below it are reported but not descended into.
+	expected := ". .git .git/HEAD a a/b a/b/c nested other z"
+	if got, want := strings.Join(actual, " "), expected; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("post children", func(t *testing.T) {
+		var actual []string
+		err := Walk(root, &Options{
+			BoundaryMarkers: []string{".git", "go.mod"},
+			ScratchBuffer:   testScratchBuffer,
+			Callback:        func(string, *Dirent) error { return nil },
+			PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+				rel, err := filepath.Rel(root, osPathname)
+				ensureError(t, err)
+				actual = append(actual, filepath.ToSlash(rel))
+				return nil
+			},
+		})
+		ensureError(t, err)
+
+		// Each directory reported to Callback, including those pruned at a
+		// boundary, is reported to PostChildrenCallback.
+		if got, want := strings.Join(actual, " "), ".git a/b a nested other ."; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("MerkleHash", func(t *testing.T) {
+		digest, err := MerkleHash(root, &Options{BoundaryMarkers: []string{".git"}}, sha256.New)
+		ensureError(t, err)
+		if got, want := len(digest), sha256.Size; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkSymlinkStats(t *testing.T) {
+	root, cleanup := setupTree(t, "d/f")
+	defer cleanup()
+	if err := os.Symlink("d", filepath.Join(root, "l3")); err != nil {
+		t.Skip(err)
+	}
+	ensureError(t, os.Symlink("l3", filepath.Join(root, "l2")))
+	ensureError(t, os.Symlink("l2", filepath.Join(root, "l1")))
+	ensureError(t, os.Symlink("d/f", filepath.Join(root, "toF")))
+
+	walkHops := func(t *testing.T, options *Options) map[string]int {
+		t.Helper()
+		hops := make(map[string]int)
+		options.Callback = func(osPathname string, de *Dirent) error {
+			if de.IsSymlink() {
+				hops[filepath.Base(osPathname)] = de.SymlinkHops()
+			}
+			return nil
+		}
+		ensureError(t, Walk(root, options))
+		return hops
+	}
+
+	t.Run("followed", func(t *testing.T) {
+		stats := &WalkStats{SymlinksFollowed: 42} // reset by Walk
+		hops := walkHops(t, &Options{FollowSymbolicLinks: true, CountSymlinkHops: true, Stats: stats})
+		for name, want := range map[string]int{"l1": 3, "l2": 2, "l3": 1, "toF": 1} {
+			if got := hops[name]; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+			}
+		}
+		// Each link is resolved once to determine whether it refers to a
+		// directory, and once to obtain its real path.
+		if got, want := *stats, (WalkStats{SymlinksFollowed: 4, EvalSymlinkCalls: 4}); got != want {
+			t.Errorf("GOT: %+v; WANT: %+v", got, want)
+		}
+	})
+
+	t.Run("annotated", func(t *testing.T) {
+		stats := new(WalkStats)
+		walkHops(t, &Options{AnnotateSymlinkTargets: true, Stats: stats})
+		if got, want := *stats, (WalkStats{SymlinksFollowed: 4}); got != want {
+			t.Errorf("GOT: %+v; WANT: %+v", got, want)
+		}
+	})
+
+	t.Run("not followed", func(t *testing.T) {
+		stats := new(WalkStats)
+		hops := walkHops(t, &Options{Stats: stats})
+		if got, want := hops["l1"], 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := *stats, (WalkStats{}); got != want {
+			t.Errorf("GOT: %+v; WANT: %+v", got, want)
+		}
+	})
+}
+
+func TestWalkEagerDescend(t *testing.T) {
+	var entries []string
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				entries = append(entries, fmt.Sprintf("d%d/d%d/d%d/file", i, j, k))
+			}
+		}
+	}
+	root, cleanup := setupTree(t, entries...)
+	defer cleanup()
+
+	var expected []string
+	err := Walk(root, &Options{
+		Callback: func(osPathname string, _ *Dirent) error {
+			expected = append(expected, osPathname)
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	var mu sync.Mutex
+	var actual []string
+	post := make(map[string]bool)
+	var inFlight, maxInFlight int32
+
+	err = Walk(root, &Options{
+		EagerDescend: true,
+		Callback: func(osPathname string, de *Dirent) error {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			if de.IsDir() {
+				time.Sleep(time.Millisecond) // give other goroutines a chance to run
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if post[filepath.Dir(osPathname)] {
+				t.Errorf("GOT: %q after its parent's PostChildrenCallback; WANT: before", osPathname)
+			}
+			actual = append(actual, osPathname)
+			return nil
+		},
+		PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			post[osPathname] = true
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	sort.Strings(expected)
+	sort.Strings(actual)
+	if got, want := strings.Join(actual, "\n"), strings.Join(expected, "\n"); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := len(post), 1+4+16+64; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	// Each worker goroutine, and the goroutine that invoked Walk.
+	if got, limit := atomic.LoadInt32(&maxInFlight), int32(runtime.NumCPU()+1); got > limit {
+		t.Errorf("GOT: %v; WANT: <= %v", got, limit)
+	}
+
+	t.Run("halts", func(t *testing.T) {
+		errHalt := errors.New("halt")
+		err := Walk(root, &Options{
+			EagerDescend: true,
+			Callback: func(osPathname string, _ *Dirent) error {
+				if osPathname == filepath.Join(root, "d0", "d0", "d0", "file") {
+					return errHalt
+				}
+				return nil
+			},
+		})
+		if got, want := err, errHalt; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("checkpoint", func(t *testing.T) {
+		err := Walk(root, &Options{
+			EagerDescend:   true,
+			CheckpointFile: filepath.Join(root, "checkpoint"),
+			Callback:       func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "EagerDescend")
+	})
+}
+
+func TestWalkSilentPermissionErrors(t *testing.T) {
+	root, cleanup := setupTree(t, "a/f", "locked/f", "z/f")
+	defer cleanup()
+	locked := filepath.Join(root, "locked")
+
+	defer func(original func(string, []byte) (Dirents, error)) { readDirents = original }(readDirents)
+	readDirents = func(osDirname string, scratchBuffer []byte) (Dirents, error) {
+		if osDirname == locked {
+			return nil, &os.PathError{Op: "open", Path: osDirname, Err: os.ErrPermission}
+		}
+		return ReadDirents(osDirname, scratchBuffer)
+	}
+
+	var actual, post []string
+	err := Walk(root, &Options{
+		SilentPermissionErrors: true,
+		ScratchBuffer:          testScratchBuffer,
+		Callback: func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		},
+		PostChildrenCallback: func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			post = append(post, filepath.ToSlash(rel))
+			return nil
+		},
+		ErrorCallback: func(osPathname string, err error) ErrorAction {
+			t.Errorf("GOT: %q: %v; WANT: no error hook", osPathname, err)
+			return Halt
+		},
+	})
+	ensureError(t, err)
+
+	if got, want := strings.Join(actual, " "), ". a a/f locked z z/f"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := strings.Join(post, " "), "a locked z ."; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("not silent", func(t *testing.T) {
+		err := Walk(root, &Options{
+			Callback: func(string, *Dirent) error { return nil },
+		})
+		if !os.IsPermission(err) {
+			t.Errorf("GOT: %v; WANT: permission error", err)
+		}
+	})
+}
+
+func TestWalkRunStartCallback(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "c")
+	defer cleanup()
+
+	walkOnce := func(t *testing.T) RunMeta {
+		t.Helper()
+		var metas []RunMeta
+		var visited int
+		err := Walk(root+string(filepath.Separator), &Options{
+			RunStartCallback: func(meta RunMeta) {
+				if visited > 0 {
+					t.Errorf("GOT: %d nodes visited; WANT: callback before any node", visited)
+				}
+				metas = append(metas, meta)
+			},
+			Callback: func(string, *Dirent) error {
+				visited++
+				return nil
+			},
+		})
+		ensureError(t, err)
+		if got, want := len(metas), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		return metas[0]
+	}
+
+	before := time.Now()
+	meta := walkOnce(t)
+	if got, want := meta.Root, root; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if meta.ID == "" {
+		t.Errorf("GOT: empty ID; WANT: nonempty ID")
+	}
+	if meta.Start.Before(before) {
+		t.Errorf("GOT: %v; WANT: not before %v", meta.Start, before)
+	}
+
+	if got, other := meta.ID, walkOnce(t).ID; got == other {
+		t.Errorf("GOT: %q for two walks; WANT: distinct IDs", got)
+	}
+}
+
+func TestWalkFollowThis(t *testing.T) {
+	root, cleanup := setupTree(t, "dir1/x", "dir2/y", "z")
+	defer cleanup()
+	if err := os.Symlink("dir1", filepath.Join(root, "link1")); err != nil {
+		t.Skip(err)
+	}
+	ensureError(t, os.Symlink("dir2", filepath.Join(root, "link2")))
+
+	walkFollowing := func(t *testing.T, options Options, follow string) []string {
+		t.Helper()
+		var mu sync.Mutex // the callback queue invokes the callback concurrently
+		var actual []string
+		options.Callback = func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			mu.Lock()
+			actual = append(actual, filepath.ToSlash(rel))
+			mu.Unlock()
+			if name := filepath.Base(osPathname); name == follow || name == "z" {
+				return FollowThis // ignored for nodes other than symbolic links
+			}
+			return nil
+		}
+		ensureError(t, Walk(root, &options))
+		return actual
+	}
+
+	t.Run("selected", func(t *testing.T) {
+		actual := walkFollowing(t, Options{}, "link2")
+		if got, want := strings.Join(actual, " "), ". dir1 dir1/x dir2 dir2/y link1 link2 link2/y z"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("callback queue", func(t *testing.T) {
+		actual := walkFollowing(t, Options{CallbackQueueSize: 1}, "link2")
+		sort.Strings(actual)
+		if got, want := strings.Join(actual, " "), ". dir1 dir1/x dir2 dir2/y link1 link2 z"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestWalkShouldVisit(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "a/c", "d/e", "f")
+	defer cleanup()
+
+	var asked, visited []string
+	rel := func(osPathname string) string {
+		rel, err := filepath.Rel(root, osPathname)
+		ensureError(t, err)
+		return filepath.ToSlash(rel)
+	}
+	err := Walk(root, &Options{
+		ShouldVisit: func(osPathname string, de *Dirent) bool {
+			if got, want := de.Path(), osPathname; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			asked = append(asked, rel(osPathname))
+			return de.Name() != "c" && de.Name() != "d"
+		},
+		Callback: func(osPathname string, _ *Dirent) error {
+			visited = append(visited, rel(osPathname))
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	// The descendants of a rejected directory are neither asked about nor
+	// visited.
+	if got, want := strings.Join(asked, " "), "a a/b a/c d f"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := strings.Join(visited, " "), ". a a/b f"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestWalkTransformPath(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "c")
+	defer cleanup()
+	if err := os.Symlink(filepath.Join("..", "c"), filepath.Join(root, "a", "link")); err != nil {
+		t.Skip(err)
+	}
+
+	home := filepath.Join(string(filepath.Separator), "home", "user")
+	var actual, visited []string
+	err := Walk(root, &Options{
+		TransformPath: func(osPathname string, _ *Dirent) string {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			return filepath.Join(home, rel)
+		},
+		ShouldVisit: func(osPathname string, _ *Dirent) bool {
+			visited = append(visited, osPathname)
+			return true
+		},
+		Callback: func(osPathname string, de *Dirent) error {
+			// The Dirent describes the actual node.
+			if got, want := de.Path(), filepath.Join(root, strings.TrimPrefix(osPathname, home)); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			actual = append(actual, osPathname)
+			if de.IsRegular() {
+				fh, err := de.Open()
+				ensureError(t, err)
+				ensureError(t, fh.Close())
+			}
+			if de.IsSymlink() {
+				target, err := de.ResolveRelativeTarget()
+				ensureError(t, err)
+				if got, want := target, "c"; got != want {
+					t.Errorf("GOT: %q; WANT: %q", got, want)
+				}
+			}
+			return nil
+		},
+	})
+	ensureError(t, err)
+
+	expected := []string{home, filepath.Join(home, "a"), filepath.Join(home, "a", "b"), filepath.Join(home, "a", "link"), filepath.Join(home, "c")}
+	if got, want := strings.Join(actual, " "), strings.Join(expected, " "); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := strings.Join(visited, " "), strings.Join(expected[1:], " "); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("helpers", func(t *testing.T) {
+		// Helpers identify nodes by their actual pathnames relative to the
+		// root, so they ignore TransformPath.
+		var manifest bytes.Buffer
+		_, err := WriteManifest(&manifest, root, &ManifestOptions{Options: Options{
+			TransformPath: func(osPathname string, _ *Dirent) string { return filepath.Join(home, osPathname) },
+		}})
+		ensureError(t, err)
+		if got, want := strings.Count(manifest.String(), "\n"), 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestWalkAssertReadOnly(t *testing.T) {
+	root, cleanup := setupTree(t, "a", "b/c", "d")
+	defer cleanup()
+
+	t.Run("read only", func(t *testing.T) {
+		err := Walk(root, &Options{
+			AssertReadOnly: true,
+			Callback: func(osPathname string, de *Dirent) error {
+				if de.IsRegular() {
+					_, err := ioutil.ReadFile(osPathname)
+					return err
+				}
+				return nil
+			},
+		})
+		ensureError(t, err)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		var modified []string
+		err := Walk(root, &Options{
+			AssertReadOnly: true,
+			Callback: func(osPathname string, de *Dirent) error {
+				switch de.Name() {
+				case "c":
+					fh, err := de.OpenForWrite()
+					ensureError(t, err)
+					_, err = fh.WriteAt([]byte("changed\n"), 0)
+					ensureError(t, err)
+					ensureError(t, fh.Close())
+				case "d":
+					ensureError(t, os.Remove(osPathname))
+				}
+				return nil
+			},
+			ErrorCallback: func(osPathname string, err error) ErrorAction {
+				if !errors.Is(err, ErrModifiedByCallback) {
+					t.Errorf("GOT: %v; WANT: %v", err, ErrModifiedByCallback)
+				}
+				modified = append(modified, filepath.Base(osPathname))
+				return SkipNode
+			},
+		})
+		ensureError(t, err)
+		if got, want := strings.Join(modified, " "), "c d"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestWalkConcurrentSharedOptions(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "d/e", "f")
+	defer cleanup()
+
+	var count int64
+	options := &Options{
+		Callback: func(_ string, _ *Dirent) error {
+			atomic.AddInt64(&count, 1)
+			return nil
+		},
+		Unsorted:       true,
+		StableUnsorted: true,
+		FailFast:       true,
+		EagerDescend:   true,
+		MaxOpenDirs:    2,
+		Stats:          new(WalkStats),
+	}
+
+	const walks = 4
+	var wg sync.WaitGroup
+	errs := make([]error, walks)
+	for i := 0; i < walks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = Walk(root, options)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		ensureError(t, err)
+	}
+	if got, want := atomic.LoadInt64(&count), int64(walks*7); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if options.ErrorCallback != nil || options.ScratchBuffer != nil {
+		t.Errorf("GOT: modified Options; WANT: Options unchanged")
+	}
+}
+
 const flameIterations = 10
 
 var goPrefix = filepath.Join(os.Getenv("GOPATH"), "src")
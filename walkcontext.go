@@ -0,0 +1,58 @@
+package godirwalk
+
+import "context"
+
+// WalkContextFunc is the type of the function called by WalkContext for each
+// file system node visited. It is identical to WalkFunc, except that it is
+// also provided the context given to WalkContext, so the function may obtain
+// shared resources, such as database handles or loggers, using the Value
+// method of the context rather than capturing them in a closure, and may be
+// tested independently of the code that provides those resources.
+type WalkContextFunc func(ctx context.Context, osPathname string, directoryEntry *Dirent) error
+
+// WalkContext walks the file tree rooted at the specified directory as Walk
+// does, invoking the specified callback function with the provided context for
+// each file system node in the tree.
+//
+// The walk halts once the context is canceled, in which case WalkContext
+// returns the error returned by the Err method of the context, regardless of
+// the action an ErrorCallback function would otherwise have taken.
+//
+// The provided Options may be nil. When not nil, its Callback field is
+// ignored.
+//
+//    ctx := context.WithValue(context.Background(), loggerKey, logger)
+//    logNode := func(ctx context.Context, osPathname string,
+//        de *godirwalk.Dirent) error {
+//        logger := ctx.Value(loggerKey).(*log.Logger)
+//        logger.Printf("%s %s", de.ModeType(), osPathname)
+//        return nil
+//    }
+//    err := godirwalk.WalkContext(ctx, dirname, logNode, nil)
+func WalkContext(ctx context.Context, pathname string, callback WalkContextFunc, opts *Options) error {
+	var options Options
+	if opts != nil {
+		options = copyOptions(opts)
+	}
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return callback(ctx, osPathname, de)
+	}
+
+	if errorCallback := options.ErrorCallback; errorCallback != nil {
+		options.ErrorCallback = func(osPathname string, err error) ErrorAction {
+			if ctx.Err() != nil {
+				return Halt
+			}
+			return errorCallback(osPathname, err)
+		}
+	}
+
+	if err := Walk(pathname, &options); err != nil {
+		return err
+	}
+	return ctx.Err() // canceled after the final callback
+}
@@ -0,0 +1,77 @@
+package godirwalk
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+)
+
+// WalkPaged walks the file tree rooted at the specified directory as Walk
+// does, and invokes onPage with each successive page of pageSize descendants
+// of the root, sorted by their pathnames relative to the root, using solidus
+// separators, followed by a final partial page when the number of descendants
+// is not a multiple of pageSize. Unlike the order Walk visits them, in which
+// the descendants of a directory named "a" precede a sibling named "a-b", the
+// descendants are sorted as a whole, so the pages are the same on every walk of
+// an unchanged tree, and a paginated view need not hold any state between
+// pages. Because the descendants are sorted once the walk completes, every
+// descendant is held in memory until the final page is provided. The Dirents
+// of each page are copies that onPage may retain.
+//
+// Pages are provided once the walk completes, and the first error returned by
+// onPage is returned by WalkPaged, without providing the remaining pages.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+//
+//    err := godirwalk.WalkPaged(osDirname, 100, nil, render)
+func WalkPaged(osDirname string, pageSize int, opts *Options, onPage func(page Dirents) error) error {
+	if pageSize <= 0 {
+		return errors.New("cannot walk paged without a positive page size")
+	}
+
+	options := HelperOptions(opts)
+
+	osDirname = filepath.Clean(osDirname)
+
+	type pagedNode struct {
+		rel string // sort key
+		de  *Dirent
+	}
+	var nodes []pagedNode
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		if osPathname == osDirname {
+			return nil
+		}
+		rel, err := filepath.Rel(osDirname, osPathname)
+		if err != nil {
+			return err
+		}
+		node := *de
+		nodes = append(nodes, pagedNode{rel: filepath.ToSlash(rel), de: &node})
+		return nil
+	}
+
+	if err := Walk(osDirname, &options); err != nil {
+		return err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].rel < nodes[j].rel })
+
+	for len(nodes) > 0 {
+		n := pageSize
+		if n > len(nodes) {
+			n = len(nodes)
+		}
+		page := make(Dirents, n) // onPage may retain each page
+		for i := range page {
+			page[i] = nodes[i].de
+		}
+		nodes = nodes[n:]
+		if err := onPage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
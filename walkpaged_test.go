@@ -0,0 +1,80 @@
+package godirwalk
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkPaged(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "a/c", "a-z", "d", "e/f/g", "h")
+	defer cleanup()
+
+	// Descendants of root sorted by their relative pathnames, so "a-z", which
+	// Walk visits after the descendants of "a", precedes them.
+	const expected = "a a-z a/b a/c d e e/f e/f/g h"
+
+	walkPages := func(t *testing.T, pageSize int) []string {
+		t.Helper()
+		var pages []string
+		err := WalkPaged(root, pageSize, nil, func(page Dirents) error {
+			var names []string
+			for _, de := range page {
+				rel, err := filepath.Rel(root, de.Path())
+				ensureError(t, err)
+				names = append(names, filepath.ToSlash(rel))
+			}
+			pages = append(pages, strings.Join(names, " "))
+			return nil
+		})
+		ensureError(t, err)
+		return pages
+	}
+
+	for _, tc := range []struct {
+		pageSize int
+		pages    []string
+	}{
+		{1, strings.Split(expected, " ")},
+		{3, []string{"a a-z a/b", "a/c d e", "e/f e/f/g h"}},
+		{4, []string{"a a-z a/b a/c", "d e e/f e/f/g", "h"}},
+		{9, []string{expected}},
+		{100, []string{expected}},
+	} {
+		pages := walkPages(t, tc.pageSize)
+		if got, want := strings.Join(pages, " | "), strings.Join(tc.pages, " | "); got != want {
+			t.Errorf("page size %d: GOT: %q; WANT: %q", tc.pageSize, got, want)
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		empty, cleanup := setupTree(t)
+		defer cleanup()
+		err := WalkPaged(empty, 10, nil, func(Dirents) error {
+			t.Errorf("GOT: page; WANT: no pages")
+			return nil
+		})
+		ensureError(t, err)
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		err := WalkPaged(root, 0, nil, func(Dirents) error { return nil })
+		ensureError(t, err, "page size")
+	})
+
+	t.Run("page error", func(t *testing.T) {
+		errPage := errors.New("page error")
+		var pages int
+		err := WalkPaged(root, 3, nil, func(Dirents) error {
+			pages++
+			return errPage
+		})
+		if got, want := err, errPage; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := pages, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package godirwalk
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChangeType identifies how a node differs from the BaselineState of a walk.
+type ChangeType int
+
+const (
+	// Added is the ChangeType of a node absent from the baseline.
+	Added ChangeType = iota
+
+	// Removed is the ChangeType of a node in the baseline that no longer
+	// exists.
+	Removed
+
+	// Modified is the ChangeType of a node whose mode differs from the
+	// baseline, or a regular file whose size or modification time differs.
+	Modified
+
+	// Unchanged is the ChangeType of a node that does not differ from the
+	// baseline.
+	Unchanged
+)
+
+// String returns the name of the ChangeType.
+func (ct ChangeType) String() string {
+	switch ct {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	case Unchanged:
+		return "unchanged"
+	default:
+		return "ChangeType(" + strconv.Itoa(int(ct)) + ")"
+	}
+}
+
+// nodeState is the recorded state of a single node.
+type nodeState struct {
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+}
+
+// WalkState is a snapshot of the nodes of a file system hierarchy, created by
+// NewWalkState, against which a later walk may compare the hierarchy using the
+// BaselineState option. Nodes are identified by their pathnames relative to
+// the root of the walk, so a WalkState may be compared against a copy of the
+// hierarchy at another location. A WalkState is not modified by walks that use
+// it, so it may be used by several at once.
+type WalkState struct {
+	nodes    map[string]nodeState // keyed by slash-separated relative pathname
+	children map[string][]string  // sorted names of children of each directory
+}
+
+// NewWalkState walks the file system hierarchy rooted at the specified
+// directory, and returns a snapshot of the mode, size, and modification time of
+// each of its nodes, including the root.
+//
+// Only the fields of the provided Options, which may be nil, that HelperOptions
+// copies are used.
+func NewWalkState(root string, opts *Options) (*WalkState, error) {
+	options := HelperOptions(opts)
+
+	root = filepath.Clean(root)
+	ws := &WalkState{nodes: make(map[string]nodeState), children: make(map[string][]string)}
+
+	options.Callback = func(osPathname string, de *Dirent) error {
+		fi := de.fileInfo
+		if fi == nil {
+			var err error
+			if fi, err = os.Lstat(osPathname); err != nil {
+				return err
+			}
+		}
+		rel, err := relativeTo(root, osPathname)
+		if err != nil {
+			return err
+		}
+		ws.nodes[rel] = nodeState{mode: fi.Mode(), size: fi.Size(), modTime: fi.ModTime()}
+		if rel != "." {
+			parent := path.Dir(rel)
+			ws.children[parent] = append(ws.children[parent], path.Base(rel))
+		}
+		return nil
+	}
+	if err := Walk(root, &options); err != nil {
+		return nil, err
+	}
+
+	for _, names := range ws.children {
+		sort.Strings(names)
+	}
+	return ws, nil
+}
+
+// relativeTo returns the slash-separated pathname of a node relative to the
+// root of the walk.
+func relativeTo(root, osPathname string) (string, error) {
+	rel, err := filepath.Rel(root, osPathname)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// reportChange invokes ChangeCallback with how the node differs from the
+// BaselineState of the walk.
+func reportChange(osPathname string, dirent *Dirent, options *walker) error {
+	rel, err := relativeTo(options.root, osPathname)
+	if err != nil {
+		return err
+	}
+	baseline, ok := options.BaselineState.nodes[rel]
+	if !ok {
+		return options.ChangeCallback(Added, dirent)
+	}
+	fi := dirent.fileInfo
+	if fi == nil {
+		if fi, err = os.Lstat(osPathname); err != nil {
+			return err
+		}
+	}
+	change := Unchanged
+	if fi.Mode() != baseline.mode || fi.Mode().IsRegular() && (fi.Size() != baseline.size || !fi.ModTime().Equal(baseline.modTime)) {
+		change = Modified
+	}
+	return options.ChangeCallback(change, dirent)
+}
+
+// reportRemoved invokes ChangeCallback for each child of the directory in the
+// BaselineState of the walk that is not among its present children, and for
+// each descendant of such a child, in depth first order. When ChangeCallback
+// returns filepath.SkipDir for a removed node, its descendants are not
+// reported.
+func reportRemoved(osDirname string, dirent *Dirent, deChildren Dirents, options *walker) error {
+	rel, err := relativeTo(options.root, osDirname)
+	if err != nil {
+		return err
+	}
+	names := options.BaselineState.children[rel]
+	if len(names) == 0 {
+		return nil
+	}
+	present := make(map[string]struct{}, len(deChildren))
+	for _, deChild := range deChildren {
+		present[deChild.name] = struct{}{}
+	}
+	for _, name := range names {
+		if _, ok := present[name]; !ok {
+			if err = reportRemovedNode(path.Join(rel, name), filepath.Join(dirent.path, name), options); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reportRemovedNode invokes ChangeCallback for a node in the BaselineState of
+// the walk that no longer exists, then for each of its descendants.
+func reportRemovedNode(rel, pathname string, options *walker) error {
+	baseline := options.BaselineState
+	de := &Dirent{
+		path:     pathname,
+		name:     path.Base(rel),
+		modeType: baseline.nodes[rel].mode & os.ModeType,
+		root:     options.root,
+	}
+	if err := options.ChangeCallback(Removed, de); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	for _, name := range baseline.children[rel] {
+		if err := reportRemovedNode(path.Join(rel, name), filepath.Join(pathname, name), options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package godirwalk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkBaselineState(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b", "a/c", "d/e/f", "g")
+	defer cleanup()
+
+	baseline, err := NewWalkState(root, nil)
+	ensureError(t, err)
+
+	ensureError(t, os.RemoveAll(filepath.Join(root, "d")))
+	ensureError(t, ioutil.WriteFile(filepath.Join(root, "g"), []byte("modified\n"), 0644))
+	ensureError(t, ioutil.WriteFile(filepath.Join(root, "h"), []byte("h\n"), 0644))
+
+	walkChanges := func(t *testing.T, changeCallback func(ChangeType, *Dirent) error) []string {
+		t.Helper()
+		var changes []string
+		err := Walk(root, &Options{
+			BaselineState: baseline,
+			ChangeCallback: func(change ChangeType, de *Dirent) error {
+				rel, err := filepath.Rel(root, de.Path())
+				ensureError(t, err)
+				changes = append(changes, change.String()+" "+filepath.ToSlash(rel))
+				return changeCallback(change, de)
+			},
+			Callback: func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err)
+		return changes
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		changes := walkChanges(t, func(ChangeType, *Dirent) error { return nil })
+		expected := []string{
+			"unchanged .",
+			"removed d",
+			"removed d/e",
+			"removed d/e/f",
+			"unchanged a",
+			"unchanged a/b",
+			"unchanged a/c",
+			"modified g",
+			"added h",
+		}
+		if got, want := strings.Join(changes, ", "), strings.Join(expected, ", "); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("skip removed descendants", func(t *testing.T) {
+		changes := walkChanges(t, func(change ChangeType, _ *Dirent) error {
+			if change == Removed {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if got, want := strings.Join(changes, ", "), "unchanged ., removed d, unchanged a, unchanged a/b, unchanged a/c, modified g, added h"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("requires ChangeCallback", func(t *testing.T) {
+		err := Walk(root, &Options{
+			BaselineState: baseline,
+			Callback:      func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "ChangeCallback")
+	})
+}
+
+func TestWalkBaselineStatePartialDirectory(t *testing.T) {
+	var entries []string
+	for i := 0; i < 10; i++ {
+		entries = append(entries, fmt.Sprintf("f%d", i))
+	}
+	root, cleanup := setupTree(t, entries...)
+	defer cleanup()
+
+	baseline, err := NewWalkState(root, nil)
+	ensureError(t, err)
+
+	// Reading only some of the entries of a directory does not mean the rest
+	// were removed.
+	var removed []string
+	err = Walk(root, &Options{
+		BaselineState: baseline,
+		ChangeCallback: func(change ChangeType, de *Dirent) error {
+			if change == Removed {
+				removed = append(removed, de.Name())
+			}
+			return nil
+		},
+		Callback:         func(string, *Dirent) error { return nil },
+		ErrorCallback:    func(string, error) ErrorAction { return SkipNode },
+		MaxEntriesPerDir: 3,
+	})
+	ensureError(t, err)
+
+	if len(removed) > 0 {
+		t.Errorf("GOT: %q removed; WANT: none", removed)
+	}
+}
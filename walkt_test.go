@@ -0,0 +1,211 @@
+//go:build go1.14
+// +build go1.14
+
+package godirwalk
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// goroutineLeakTimeout is how long WalkT waits for the goroutines started by a
+// walk to return before reporting them as leaked.
+const goroutineLeakTimeout = time.Second
+
+// WalkT invokes Walk, then reports a test error for each goroutine started
+// during the walk that runs code of this library and has not returned shortly
+// after Walk returns. Because goroutines are compared by identifier, WalkT
+// must not be used while a parallel test may also be walking.
+func WalkT(tb testing.TB, root string, opts *Options) error {
+	tb.Helper()
+	before := goroutineStacks()
+	err := Walk(root, opts)
+
+	var leaked []string
+	for deadline := time.Now().Add(goroutineLeakTimeout); ; time.Sleep(10 * time.Millisecond) {
+		leaked = leaked[:0]
+		for id, stack := range goroutineStacks() {
+			if _, ok := before[id]; !ok && strings.Contains(stack, "godirwalk.") {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+	for _, stack := range leaked {
+		tb.Errorf("GOT: leaked goroutine: %s; WANT: none", stack)
+	}
+	return err
+}
+
+// MustWalkT invokes WalkT with a copy of the provided Options whose Callback
+// is fn, and fails the test immediately when the walk returns an error. The
+// provided Options may be nil.
+func MustWalkT(tb testing.TB, root string, opts *Options, fn WalkFunc) {
+	tb.Helper()
+	var options Options
+	if opts != nil {
+		options = *opts
+	}
+	options.Callback = fn
+	if err := WalkT(tb, root, &options); err != nil {
+		tb.Fatalf("GOT: %v; WANT: %v", err, nil)
+	}
+}
+
+// NewDirentT returns the Dirent for the specified pathname, failing the test
+// immediately when it cannot. When nothing exists at the pathname, it first
+// creates an empty regular file there, along with any missing parent
+// directories, all of which are removed when the test completes.
+func NewDirentT(tb testing.TB, osPathname string) *Dirent {
+	tb.Helper()
+	if _, err := os.Lstat(osPathname); os.IsNotExist(err) {
+		created := filepath.Dir(osPathname)
+		for {
+			parent := filepath.Dir(created)
+			if _, err := os.Lstat(parent); err == nil || parent == created {
+				break
+			}
+			created = parent
+		}
+		if _, err := os.Lstat(created); err == nil {
+			created = osPathname // only the file itself is missing
+		}
+		if err := os.MkdirAll(filepath.Dir(osPathname), 0755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := ioutil.WriteFile(osPathname, nil, 0644); err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() {
+			if err := os.RemoveAll(created); err != nil {
+				tb.Error(err)
+			}
+		})
+	}
+	de, err := NewDirent(osPathname)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return de
+}
+
+// goroutineStacks returns the stack of each goroutine, keyed by its header
+// line, which holds its unique identifier.
+func goroutineStacks() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[string]string)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		header := stack
+		if i := strings.IndexByte(stack, '\n'); i >= 0 {
+			header = stack[:i]
+		}
+		// Discard the state following the identifier, which changes.
+		if i := strings.IndexByte(header, '['); i >= 0 {
+			header = header[:i]
+		}
+		stacks[header] = stack
+	}
+	return stacks
+}
+
+// leakRecorder records the errors reported by WalkT rather than failing the
+// test.
+type leakRecorder struct {
+	testing.TB
+	errors int
+}
+
+func (lr *leakRecorder) Errorf(string, ...interface{}) { lr.errors++ }
+
+func TestWalkT(t *testing.T) {
+	root, cleanup := setupTree(t, "a/b/c", "a/d", "e/f", "g")
+	defer cleanup()
+
+	errHalt := errors.New("halt")
+
+	for _, tc := range []struct {
+		name    string
+		options Options
+	}{
+		{"serial", Options{}},
+		{"parallel dirs", Options{ParallelDirs: true}},
+		{"eager descend", Options{EagerDescend: true}},
+		{"callback queue", Options{CallbackQueueSize: 4}},
+	} {
+		options := tc.options
+		t.Run(tc.name, func(t *testing.T) {
+			var count int64 // EagerDescend invokes the callback concurrently
+			MustWalkT(t, root, &options, func(string, *Dirent) error {
+				atomic.AddInt64(&count, 1)
+				return nil
+			})
+			if got, want := atomic.LoadInt64(&count), int64(8); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			options.Callback = func(osPathname string, _ *Dirent) error {
+				if filepath.Base(osPathname) == "b" {
+					return errHalt
+				}
+				return nil
+			}
+			if err := WalkT(t, root, &options); !errors.Is(err, errHalt) {
+				t.Errorf("GOT: %v; WANT: %v", err, errHalt)
+			}
+		})
+	}
+
+	t.Run("leak", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		lr := &leakRecorder{TB: t}
+		err := WalkT(lr, root, &Options{
+			Callback: func(osPathname string, _ *Dirent) error {
+				if osPathname == root {
+					go func() { <-release }()
+				}
+				return nil
+			},
+		})
+		ensureError(t, err)
+		if got, want := lr.errors, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("NewDirentT", func(t *testing.T) {
+		created := filepath.Join(root, "h/i/j")
+		t.Run("creates", func(t *testing.T) {
+			de := NewDirentT(t, created)
+			if got, want := de.IsRegular(), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := NewDirentT(t, filepath.Join(root, "a")).IsDir(), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+		if _, err := os.Lstat(filepath.Join(root, "h")); !os.IsNotExist(err) {
+			t.Errorf("GOT: %v; WANT: created directories removed", err)
+		}
+		if _, err := os.Lstat(filepath.Join(root, "a")); err != nil {
+			t.Errorf("GOT: %v; WANT: existing directory kept", err)
+		}
+	})
+}
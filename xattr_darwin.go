@@ -0,0 +1,75 @@
+package godirwalk
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// listxattr returns the names of the extended attributes of the specified
+// file, each terminated by a NUL byte.
+func listxattr(osPathname string) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(osPathname)
+	if err != nil {
+		return nil, err
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), 0, 0, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, _, errno = syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&buf[0])), size, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return buf[:size], nil
+}
+
+// getxattr returns the value of the named extended attribute of the specified
+// file.
+func getxattr(osPathname, name string) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(osPathname)
+	if err != nil {
+		return nil, err
+	}
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), 0, 0, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, _, errno = syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(&buf[0])), size, 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return buf[:size], nil
+}
+
+// setxattr sets the value of the named extended attribute of the specified
+// file.
+func setxattr(osPathname, name string, value []byte) error {
+	p, err := syscall.BytePtrFromString(osPathname)
+	if err != nil {
+		return err
+	}
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var v unsafe.Pointer
+	if len(value) > 0 {
+		v = unsafe.Pointer(&value[0])
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(v), uintptr(len(value)), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package godirwalk
+
+import "syscall"
+
+// listxattr returns the names of the extended attributes of the specified
+// file, each terminated by a NUL byte.
+func listxattr(osPathname string) ([]byte, error) {
+	size, err := syscall.Listxattr(osPathname, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(osPathname, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+// getxattr returns the value of the named extended attribute of the specified
+// file.
+func getxattr(osPathname, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(osPathname, name, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(osPathname, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+// setxattr sets the value of the named extended attribute of the specified
+// file.
+func setxattr(osPathname, name string, value []byte) error {
+	return syscall.Setxattr(osPathname, name, value, 0)
+}
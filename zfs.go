@@ -0,0 +1,68 @@
+package godirwalk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// zfsControlDir is the name of the directory at the root of each ZFS dataset
+// through which its snapshots are accessed.
+const zfsControlDir = ".zfs"
+
+// zfsMounts records the mount points of ZFS datasets and snapshots.
+type zfsMounts struct {
+	datasets  map[string]bool
+	snapshots map[string]bool
+}
+
+// newZFSMounts returns the ZFS mount points among the provided entries. A
+// snapshot is mounted from a device named after its dataset and snapshot,
+// separated by '@', such as "pool/home@daily".
+func newZFSMounts(entries []mountEntry) *zfsMounts {
+	zm := &zfsMounts{datasets: make(map[string]bool), snapshots: make(map[string]bool)}
+	for _, entry := range entries {
+		if entry.fsType != "zfs" {
+			continue
+		}
+		if strings.Contains(entry.device, "@") {
+			zm.snapshots[filepath.Clean(entry.mountPoint)] = true
+		} else {
+			zm.datasets[filepath.Clean(entry.mountPoint)] = true
+		}
+	}
+	return zm
+}
+
+// isSnapshot returns true if and only if a ZFS snapshot is mounted at the
+// specified directory.
+func (zm *zfsMounts) isSnapshot(osDirname string) bool {
+	if zm == nil || len(zm.snapshots) == 0 {
+		return false
+	}
+	osAbsname, err := filepath.Abs(osDirname)
+	return err == nil && zm.snapshots[osAbsname]
+}
+
+// includeControlDir returns the children of the specified directory, along with
+// its ZFS control directory when the directory is the mount point of a ZFS
+// dataset, and the control directory is hidden, so it is not enumerated.
+func (zm *zfsMounts) includeControlDir(osDirname string, deChildren Dirents) Dirents {
+	if zm == nil || len(zm.datasets) == 0 {
+		return deChildren
+	}
+	if osAbsname, err := filepath.Abs(osDirname); err != nil || !zm.datasets[osAbsname] {
+		return deChildren
+	}
+	for _, deChild := range deChildren {
+		if deChild.name == zfsControlDir {
+			return deChildren // already enumerated
+		}
+	}
+	osPathname := filepath.Join(osDirname, zfsControlDir)
+	fi, err := os.Lstat(osPathname)
+	if err != nil {
+		return deChildren
+	}
+	return append(deChildren, &Dirent{path: osPathname, name: zfsControlDir, modeType: fi.Mode() & os.ModeType})
+}
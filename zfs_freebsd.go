@@ -0,0 +1,44 @@
+package godirwalk
+
+import (
+	"os"
+	"syscall"
+)
+
+// mntNowait directs getfsstat(2) to return the information it has without
+// refreshing it from each file system.
+const mntNowait = 2
+
+// loadZFSMounts returns the ZFS mount points from the statfs(2) information of
+// every mounted file system.
+func loadZFSMounts() (*zfsMounts, error) {
+	n, err := syscall.Getfsstat(nil, mntNowait)
+	if err != nil {
+		return nil, os.NewSyscallError("getfsstat", err)
+	}
+	buf := make([]syscall.Statfs_t, n)
+	if n, err = syscall.Getfsstat(buf, mntNowait); err != nil {
+		return nil, os.NewSyscallError("getfsstat", err)
+	}
+	entries := make([]mountEntry, 0, n)
+	for _, sfs := range buf[:n] {
+		entries = append(entries, mountEntry{
+			device:     int8String(sfs.Mntfromname[:]),
+			mountPoint: int8String(sfs.Mntonname[:]),
+			fsType:     int8String(sfs.Fstypename[:]),
+		})
+	}
+	return newZFSMounts(entries), nil
+}
+
+// int8String returns the NUL-terminated string held in b.
+func int8String(b []int8) string {
+	s := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c == 0 {
+			break
+		}
+		s = append(s, byte(c))
+	}
+	return string(s)
+}
@@ -0,0 +1,11 @@
+package godirwalk
+
+// loadZFSMounts returns the ZFS mount points from the mount table of the mount
+// namespace of this process.
+func loadZFSMounts() (*zfsMounts, error) {
+	entries, err := loadMountEntries()
+	if err != nil {
+		return nil, err
+	}
+	return newZFSMounts(entries), nil
+}
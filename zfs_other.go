@@ -0,0 +1,7 @@
+// +build !linux,!freebsd
+
+package godirwalk
+
+// loadZFSMounts returns no ZFS mount points, because they are only detected on
+// Linux and FreeBSD.
+func loadZFSMounts() (*zfsMounts, error) { return nil, nil }
@@ -0,0 +1,92 @@
+package godirwalk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkSkipZFSSnapshots(t *testing.T) {
+	root, cleanup := setupTree(t, ".zfs/snapshot/daily/f", "a/.zfs/snapshot/daily/f", "a/b", "c/.zfs")
+	defer cleanup()
+
+	walkNames := func(t *testing.T, options *Options) string {
+		t.Helper()
+		var actual []string
+		options.ScratchBuffer = testScratchBuffer
+		options.Callback = func(osPathname string, _ *Dirent) error {
+			rel, err := filepath.Rel(root, osPathname)
+			ensureError(t, err)
+			actual = append(actual, filepath.ToSlash(rel))
+			return nil
+		}
+		ensureError(t, Walk(root, options))
+		return strings.Join(actual, " ")
+	}
+
+	// The file named .zfs is not a control directory.
+	if got, want := walkNames(t, &Options{SkipZFSSnapshots: true}), ". a a/b c c/.zfs"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	t.Run("not skipped", func(t *testing.T) {
+		if got, want := walkNames(t, &Options{}), ". .zfs .zfs/snapshot .zfs/snapshot/daily .zfs/snapshot/daily/f a a/.zfs a/.zfs/snapshot a/.zfs/snapshot/daily a/.zfs/snapshot/daily/f a/b c c/.zfs"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("both", func(t *testing.T) {
+		err := Walk(root, &Options{
+			SkipZFSSnapshots:    true,
+			IncludeZFSSnapshots: true,
+			Callback:            func(string, *Dirent) error { return nil },
+		})
+		ensureError(t, err, "SkipZFSSnapshots and IncludeZFSSnapshots")
+	})
+}
+
+func TestZFSMounts(t *testing.T) {
+	root, cleanup := setupTree(t, ".zfs/snapshot/", "f")
+	defer cleanup()
+	snapshot := filepath.Join(root, ".zfs", "snapshot", "daily")
+
+	zm := newZFSMounts([]mountEntry{
+		{device: "/dev/sda1", mountPoint: "/", fsType: "ext4"},
+		{device: "pool/home", mountPoint: root, fsType: "zfs"},
+		{device: "pool/home@daily", mountPoint: snapshot, fsType: "zfs"},
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		if got, want := zm.isSnapshot(snapshot), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := zm.isSnapshot(root), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := (*zfsMounts)(nil).isSnapshot(snapshot), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("hidden control directory", func(t *testing.T) {
+		f := &Dirent{path: filepath.Join(root, "f"), name: "f"}
+		deChildren := zm.includeControlDir(root, Dirents{f})
+		if got, want := len(deChildren), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := deChildren[1].Name(), ".zfs"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := deChildren[1].IsDir(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Already enumerated, or not the root of a dataset.
+		if got, want := len(zm.includeControlDir(root, deChildren)), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(zm.includeControlDir(filepath.Join(root, ".zfs"), nil)), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}